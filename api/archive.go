@@ -0,0 +1,249 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	stdjson "encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/akiomik/squawks/api/json"
+)
+
+// ArchiveIndex is the name of the index of an archive directory, which has a
+// line of JSON per archived response.
+const ArchiveIndex = "index.jsonl"
+
+// maxCursorLength limits the length of the cursor in file names.
+const maxCursorLength = 64
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// ArchiveEntry describes a raw response in an archive.
+type ArchiveEntry struct {
+	Sequence uint64 `json:"sequence"`
+	// File is the name of the gzip-compressed response body in the archive directory.
+	File        string            `json:"file"`
+	Url         string            `json:"url"`
+	Cursor      string            `json:"cursor,omitempty"`
+	Params      map[string]string `json:"params"`
+	RequestedAt time.Time         `json:"requested_at"`
+	Status      int               `json:"status"`
+}
+
+// Archive saves raw search responses to a directory, so that they can be
+// exported again by ReplayArchive. It is safe for concurrent use.
+type Archive struct {
+	Dir string
+
+	mu    sync.Mutex
+	index *os.File
+	seq   uint64
+}
+
+// OpenArchive creates dir if needed. Responses are numbered after those
+// already in dir.
+func OpenArchive(dir string) (*Archive, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	entries, err := ReadArchive(dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	index, err := os.OpenFile(filepath.Join(dir, ArchiveIndex), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Archive{Dir: dir, index: index}
+	if len(entries) != 0 {
+		a.seq = entries[len(entries)-1].Sequence
+	}
+
+	return a, nil
+}
+
+// Save writes body to a file named by the sequence number and cursor of e, and
+// adds e to the index.
+func (a *Archive) Save(e ArchiveEntry, body []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.index == nil {
+		return fmt.Errorf("archive is closed")
+	}
+
+	e.Sequence = a.seq + 1
+	e.File = archiveFileName(e.Sequence, e.Cursor)
+	if err := writeGzipFile(filepath.Join(a.Dir, e.File), body); err != nil {
+		return err
+	}
+
+	var line bytes.Buffer
+	enc := stdjson.NewEncoder(&line)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(e); err != nil {
+		return err
+	}
+
+	if _, err := a.index.Write(line.Bytes()); err != nil {
+		return err
+	}
+
+	a.seq = e.Sequence
+	return nil
+}
+
+// Close closes the index. Calling Close more than once is a no-op.
+func (a *Archive) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.index == nil {
+		return nil
+	}
+
+	err := a.index.Close()
+	a.index = nil
+	return err
+}
+
+func archiveFileName(seq uint64, cursor string) string {
+	name := fmt.Sprintf("%06d", seq)
+	if slug := unsafeFileChars.ReplaceAllString(cursor, "_"); len(slug) != 0 {
+		if len(slug) > maxCursorLength {
+			slug = slug[:maxCursorLength]
+		}
+
+		name += "-" + slug
+	}
+
+	return name + ".json.gz"
+}
+
+func writeGzipFile(name string, body []byte) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+
+	w := gzip.NewWriter(f)
+	_, err = w.Write(body)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// ReadArchive returns the entries of the archive in dir in the order they
+// were saved.
+func ReadArchive(dir string) ([]ArchiveEntry, error) {
+	f, err := os.Open(filepath.Join(dir, ArchiveIndex))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []ArchiveEntry
+	s := bufio.NewScanner(f)
+	for line := 1; s.Scan(); line++ {
+		if len(bytes.TrimSpace(s.Bytes())) == 0 {
+			continue
+		}
+
+		var e ArchiveEntry
+		if err := stdjson.Unmarshal(s.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("invalid archive index: line %d: %w", line, err)
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, s.Err()
+}
+
+// ReadArchivedBody returns the response body of e in the archive in dir.
+func ReadArchivedBody(dir string, e ArchiveEntry) ([]byte, error) {
+	f, err := os.Open(filepath.Join(dir, e.File))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", e.File, err)
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+// ReplayArchive reads the responses archived in dir in order, as if they were
+// returned by SearchAll. Error responses are skipped. It stops at the first
+// error.
+func ReplayArchive(dir string) <-chan *SearchResult {
+	ch := make(chan *SearchResult)
+
+	go func() {
+		defer close(ch)
+
+		entries, err := ReadArchive(dir)
+		if err != nil {
+			ch <- &SearchResult{Error: fmt.Errorf("failed to read archive: %w", err)}
+			return
+		}
+
+		for _, e := range entries {
+			if e.Status >= 400 {
+				continue
+			}
+
+			body, err := ReadArchivedBody(dir, e)
+			if err != nil {
+				ch <- &SearchResult{Error: fmt.Errorf("failed to read archived response: %w", err)}
+				return
+			}
+
+			var res json.Adaptive
+			if err := stdjson.Unmarshal(body, &res); err != nil {
+				ch <- &SearchResult{Error: fmt.Errorf("failed to parse archived response: %s: %w", e.File, err)}
+				return
+			}
+
+			ch <- &SearchResult{Adaptive: &res}
+		}
+	}()
+
+	return ch
+}
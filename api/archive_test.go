@@ -0,0 +1,242 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build medium
+// +build medium
+
+package api
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/akiomik/squawks/api/json"
+)
+
+func TestArchiveFileName(t *testing.T) {
+	examples := map[string]struct {
+		seq      uint64
+		cursor   string
+		expected string
+	}{
+		"first-page": {seq: 1, cursor: "", expected: "000001.json.gz"},
+		"cursor":     {seq: 2, cursor: "scroll:thGAVUV0VF+/=", expected: "000002-scroll_thGAVUV0VF_.json.gz"},
+		"long-cursor": {
+			seq:      1234567,
+			cursor:   "scroll:0123456789012345678901234567890123456789012345678901234567890123456789",
+			expected: "1234567-scroll_012345678901234567890123456789012345678901234567890123456.json.gz",
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, e.expected, archiveFileName(e.seq, e.cursor))
+		})
+	}
+}
+
+func TestArchive(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "raw")
+	bodies := []string{
+		`{"globalObjects":{"tweets":{"1":{"id":1,"full_text":"a"}},"users":{}}}`,
+		`{"globalObjects":{"tweets":{},"users":{}}}`,
+	}
+
+	a, err := OpenArchive(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NoError(t, a.Save(ArchiveEntry{Params: map[string]string{"q": "foo"}, Status: 200}, []byte(bodies[0])))
+	assert.NoError(t, a.Close())
+	assert.EqualError(t, a.Save(ArchiveEntry{}, []byte(bodies[1])), "archive is closed")
+
+	// A reopened archive continues the sequence.
+	a, err = OpenArchive(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NoError(t, a.Save(ArchiveEntry{Cursor: "scroll:2", Params: map[string]string{"q": "foo", "cursor": "scroll:2"}, Status: 200}, []byte(bodies[1])))
+	assert.NoError(t, a.Close())
+
+	entries, err := ReadArchive(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []ArchiveEntry{
+		{Sequence: 1, File: "000001.json.gz", Params: map[string]string{"q": "foo"}, Status: 200},
+		{Sequence: 2, File: "000002-scroll_2.json.gz", Cursor: "scroll:2", Params: map[string]string{"q": "foo", "cursor": "scroll:2"}, Status: 200},
+	}, entries)
+
+	for i, e := range entries {
+		body, err := ReadArchivedBody(dir, e)
+		assert.NoError(t, err)
+		assert.Equal(t, bodies[i], string(body))
+	}
+
+	var actual []*SearchResult
+	for res := range ReplayArchive(dir) {
+		actual = append(actual, res)
+	}
+
+	if assert.Len(t, actual, 2) {
+		assert.NoError(t, actual[0].Error)
+		assert.Equal(t, "a", actual[0].Adaptive.GlobalObjects.Tweets["1"].FullText)
+		assert.NoError(t, actual[1].Error)
+		assert.Empty(t, actual[1].Adaptive.GlobalObjects.Tweets)
+	}
+}
+
+func TestReplayArchiveErrors(t *testing.T) {
+	examples := map[string]struct {
+		index string
+		files map[string]string
+		msg   string
+	}{
+		"no-index": {
+			index: "",
+			files: nil,
+			msg:   "failed to read archive: open ",
+		},
+		"invalid-index": {
+			index: "{\"sequence\":1,\"file\":\"000001.json.gz\"}\nnot json\n",
+			files: nil,
+			msg:   "failed to read archive: invalid archive index: line 2: ",
+		},
+		"missing-file": {
+			index: "{\"sequence\":1,\"file\":\"000001.json.gz\"}\n",
+			files: nil,
+			msg:   "failed to read archived response: open ",
+		},
+		"not-gzip": {
+			index: "{\"sequence\":1,\"file\":\"000001.json.gz\"}\n",
+			files: map[string]string{"000001.json.gz": "{}"},
+			msg:   "failed to read archived response: 000001.json.gz: ",
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			if len(e.index) != 0 {
+				assert.NoError(t, os.WriteFile(filepath.Join(dir, ArchiveIndex), []byte(e.index), 0644))
+			}
+
+			for name, content := range e.files {
+				assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+			}
+
+			var actual []*SearchResult
+			for res := range ReplayArchive(dir) {
+				actual = append(actual, res)
+			}
+
+			if assert.Len(t, actual, 1) {
+				assert.ErrorContains(t, actual[0].Error, e.msg)
+			}
+		})
+	}
+}
+
+func TestSearchArchive(t *testing.T) {
+	dir := t.TempDir()
+	a, err := OpenArchive(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer a.Close()
+
+	c := NewClient()
+	c.Archive = a
+
+	httpmock.ActivateNonDefault(c.Client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	body := `{ "globalObjects": { "tweets": {}, "users": {} } }`
+	httpmock.RegisterResponder("GET", "https://twitter.com/i/api/2/search/adaptive.json", func(req *http.Request) (*http.Response, error) {
+		res := httpmock.NewStringResponse(200, body)
+		res.Header.Add("Content-Type", "application/json")
+		return res, nil
+	})
+
+	actual, err := c.Search(&SearchOptions{Query: Query{Text: "foo"}, Cursor: "scroll:1"})
+	assert.NoError(t, err)
+	assert.Equal(t, &json.Adaptive{GlobalObjects: json.GlobalObjects{Tweets: map[string]json.Tweet{}, Users: map[string]json.User{}}}, actual)
+
+	entries, err := ReadArchive(dir)
+	if !assert.NoError(t, err) || !assert.Len(t, entries, 1) {
+		return
+	}
+
+	e := entries[0]
+	assert.Equal(t, uint64(1), e.Sequence)
+	assert.Equal(t, "000001-scroll_1.json.gz", e.File)
+	assert.Equal(t, "scroll:1", e.Cursor)
+	assert.Equal(t, "foo", e.Params["q"])
+	assert.Equal(t, "https://twitter.com/i/api/2/search/adaptive.json?count=40&cursor=scroll%3A1&include_quote_count=true&include_reply_count=1&q=foo&query_source=typed_query&tweet_mode=extended&tweet_search_mode=live", e.Url)
+	assert.Equal(t, 200, e.Status)
+	assert.False(t, e.RequestedAt.IsZero())
+
+	archived, err := ReadArchivedBody(dir, e)
+	assert.NoError(t, err)
+	assert.Equal(t, body, string(archived))
+}
+
+func TestSearchArchiveErrorResponse(t *testing.T) {
+	dir := t.TempDir()
+	a, err := OpenArchive(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer a.Close()
+
+	c := NewClient()
+	c.Archive = a
+
+	httpmock.ActivateNonDefault(c.Client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	body := `{ "errors": [{ "code": 200, "message": "forbidden" }] }`
+	httpmock.RegisterResponder("GET", "https://twitter.com/i/api/2/search/adaptive.json", func(req *http.Request) (*http.Response, error) {
+		res := httpmock.NewStringResponse(403, body)
+		res.Header.Add("Content-Type", "application/json")
+		return res, nil
+	})
+
+	_, err = c.Search(&SearchOptions{Query: Query{Text: "foo"}})
+	assert.EqualError(t, err, "200: forbidden")
+
+	entries, err := ReadArchive(dir)
+	if !assert.NoError(t, err) || !assert.Len(t, entries, 1) {
+		return
+	}
+
+	assert.Equal(t, 403, entries[0].Status)
+
+	archived, err := ReadArchivedBody(dir, entries[0])
+	assert.NoError(t, err)
+	assert.Equal(t, body, string(archived))
+
+	// Error responses are not replayed.
+	var actual []*SearchResult
+	for res := range ReplayArchive(dir) {
+		actual = append(actual, res)
+	}
+
+	assert.Empty(t, actual)
+}
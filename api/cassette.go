@@ -0,0 +1,258 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"bufio"
+	"bytes"
+	stdjson "encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CassetteRequest is a recorded request. Headers are not recorded, so that
+// cassettes do not contain auth or guest tokens.
+type CassetteRequest struct {
+	Method string `json:"method"`
+	Url    string `json:"url"`
+}
+
+// CassetteResponse is a recorded response.
+type CassetteResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body"`
+}
+
+// Interaction is a request and its response.
+type Interaction struct {
+	Request    CassetteRequest  `json:"request"`
+	Response   CassetteResponse `json:"response"`
+	RecordedAt time.Time        `json:"recorded_at"`
+}
+
+// key matches requests by method, path and query parameters, so that
+// cassettes can be replayed with other base urls.
+func (r CassetteRequest) key() (string, error) {
+	u, err := url.Parse(r.Url)
+	if err != nil {
+		return "", err
+	}
+
+	return requestKey(r.Method, u), nil
+}
+
+func requestKey(method string, u *url.URL) string {
+	return method + " " + u.Path + "?" + u.Query().Encode()
+}
+
+// Cassette records the http interactions of a session to a file of JSON lines,
+// or replays the interactions loaded from one. It is safe for concurrent use.
+type Cassette struct {
+	Name string
+
+	mu  sync.Mutex
+	out *os.File
+	// interactions are the recorded responses by request key, and next is
+	// the index of the next response to replay for each key.
+	interactions map[string][]Interaction
+	next         map[string]int
+}
+
+// CreateCassette creates a cassette file to record to, replacing any existing one.
+func CreateCassette(name string) (*Cassette, error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cassette{Name: name, out: f}, nil
+}
+
+// LoadCassette reads a cassette file to replay.
+func LoadCassette(name string) (*Cassette, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	c := &Cassette{Name: name, interactions: map[string][]Interaction{}, next: map[string]int{}}
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 64*1024*1024)
+	for line := 1; s.Scan(); line++ {
+		if len(bytes.TrimSpace(s.Bytes())) == 0 {
+			continue
+		}
+
+		var i Interaction
+		if err := stdjson.Unmarshal(s.Bytes(), &i); err != nil {
+			return nil, fmt.Errorf("invalid cassette: line %d: %w", line, err)
+		}
+
+		key, err := i.Request.key()
+		if err != nil {
+			return nil, fmt.Errorf("invalid cassette: line %d: %w", line, err)
+		}
+
+		c.interactions[key] = append(c.interactions[key], i)
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Close closes the file being recorded to. Calling Close more than once is a no-op.
+func (c *Cassette) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.out == nil {
+		return nil
+	}
+
+	err := c.out.Close()
+	c.out = nil
+	return err
+}
+
+// Recorder returns a transport that sends requests with next and records them
+// to c. Requests failing without a response are not recorded.
+func (c *Cassette) Recorder(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &cassetteRecorder{cassette: c, next: next}
+}
+
+// Replayer returns a transport that responds to requests with the recorded
+// responses to the same method, path and query parameters, in the order they
+// were recorded. Once they are used up, the last one is repeated.
+func (c *Cassette) Replayer(_ http.RoundTripper) http.RoundTripper {
+	return &cassetteReplayer{cassette: c}
+}
+
+func (c *Cassette) record(i Interaction) error {
+	line, err := stdjson.Marshal(i)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.out == nil {
+		return fmt.Errorf("cassette is closed: %s", c.Name)
+	}
+
+	// Interactions are written at once, as the process may exit at any time.
+	_, err = c.out.Write(append(line, '\n'))
+	return err
+}
+
+func (c *Cassette) replay(req *http.Request) (Interaction, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := requestKey(req.Method, req.URL)
+	is := c.interactions[key]
+	if len(is) == 0 {
+		return Interaction{}, false
+	}
+
+	n := c.next[key]
+	if n < len(is)-1 {
+		c.next[key] = n + 1
+	}
+
+	return is[n], true
+}
+
+type cassetteRecorder struct {
+	cassette *Cassette
+	next     http.RoundTripper
+}
+
+func (r *cassetteRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	header := res.Header.Clone()
+	header.Del("Set-Cookie")
+	i := Interaction{
+		Request:    CassetteRequest{Method: req.Method, Url: req.URL.String()},
+		Response:   CassetteResponse{Status: res.StatusCode, Header: header, Body: string(body)},
+		RecordedAt: time.Now().UTC(),
+	}
+
+	if err := r.cassette.record(i); err != nil {
+		return nil, fmt.Errorf("failed to record: %w", err)
+	}
+
+	return res, nil
+}
+
+type cassetteReplayer struct {
+	cassette *Cassette
+}
+
+func (r *cassetteReplayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	i, ok := r.cassette.replay(req)
+	if !ok {
+		return nil, fmt.Errorf("no recorded response in %s: %s", r.cassette.Name, requestKey(req.Method, req.URL))
+	}
+
+	res := i.Response
+	header := res.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", res.Status, http.StatusText(res.Status)),
+		StatusCode:    res.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(res.Body)),
+		ContentLength: int64(len(res.Body)),
+		Request:       req,
+	}, nil
+}
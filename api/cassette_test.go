@@ -0,0 +1,171 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build medium
+// +build medium
+
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/akiomik/squawks/config"
+)
+
+func TestCassette(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/1.1/guest/activate.json":
+			fmt.Fprint(w, `{"guest_token":"1"}`)
+		case "/i/api/2/search/adaptive.json":
+			if len(r.URL.Query().Get("cursor")) == 0 {
+				fmt.Fprint(w, `{"globalObjects":{"tweets":{"1":{"id":1,"full_text":"a"}},"users":{}},"timeline":{"instructions":[{"addEntries":{"entries":[{"entryId":"sq-cursor-bottom","content":{"operation":{"cursor":{"value":"scroll:1","cursorType":"Bottom"}}}}]}}]}}`)
+			} else {
+				fmt.Fprint(w, `{"globalObjects":{"tweets":{},"users":{}}}`)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	name := filepath.Join(t.TempDir(), "cassette.jsonl")
+	search := func(c config.ClientConfig) []*SearchResult {
+		var results []*SearchResult
+		for res := range NewClientWithConfig(c).SearchAll(SearchOptions{Query: Query{Text: "foo"}}) {
+			results = append(results, res)
+		}
+
+		return results
+	}
+
+	recorder, err := CreateCassette(name)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	recorded := search(config.ClientConfig{ApiBaseUrl: server.URL, SearchBaseUrl: server.URL, WrapTransport: recorder.Recorder})
+	assert.NoError(t, recorder.Close())
+	assert.Equal(t, 3, requests)
+	if assert.Len(t, recorded, 2) {
+		assert.NoError(t, recorded[0].Error)
+		assert.Equal(t, "a", recorded[0].Adaptive.GlobalObjects.Tweets["1"].FullText)
+	}
+
+	content, err := os.ReadFile(name)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, strings.Count(string(content), "\n"))
+	assert.NotContains(t, string(content), "AAAAAAAA")
+
+	player, err := LoadCassette(name)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The cassette is replayed without the server and regardless of the base url.
+	replayed := search(config.ClientConfig{ApiBaseUrl: "http://127.0.0.1:1", SearchBaseUrl: "http://127.0.0.1:1", WrapTransport: player.Replayer})
+	assert.Equal(t, 3, requests)
+	assert.Equal(t, recorded, replayed)
+}
+
+func TestCassetteReplayer(t *testing.T) {
+	content := `{"request":{"method":"GET","url":"https://twitter.com/a?x=1&y=2"},"response":{"status":200,"body":"first"}}
+{"request":{"method":"GET","url":"https://twitter.com/a?y=2&x=1"},"response":{"status":429,"body":"second"}}
+{"request":{"method":"POST","url":"https://twitter.com/b"},"response":{"status":200,"body":"post"}}
+`
+	name := filepath.Join(t.TempDir(), "cassette.jsonl")
+	assert.NoError(t, os.WriteFile(name, []byte(content), 0644))
+
+	c, err := LoadCassette(name)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	examples := []struct {
+		method         string
+		url            string
+		expectedStatus int
+		expectedBody   string
+		msg            string
+	}{
+		{method: "GET", url: "http://localhost/a?y=2&x=1", expectedStatus: 200, expectedBody: "first", msg: ""},
+		{method: "GET", url: "http://localhost/a?x=1&y=2", expectedStatus: 429, expectedBody: "second", msg: ""},
+		{method: "GET", url: "http://localhost/a?x=1&y=2", expectedStatus: 429, expectedBody: "second", msg: ""},
+		{method: "POST", url: "http://localhost/b", expectedStatus: 200, expectedBody: "post", msg: ""},
+		{method: "GET", url: "http://localhost/b", msg: "no recorded response in " + name + ": GET /b?"},
+		{method: "GET", url: "http://localhost/a?x=1", msg: "no recorded response in " + name + ": GET /a?x=1"},
+	}
+
+	client := &http.Client{Transport: c.Replayer(nil)}
+	for i, e := range examples {
+		t.Run(fmt.Sprintf("%d-%s-%s", i, e.method, e.url), func(t *testing.T) {
+			req, err := http.NewRequest(e.method, e.url, nil)
+			assert.NoError(t, err)
+
+			res, err := client.Do(req)
+			if len(e.msg) != 0 {
+				assert.ErrorContains(t, err, e.msg)
+				return
+			}
+
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+			assert.Equal(t, e.expectedStatus, res.StatusCode)
+			assert.Equal(t, e.expectedBody, string(body))
+		})
+	}
+}
+
+func TestLoadCassette(t *testing.T) {
+	examples := map[string]struct {
+		content string
+		msg     string
+	}{
+		"empty":       {content: "", msg: ""},
+		"blank-lines": {content: "\n{\"request\":{\"method\":\"GET\",\"url\":\"https://twitter.com/\"}}\n\n", msg: ""},
+		"invalid":     {content: "{}\nnot json\n", msg: "invalid cassette: line 2: "},
+		"invalid-url": {content: "{\"request\":{\"method\":\"GET\",\"url\":\":\"}}\n", msg: "invalid cassette: line 1: "},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cassette.jsonl")
+			assert.NoError(t, os.WriteFile(path, []byte(e.content), 0644))
+
+			_, err := LoadCassette(path)
+			if len(e.msg) == 0 {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, e.msg)
+			}
+		})
+	}
+}
@@ -15,29 +15,129 @@
 package api
 
 import (
+	"net/url"
+	"strings"
+	"sync/atomic"
+
 	"github.com/go-resty/resty/v2"
 
 	"github.com/akiomik/squawks/api/json"
 	"github.com/akiomik/squawks/config"
+	"github.com/akiomik/squawks/logging"
 )
 
+type Stats struct {
+	Requests    uint64
+	Retries     uint64
+	GuestTokens uint64
+}
+
 type Client struct {
+	stats            Stats // first for 64-bit alignment of atomic operations
 	Client           *resty.Client
 	UserAgent        string
 	AuthToken        string
 	MaxRetryAttempts uint
+	ApiBaseUrl       string
+	SearchBaseUrl    string
+	// GuestTokenPool is used to get guest tokens instead of activating a new one per search if set.
+	GuestTokenPool *GuestTokenPool
+	// Archive saves the raw body of every search response if set.
+	Archive *Archive
+	Logger  logging.Logger
 }
 
+const (
+	DefaultApiBaseUrl    = "https://api.twitter.com"
+	DefaultSearchBaseUrl = "https://twitter.com"
+)
+
 func NewClient() *Client {
 	client := Client{}
 	client.Client = resty.New()
 	client.UserAgent = "squawks/" + config.Version
 	client.AuthToken = "AAAAAAAAAAAAAAAAAAAAANRILgAAAAAAnNwIzUejRCOuH5E6I8xnZz4puTs%3D1Zv7ttfk8LF81IUq16cHjhLTvJu4FA33AGWWjCpTnA"
 	client.MaxRetryAttempts = 3
+	client.ApiBaseUrl = DefaultApiBaseUrl
+	client.SearchBaseUrl = DefaultSearchBaseUrl
+	client.Logger = logging.Default()
+
+	client.Client.OnAfterResponse(func(_ *resty.Client, res *resty.Response) error {
+		client.logResponse(res)
+		return nil
+	})
+	client.Client.OnError(func(req *resty.Request, err error) {
+		client.Logger.Warn("http request failed", "method", req.Method, "endpoint", endpoint(req.URL), "error", err)
+	})
 
 	return &client
 }
 
+// NewClientWithConfig returns a client with the configured settings applied over the defaults.
+func NewClientWithConfig(c config.ClientConfig) *Client {
+	client := NewClient()
+
+	if len(c.AuthToken) != 0 {
+		client.AuthToken = c.AuthToken
+	}
+
+	if len(c.UserAgent) != 0 {
+		client.UserAgent = c.UserAgent
+	}
+
+	if c.MaxRetryAttempts != nil {
+		client.MaxRetryAttempts = *c.MaxRetryAttempts
+	}
+
+	if len(c.Proxy) != 0 {
+		client.Client.SetProxy(c.Proxy)
+	}
+
+	if c.WrapTransport != nil {
+		client.Client.SetTransport(c.WrapTransport(client.Client.GetClient().Transport))
+	}
+
+	if len(c.ApiBaseUrl) != 0 {
+		client.ApiBaseUrl = strings.TrimRight(c.ApiBaseUrl, "/")
+	}
+
+	if len(c.SearchBaseUrl) != 0 {
+		client.SearchBaseUrl = strings.TrimRight(c.SearchBaseUrl, "/")
+	}
+
+	return client
+}
+
+func endpoint(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return rawUrl
+	}
+
+	return u.Host + u.Path
+}
+
+func (c *Client) logResponse(res *resty.Response) {
+	args := []interface{}{
+		"method", res.Request.Method,
+		"endpoint", endpoint(res.Request.URL),
+		"status", res.StatusCode(),
+		"latency_ms", res.Time().Milliseconds(),
+	}
+
+	for _, h := range []string{"x-rate-limit-limit", "x-rate-limit-remaining", "x-rate-limit-reset"} {
+		if v := res.Header().Get(h); len(v) != 0 {
+			args = append(args, h, v)
+		}
+	}
+
+	if res.IsError() {
+		c.Logger.Warn("http request", args...)
+	} else {
+		c.Logger.Debug("http request", args...)
+	}
+}
+
 func (c *Client) Request() *resty.Request {
 	client := c.Client.R().SetHeader("Accept", "application/json")
 
@@ -52,11 +152,23 @@ func (c *Client) Request() *resty.Request {
 	return client
 }
 
+// Stats returns the number of requests sent, searches retried and guest tokens
+// activated so far. It is safe to call while searching.
+func (c *Client) Stats() Stats {
+	return Stats{
+		Requests:    atomic.LoadUint64(&c.stats.Requests),
+		Retries:     atomic.LoadUint64(&c.stats.Retries),
+		GuestTokens: atomic.LoadUint64(&c.stats.GuestTokens),
+	}
+}
+
 func (c *Client) GetGuestToken() (string, error) {
+	atomic.AddUint64(&c.stats.Requests, 1)
+	atomic.AddUint64(&c.stats.GuestTokens, 1)
 	res, err := c.Request().
 		SetResult(json.Activate{}).
 		SetError(json.ErrorResponse{}).
-		Post("https://api.twitter.com/1.1/guest/activate.json")
+		Post(c.ApiBaseUrl + "/1.1/guest/activate.json")
 
 	if err != nil {
 		return "", err
@@ -66,5 +178,14 @@ func (c *Client) GetGuestToken() (string, error) {
 		return "", res.Error().(*json.ErrorResponse)
 	}
 
+	c.Logger.Info("activated guest token")
 	return res.Result().(*json.Activate).GuestToken, nil
 }
+
+func (c *Client) guestToken() (string, error) {
+	if c.GuestTokenPool != nil {
+		return c.GuestTokenPool.Get()
+	}
+
+	return c.GetGuestToken()
+}
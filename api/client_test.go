@@ -18,6 +18,7 @@
 package api
 
 import (
+	"bytes"
 	"net/http"
 	"testing"
 
@@ -25,6 +26,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/akiomik/squawks/config"
+	"github.com/akiomik/squawks/logging"
 )
 
 func NewJsonResponse(code int, body string) func(req *http.Request) (*http.Response, error) {
@@ -42,6 +44,31 @@ func TestNewClient(t *testing.T) {
 	assert.NotEmpty(t, c.AuthToken)
 }
 
+func TestNewClientWithConfig(t *testing.T) {
+	attempts := uint(0)
+	c := NewClientWithConfig(config.ClientConfig{
+		AuthToken:        "my-auth-token",
+		UserAgent:        "custom-user-agent",
+		MaxRetryAttempts: &attempts,
+		ApiBaseUrl:       "http://localhost:8081/",
+		SearchBaseUrl:    "http://localhost:8082",
+	})
+
+	assert.Equal(t, "my-auth-token", c.AuthToken)
+	assert.Equal(t, "custom-user-agent", c.UserAgent)
+	assert.Equal(t, uint(0), c.MaxRetryAttempts)
+	assert.Equal(t, "http://localhost:8081", c.ApiBaseUrl)
+	assert.Equal(t, "http://localhost:8082", c.SearchBaseUrl)
+
+	c = NewClientWithConfig(config.ClientConfig{})
+
+	assert.Equal(t, NewClient().AuthToken, c.AuthToken)
+	assert.Equal(t, "squawks/"+config.Version, c.UserAgent)
+	assert.Equal(t, uint(3), c.MaxRetryAttempts)
+	assert.Equal(t, DefaultApiBaseUrl, c.ApiBaseUrl)
+	assert.Equal(t, DefaultSearchBaseUrl, c.SearchBaseUrl)
+}
+
 func TestRequest(t *testing.T) {
 	c := NewClient()
 
@@ -103,3 +130,60 @@ func TestGetGuestToken(t *testing.T) {
 		})
 	}
 }
+
+func TestLogResponse(t *testing.T) {
+	examples := map[string]struct {
+		statusCode int
+		response   string
+		level      logging.Level
+		expected   []string
+	}{
+		"success": {
+			statusCode: 200,
+			response:   `{ "guest_token": "deadbeef" }`,
+			level:      logging.LevelDebug,
+			expected: []string{
+				`"level":"DEBUG"`,
+				`"msg":"http request","method":"POST","endpoint":"api.twitter.com/1.1/guest/activate.json","status":200`,
+				`"x-rate-limit-remaining":"179"`,
+				`"msg":"activated guest token"`,
+			},
+		},
+		"failure": {
+			statusCode: 429,
+			response:   `{ "errors": [{ "code": 88, "message": "Rate limit exceeded" }] }`,
+			level:      logging.LevelWarn,
+			expected: []string{
+				`"level":"WARN"`,
+				`"msg":"http request","method":"POST","endpoint":"api.twitter.com/1.1/guest/activate.json","status":429`,
+				`"x-rate-limit-remaining":"179"`,
+			},
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			c := NewClient()
+			c.Logger = logging.New(&buf, e.level, logging.FormatJson)
+
+			httpmock.ActivateNonDefault(c.Client.GetClient())
+			defer httpmock.DeactivateAndReset()
+
+			url := "https://api.twitter.com/1.1/guest/activate.json"
+			httpmock.RegisterResponder("POST", url, func(req *http.Request) (*http.Response, error) {
+				res := httpmock.NewStringResponse(e.statusCode, e.response)
+				res.Header.Add("Content-Type", "application/json")
+				res.Header.Add("x-rate-limit-remaining", "179")
+				return res, nil
+			})
+
+			c.GetGuestToken()
+
+			for _, expected := range e.expected {
+				assert.Contains(t, buf.String(), expected)
+			}
+		})
+	}
+}
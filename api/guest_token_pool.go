@@ -0,0 +1,70 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"sync"
+)
+
+// GuestTokenPool shares up to Size guest tokens between clients searching concurrently.
+// Tokens are handed out in turn and a new one is activated when a token is invalidated.
+type GuestTokenPool struct {
+	Size  int
+	fetch func() (string, error)
+
+	mu     sync.Mutex
+	tokens []string
+	next   int
+}
+
+func NewGuestTokenPool(c *Client, size int) *GuestTokenPool {
+	if size < 1 {
+		size = 1
+	}
+
+	return &GuestTokenPool{Size: size, fetch: c.GetGuestToken}
+}
+
+func (p *GuestTokenPool) Get() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.tokens) < p.Size {
+		token, err := p.fetch()
+		if err != nil {
+			return "", err
+		}
+
+		p.tokens = append(p.tokens, token)
+		return token, nil
+	}
+
+	token := p.tokens[p.next%len(p.tokens)]
+	p.next++
+	return token, nil
+}
+
+// Invalidate removes a token that was rejected so that it is not handed out again.
+func (p *GuestTokenPool) Invalidate(token string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, t := range p.tokens {
+		if t == token {
+			p.tokens = append(p.tokens[:i], p.tokens[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build small
+// +build small
+
+package api
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGuestTokenPoolGet(t *testing.T) {
+	n := 0
+	p := &GuestTokenPool{Size: 2, fetch: func() (string, error) {
+		n++
+		return strconv.Itoa(n), nil
+	}}
+
+	for _, expected := range []string{"1", "2", "1", "2", "1"} {
+		actual, err := p.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	}
+
+	p.Invalidate("1")
+
+	for _, expected := range []string{"3", "3", "2"} {
+		actual, err := p.Get()
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	}
+
+	assert.Equal(t, 3, n)
+}
+
+func TestGuestTokenPoolGetWhenFetchFails(t *testing.T) {
+	p := &GuestTokenPool{Size: 1, fetch: func() (string, error) {
+		return "", errors.New("forbidden")
+	}}
+
+	actual, err := p.Get()
+	assert.EqualError(t, err, "forbidden")
+	assert.Equal(t, "", actual)
+	assert.Empty(t, p.tokens)
+}
+
+func TestSearchAllWithGuestTokenPool(t *testing.T) {
+	c := NewClient()
+	c.GuestTokenPool = NewGuestTokenPool(c, 1)
+
+	httpmock.ActivateNonDefault(c.Client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	url1 := "https://api.twitter.com/1.1/guest/activate.json"
+	httpmock.RegisterResponder("POST", url1, NewJsonResponse(200, `{ "guest_token": "1234" }`))
+
+	url2 := "https://twitter.com/i/api/2/search/adaptive.json?count=40&include_quote_count=true&include_reply_count=1&q=foo&query_source=typed_query&tweet_mode=extended&tweet_search_mode=live"
+	httpmock.RegisterResponder("GET", url2, NewJsonResponse(200, `{}`))
+
+	for i := 0; i < 3; i++ {
+		for res := range c.SearchAll(SearchOptions{Query: Query{Text: "foo"}}) {
+			assert.NoError(t, res.Error)
+		}
+	}
+
+	info := httpmock.GetCallCountInfo()
+	assert.Equal(t, 1, info["POST "+url1])
+	assert.Equal(t, 3, info["GET "+url2])
+}
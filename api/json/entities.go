@@ -0,0 +1,44 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package json
+
+// https://developer.twitter.com/en/docs/twitter-api/v1/data-dictionary/object-model/entities
+
+type Indices [2]int
+
+type Hashtag struct {
+	Text    string  `json:"text"`
+	Indices Indices `json:"indices"`
+}
+
+type UserMention struct {
+	Id         uint64  `json:"id"`
+	ScreenName string  `json:"screen_name"`
+	Name       string  `json:"name"`
+	Indices    Indices `json:"indices"`
+}
+
+type Url struct {
+	Url         string  `json:"url"`
+	ExpandedUrl string  `json:"expanded_url"`
+	DisplayUrl  string  `json:"display_url"`
+	Indices     Indices `json:"indices"`
+}
+
+type Entities struct {
+	Hashtags     []Hashtag     `json:"hashtags"`
+	UserMentions []UserMention `json:"user_mentions"`
+	Urls         []Url         `json:"urls"`
+}
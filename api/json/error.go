@@ -36,3 +36,15 @@ func (res *ErrorResponse) Error() string {
 
 	return strings.TrimRight(message, "\n")
 }
+
+const RateLimitExceededCode = 88
+
+func (res *ErrorResponse) IsRateLimited() bool {
+	for _, e := range res.Errors {
+		if e.Code == RateLimitExceededCode {
+			return true
+		}
+	}
+
+	return false
+}
@@ -34,3 +34,30 @@ func TestError(t *testing.T) {
 	expected := "200: forbidden\n400: something went wrong"
 	assert.EqualError(t, actual, expected)
 }
+
+func TestIsRateLimited(t *testing.T) {
+	examples := map[string]struct {
+		errors   []Error
+		expected bool
+	}{
+		"rate-limited": {
+			errors:   []Error{Error{Code: 88, Message: "Rate limit exceeded"}},
+			expected: true,
+		},
+		"other": {
+			errors:   []Error{Error{Code: 200, Message: "forbidden"}},
+			expected: false,
+		},
+		"empty": {
+			errors:   []Error{},
+			expected: false,
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			res := &ErrorResponse{Errors: e.errors}
+			assert.Equal(t, e.expected, res.IsRateLimited())
+		})
+	}
+}
@@ -73,3 +73,40 @@ type Place struct {
 	Country     string      `json:"country"`
 	BoundingBox BoundingBox `json:"bounding_box"`
 }
+
+// Ring returns the outer ring of the bounding box, closed so that its last
+// point is its first one, or nil if the box is empty.
+func (b BoundingBox) Ring() []LongLat {
+	if len(b.Coordinates) == 0 || len(b.Coordinates[0]) == 0 {
+		return nil
+	}
+
+	ring := append([]LongLat{}, b.Coordinates[0]...)
+	if ring[0] != ring[len(ring)-1] {
+		ring = append(ring, ring[0])
+	}
+
+	return ring
+}
+
+// Centroid returns the mean of the corners of the bounding box, or false if
+// the box is empty.
+func (b BoundingBox) Centroid() (LongLat, bool) {
+	ring := b.Ring()
+	if len(ring) == 0 {
+		return LongLat{}, false
+	}
+
+	if len(ring) > 1 {
+		ring = ring[:len(ring)-1]
+	}
+
+	var c LongLat
+	for _, p := range ring {
+		c[0] += p[0]
+		c[1] += p[1]
+	}
+
+	n := float64(len(ring))
+	return LongLat{c[0] / n, c[1] / n}, true
+}
@@ -68,3 +68,46 @@ func TestLongLatString(t *testing.T) {
 		})
 	}
 }
+
+func TestBoundingBoxCentroid(t *testing.T) {
+	examples := map[string]struct {
+		box              BoundingBox
+		expected         LongLat
+		expectedRing     []LongLat
+		expectedCentroid bool
+	}{
+		"box": {
+			box:              BoundingBox{Type: "Polygon", Coordinates: [][]LongLat{{{139, 35}, {140, 35}, {140, 36}, {139, 36}}}},
+			expected:         LongLat{139.5, 35.5},
+			expectedRing:     []LongLat{{139, 35}, {140, 35}, {140, 36}, {139, 36}, {139, 35}},
+			expectedCentroid: true,
+		},
+		"closed": {
+			box:              BoundingBox{Type: "Polygon", Coordinates: [][]LongLat{{{0, 0}, {2, 0}, {2, 2}, {0, 2}, {0, 0}}}},
+			expected:         LongLat{1, 1},
+			expectedRing:     []LongLat{{0, 0}, {2, 0}, {2, 2}, {0, 2}, {0, 0}},
+			expectedCentroid: true,
+		},
+		"point": {
+			box:              BoundingBox{Type: "Polygon", Coordinates: [][]LongLat{{{139, 35}}}},
+			expected:         LongLat{139, 35},
+			expectedRing:     []LongLat{{139, 35}},
+			expectedCentroid: true,
+		},
+		"empty": {
+			box:              BoundingBox{},
+			expected:         LongLat{},
+			expectedRing:     nil,
+			expectedCentroid: false,
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			actual, ok := e.box.Centroid()
+			assert.Equal(t, e.expectedCentroid, ok)
+			assert.Equal(t, e.expected, actual)
+			assert.Equal(t, e.expectedRing, e.box.Ring())
+		})
+	}
+}
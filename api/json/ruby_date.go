@@ -16,6 +16,7 @@ package json
 
 import (
 	"bytes"
+	"encoding/json"
 	"time"
 )
 
@@ -29,11 +30,27 @@ func (t *RubyDate) Equal(u RubyDate) bool {
 	return time.Time(*t).Equal(time.Time(u))
 }
 
+func (t RubyDate) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// MarshalJSON encodes t in the format it is read from.
+func (t RubyDate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
 func (t *RubyDate) UnmarshalJSON(buf []byte) error {
 	s := bytes.Trim(buf, `"`)
 	parsed, err := time.ParseInLocation(time.RubyDate, string(s), time.UTC)
 	if err != nil {
-		return err
+		// Timestamps written by other tools are accepted in RFC 3339 as well.
+		var rfc3339Err error
+		parsed, rfc3339Err = time.Parse(time.RFC3339, string(s))
+		if rfc3339Err != nil {
+			return err
+		}
+
+		parsed = parsed.UTC()
 	}
 
 	*t = RubyDate(parsed)
@@ -73,6 +73,11 @@ func TestUnmarshall(t *testing.T) {
 			expected:    RubyDate(time.Date(2013, 8, 19, 2, 4, 28, 0, time.UTC)),
 			expectError: false,
 		},
+		"rfc3339": {
+			jsonString:  `{ "created_at": "2013-08-19T11:04:28+09:00" }`,
+			expected:    RubyDate(time.Date(2013, 8, 19, 2, 4, 28, 0, time.UTC)),
+			expectError: false,
+		},
 		"failure": {
 			jsonString:  `{ "created_at": "2013-01-08-19T02:04:28+00:00" }`,
 			expected:    RubyDate(time.Time{}),
@@ -95,3 +100,18 @@ func TestUnmarshall(t *testing.T) {
 		})
 	}
 }
+
+func TestMarshal(t *testing.T) {
+	schema := TestSchema{CreatedAt: RubyDate(time.Date(2013, 8, 19, 2, 4, 28, 0, time.UTC))}
+	actual, err := json.Marshal(schema)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"created_at":"Mon Aug 19 02:04:28 +0000 2013"}`, string(actual))
+
+	var decoded TestSchema
+	assert.NoError(t, json.Unmarshal(actual, &decoded))
+	assert.True(t, decoded.CreatedAt.Equal(schema.CreatedAt))
+
+	text, err := schema.CreatedAt.MarshalText()
+	assert.NoError(t, err)
+	assert.Equal(t, "Mon Aug 19 02:04:28 +0000 2013", string(text))
+}
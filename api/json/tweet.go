@@ -27,6 +27,7 @@ type Tweet struct {
 	Geo           *Geo         `json:"geo"` // deprecated
 	Coordinates   *Coordinates `json:"coordinates"`
 	Place         *Place       `json:"place"`
+	Entities      Entities     `json:"entities"`
 	Lang          string       `json:"lang"`
 	Source        string       `json:"source"`
 	CreatedAt     RubyDate     `json:"created_at"`
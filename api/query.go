@@ -15,6 +15,7 @@
 package api
 
 import (
+	"strconv"
 	"strings"
 )
 
@@ -32,6 +33,7 @@ type Query struct {
 	Near     string
 	Within   string
 	Url      string
+	SinceId  uint64
 }
 
 func (q *Query) Encode() string {
@@ -89,6 +91,10 @@ func (q *Query) Encode() string {
 		ss = append(ss, "url:"+q.Url)
 	}
 
+	if q.SinceId != 0 {
+		ss = append(ss, "since_id:"+strconv.FormatUint(q.SinceId, 10))
+	}
+
 	return strings.Join(ss[:], " ")
 }
 
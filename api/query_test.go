@@ -38,6 +38,7 @@ func TestEncode(t *testing.T) {
 		near     string
 		within   string
 		url      string
+		sinceId  uint64
 		expected string
 	}{
 		"none": {
@@ -54,6 +55,7 @@ func TestEncode(t *testing.T) {
 			near:     "",
 			within:   "",
 			url:      "",
+			sinceId:  0,
 			expected: "",
 		},
 		"all": {
@@ -70,7 +72,8 @@ func TestEncode(t *testing.T) {
 			near:     "tokyo",
 			within:   "0.1km",
 			url:      "www.example.com",
-			expected: "foo bar since:2020-09-06 until:2020-09-07 from:foo to:bar lang:ja filter:verified filter:links include:retweets include:nativeretweets exclude:replies exclude:hashtags geocode:35.6851508,139.7526768,0.1km near:tokyo within:0.1km url:www.example.com",
+			sinceId:  1000,
+			expected: "foo bar since:2020-09-06 until:2020-09-07 from:foo to:bar lang:ja filter:verified filter:links include:retweets include:nativeretweets exclude:replies exclude:hashtags geocode:35.6851508,139.7526768,0.1km near:tokyo within:0.1km url:www.example.com since_id:1000",
 		},
 	}
 
@@ -90,6 +93,7 @@ func TestEncode(t *testing.T) {
 				Near:     e.near,
 				Within:   e.within,
 				Url:      e.url,
+				SinceId:  e.sinceId,
 			}
 
 			actual := q.Encode()
@@ -113,6 +117,7 @@ func TestIsEmpty(t *testing.T) {
 		near     string
 		within   string
 		url      string
+		sinceId  uint64
 		expected bool
 	}{
 		"none": {
@@ -129,6 +134,7 @@ func TestIsEmpty(t *testing.T) {
 			near:     "",
 			within:   "",
 			url:      "",
+			sinceId:  0,
 			expected: true,
 		},
 		"all": {
@@ -145,6 +151,7 @@ func TestIsEmpty(t *testing.T) {
 			near:     "tokyo",
 			within:   "0.1km",
 			url:      "www.example.com",
+			sinceId:  1000,
 			expected: false,
 		},
 	}
@@ -165,6 +172,7 @@ func TestIsEmpty(t *testing.T) {
 				Near:     e.near,
 				Within:   e.within,
 				Url:      e.url,
+				SinceId:  e.sinceId,
 			}
 
 			actual := q.IsEmpty()
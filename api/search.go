@@ -15,25 +15,68 @@
 package api
 
 import (
+	"context"
 	"fmt"
+	"strconv"
+	"sync/atomic"
 
 	"github.com/akiomik/squawks/api/json"
 )
 
+type SearchMode string
+
+const (
+	SearchModeLatest SearchMode = "latest"
+	SearchModeTop    SearchMode = "top"
+	SearchModePeople SearchMode = "people"
+	SearchModePhotos SearchMode = "photos"
+	SearchModeVideos SearchMode = "videos"
+)
+
+const DefaultPageSize = 40
+
+// Params returns the request parameters selecting the search mode.
+func (m SearchMode) Params() (map[string]string, error) {
+	switch m {
+	case "", SearchModeLatest:
+		return map[string]string{"tweet_search_mode": "live"}, nil
+	case SearchModeTop:
+		return map[string]string{}, nil
+	case SearchModePeople:
+		return map[string]string{"result_filter": "user"}, nil
+	case SearchModePhotos:
+		return map[string]string{"result_filter": "image"}, nil
+	case SearchModeVideos:
+		return map[string]string{"result_filter": "video"}, nil
+	default:
+		return nil, fmt.Errorf("unknown search mode: %s", m)
+	}
+}
+
 type SearchOptions struct {
 	GuestToken string
 	Cursor     string
 	Query      Query
-	Top        bool
+	Mode       SearchMode
+	PageSize   uint
+	// MaxPages limits the number of pages fetched by SearchAll. Zero means no limit.
+	MaxPages uint
+	// Params adds or overrides request parameters. An empty value removes the parameter.
+	Params map[string]string
 }
 
-func (c *Client) Search(opts *SearchOptions) (*json.Adaptive, error) {
+func (opts *SearchOptions) RequestParams() (map[string]string, error) {
+	pageSize := opts.PageSize
+	if pageSize == 0 {
+		pageSize = DefaultPageSize
+	}
+
 	params := map[string]string{
 		"q":                   opts.Query.Encode(),
 		"include_quote_count": "true",
 		"include_reply_count": "1",
 		"tweet_mode":          "extended",
-		"count":               "40",
+		"count":               strconv.FormatUint(uint64(pageSize), 10),
 		"query_source":        "typed_query",
 	}
 
@@ -41,21 +84,59 @@ func (c *Client) Search(opts *SearchOptions) (*json.Adaptive, error) {
 		params["cursor"] = opts.Cursor
 	}
 
-	if !opts.Top {
-		params["tweet_search_mode"] = "live"
+	modeParams, err := opts.Mode.Params()
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range modeParams {
+		params[k] = v
+	}
+
+	for k, v := range opts.Params {
+		if len(v) == 0 {
+			delete(params, k)
+		} else {
+			params[k] = v
+		}
+	}
+
+	return params, nil
+}
+
+func (c *Client) Search(opts *SearchOptions) (*json.Adaptive, error) {
+	params, err := opts.RequestParams()
+	if err != nil {
+		return nil, err
 	}
 
+	atomic.AddUint64(&c.stats.Requests, 1)
 	res, err := c.Request().
 		SetResult(json.Adaptive{}).
 		SetError(json.ErrorResponse{}).
 		SetHeader("x-guest-token", opts.GuestToken).
 		SetQueryParams(params).
-		Get("https://twitter.com/i/api/2/search/adaptive.json")
+		Get(c.SearchBaseUrl + "/i/api/2/search/adaptive.json")
 
 	if err != nil {
 		return nil, err
 	}
 
+	// Error responses are archived too, so that failed searches can be debugged.
+	if c.Archive != nil {
+		e := ArchiveEntry{
+			Url:         res.Request.URL,
+			Cursor:      opts.Cursor,
+			Params:      params,
+			RequestedAt: res.Request.Time.UTC(),
+			Status:      res.StatusCode(),
+		}
+
+		if err := c.Archive.Save(e, res.Body()); err != nil {
+			return nil, fmt.Errorf("failed to archive response: %w", err)
+		}
+	}
+
 	if res.IsError() {
 		return nil, res.Error().(*json.ErrorResponse)
 	}
@@ -66,23 +147,41 @@ func (c *Client) Search(opts *SearchOptions) (*json.Adaptive, error) {
 type SearchResult struct {
 	Adaptive *json.Adaptive
 	Error    error
+	// GuestToken is the guest token the page was fetched with, which may have
+	// been refreshed during the search.
+	GuestToken string
 }
 
 func (c *Client) SearchAll(opts SearchOptions) <-chan *SearchResult {
+	return c.SearchAllContext(context.Background(), opts)
+}
+
+// SearchAllContext is SearchAll that stops searching once ctx is done.
+func (c *Client) SearchAllContext(ctx context.Context, opts SearchOptions) <-chan *SearchResult {
 	ch := make(chan *SearchResult)
 
 	go func() {
 		defer close(ch)
 
+		send := func(res *SearchResult) bool {
+			select {
+			case ch <- res:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
 		cursor := opts.Cursor
 		guestToken := opts.GuestToken
 		attempts := uint(0)
+		pages := uint(0)
 
 		for {
 			if guestToken == "" {
-				newGuestToken, err := c.GetGuestToken()
+				newGuestToken, err := c.guestToken()
 				if err != nil {
-					ch <- &SearchResult{nil, fmt.Errorf("failed to get guest token: %w", err)}
+					send(&SearchResult{Error: fmt.Errorf("failed to get guest token: %w", err)})
 					break
 				}
 
@@ -98,27 +197,41 @@ func (c *Client) SearchAll(opts SearchOptions) <-chan *SearchResult {
 				_, ok := err.(*json.ErrorResponse)
 				if ok && c.MaxRetryAttempts != 0 {
 					if attempts >= c.MaxRetryAttempts {
-						ch <- &SearchResult{nil, fmt.Errorf("retry limit exceeded: %w", err)}
+						send(&SearchResult{Error: fmt.Errorf("retry limit exceeded: %w", err)})
 						break
 					}
 
+					if c.GuestTokenPool != nil {
+						c.GuestTokenPool.Invalidate(guestToken)
+					}
+
 					guestToken = ""
 					attempts++
+					atomic.AddUint64(&c.stats.Retries, 1)
+					c.Logger.Warn("retrying search", "attempt", attempts, "max_attempts", c.MaxRetryAttempts, "error", err)
 					continue
 				} else {
-					ch <- &SearchResult{nil, fmt.Errorf("failed to search: %w", err)}
+					send(&SearchResult{Error: fmt.Errorf("failed to search: %w", err)})
 					break
 				}
 			}
 
-			ch <- &SearchResult{res, nil}
+			if !send(&SearchResult{Adaptive: res, GuestToken: guestToken}) {
+				break
+			}
+
 			if len(res.GlobalObjects.Tweets) == 0 {
 				break
 			}
 
+			pages++
+			if opts.MaxPages != 0 && pages >= opts.MaxPages {
+				break
+			}
+
 			cursor, err = res.FindCursor()
 			if err != nil {
-				ch <- &SearchResult{nil, fmt.Errorf("failed to find cursor: %w", err)}
+				send(&SearchResult{Error: fmt.Errorf("failed to find cursor: %w", err)})
 				break
 			}
 		}
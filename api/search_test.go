@@ -18,6 +18,7 @@
 package api
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -75,13 +76,37 @@ func TestSearch(t *testing.T) {
 			expectError: true,
 		},
 		"top": {
-			opts:        &SearchOptions{Query: Query{Text: "foo"}, Top: true},
+			opts:        &SearchOptions{Query: Query{Text: "foo"}, Mode: SearchModeTop},
 			url:         "https://twitter.com/i/api/2/search/adaptive.json?count=40&include_quote_count=true&include_reply_count=1&q=foo&query_source=typed_query&tweet_mode=extended",
 			statusCode:  200,
 			response:    `{ "globalObjects": { "tweets": {}, "users": {} } }`,
 			expected:    &json.Adaptive{GlobalObjects: json.GlobalObjects{Tweets: map[string]json.Tweet{}, Users: map[string]json.User{}}},
 			expectError: false,
 		},
+		"photos": {
+			opts:        &SearchOptions{Query: Query{Text: "foo"}, Mode: SearchModePhotos},
+			url:         "https://twitter.com/i/api/2/search/adaptive.json?count=40&include_quote_count=true&include_reply_count=1&q=foo&query_source=typed_query&result_filter=image&tweet_mode=extended",
+			statusCode:  200,
+			response:    `{ "globalObjects": { "tweets": {}, "users": {} } }`,
+			expected:    &json.Adaptive{GlobalObjects: json.GlobalObjects{Tweets: map[string]json.Tweet{}, Users: map[string]json.User{}}},
+			expectError: false,
+		},
+		"page-size": {
+			opts:        &SearchOptions{Query: Query{Text: "foo"}, PageSize: 100},
+			url:         "https://twitter.com/i/api/2/search/adaptive.json?count=100&include_quote_count=true&include_reply_count=1&q=foo&query_source=typed_query&tweet_mode=extended&tweet_search_mode=live",
+			statusCode:  200,
+			response:    `{ "globalObjects": { "tweets": {}, "users": {} } }`,
+			expected:    &json.Adaptive{GlobalObjects: json.GlobalObjects{Tweets: map[string]json.Tweet{}, Users: map[string]json.User{}}},
+			expectError: false,
+		},
+		"params": {
+			opts:        &SearchOptions{Query: Query{Text: "foo"}, Params: map[string]string{"query_source": "", "include_reply_count": "0", "spelling_corrections": "1"}},
+			url:         "https://twitter.com/i/api/2/search/adaptive.json?count=40&include_quote_count=true&include_reply_count=0&q=foo&spelling_corrections=1&tweet_mode=extended&tweet_search_mode=live",
+			statusCode:  200,
+			response:    `{ "globalObjects": { "tweets": {}, "users": {} } }`,
+			expected:    &json.Adaptive{GlobalObjects: json.GlobalObjects{Tweets: map[string]json.Tweet{}, Users: map[string]json.User{}}},
+			expectError: false,
+		},
 	}
 
 	for name, e := range examples {
@@ -110,6 +135,63 @@ func TestSearch(t *testing.T) {
 	}
 }
 
+func TestSearchModeParams(t *testing.T) {
+	examples := map[string]struct {
+		mode        SearchMode
+		expected    map[string]string
+		expectError bool
+	}{
+		"default": {
+			mode:        "",
+			expected:    map[string]string{"tweet_search_mode": "live"},
+			expectError: false,
+		},
+		"latest": {
+			mode:        SearchModeLatest,
+			expected:    map[string]string{"tweet_search_mode": "live"},
+			expectError: false,
+		},
+		"top": {
+			mode:        SearchModeTop,
+			expected:    map[string]string{},
+			expectError: false,
+		},
+		"people": {
+			mode:        SearchModePeople,
+			expected:    map[string]string{"result_filter": "user"},
+			expectError: false,
+		},
+		"photos": {
+			mode:        SearchModePhotos,
+			expected:    map[string]string{"result_filter": "image"},
+			expectError: false,
+		},
+		"videos": {
+			mode:        SearchModeVideos,
+			expected:    map[string]string{"result_filter": "video"},
+			expectError: false,
+		},
+		"unknown": {
+			mode:        SearchMode("foo"),
+			expected:    nil,
+			expectError: true,
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			actual, err := e.mode.Params()
+			if e.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, e.expected, actual)
+		})
+	}
+}
+
 func TestSearchAll(t *testing.T) {
 	examples := map[string]struct {
 		maxRetryAttempts      uint
@@ -120,6 +202,7 @@ func TestSearchAll(t *testing.T) {
 		expectedResults       []*SearchResult
 		expectedActivateCount int
 		expectedAdaptiveCount int
+		expectedRetries       uint64
 	}{
 		"empty-tweets": {
 			maxRetryAttempts:   uint(3),
@@ -128,11 +211,12 @@ func TestSearchAll(t *testing.T) {
 			adaptiveStatsCode:  200,
 			adaptiveResponse:   `{}`,
 			expectedResults: []*SearchResult{
-				&SearchResult{&json.Adaptive{}, nil},
+				&SearchResult{Adaptive: &json.Adaptive{}},
 				nil,
 			},
 			expectedActivateCount: 1,
 			expectedAdaptiveCount: 1,
+			expectedRetries:       0,
 		},
 		"failed-get-guest-token": {
 			maxRetryAttempts:   uint(3),
@@ -141,11 +225,12 @@ func TestSearchAll(t *testing.T) {
 			adaptiveStatsCode:  200,
 			adaptiveResponse:   `{}`,
 			expectedResults: []*SearchResult{
-				&SearchResult{nil, errors.New("failed to get guest token: 200: forbidden")},
+				&SearchResult{Error: errors.New("failed to get guest token: 200: forbidden")},
 				nil,
 			},
 			expectedActivateCount: 1,
 			expectedAdaptiveCount: 0,
+			expectedRetries:       0,
 		},
 		"retry-limit-exceeded": {
 			maxRetryAttempts:   uint(3),
@@ -154,11 +239,12 @@ func TestSearchAll(t *testing.T) {
 			adaptiveStatsCode:  403,
 			adaptiveResponse:   `{ "errors": [{ "code": 200, "message": "forbidden" }] }`,
 			expectedResults: []*SearchResult{
-				&SearchResult{nil, errors.New("retry limit exceeded: 200: forbidden")},
+				&SearchResult{Error: errors.New("retry limit exceeded: 200: forbidden")},
 				nil,
 			},
 			expectedActivateCount: 4,
 			expectedAdaptiveCount: 4,
+			expectedRetries:       3,
 		},
 		"no-retries": {
 			maxRetryAttempts:   uint(0),
@@ -167,11 +253,12 @@ func TestSearchAll(t *testing.T) {
 			adaptiveStatsCode:  403,
 			adaptiveResponse:   `{ "errors": [{ "code": 200, "message": "forbidden" }] }`,
 			expectedResults: []*SearchResult{
-				&SearchResult{nil, errors.New("failed to search: 200: forbidden")},
+				&SearchResult{Error: errors.New("failed to search: 200: forbidden")},
 				nil,
 			},
 			expectedActivateCount: 1,
 			expectedAdaptiveCount: 1,
+			expectedRetries:       0,
 		},
 	}
 
@@ -203,6 +290,13 @@ func TestSearchAll(t *testing.T) {
 
 			assert.Equal(t, e.expectedActivateCount, info["POST "+url1])
 			assert.Equal(t, e.expectedAdaptiveCount, info["GET "+url2])
+
+			expectedStats := Stats{
+				Requests:    uint64(e.expectedActivateCount + e.expectedAdaptiveCount),
+				Retries:     e.expectedRetries,
+				GuestTokens: uint64(e.expectedActivateCount),
+			}
+			assert.Equal(t, expectedStats, c.Stats())
 		})
 	}
 }
@@ -303,3 +397,42 @@ func TestSearchAllWhenRestTweetsExist(t *testing.T) {
 	assert.Equal(t, 1, info["GET "+url2])
 	assert.Equal(t, 1, info["GET "+url3])
 }
+
+func TestSearchAllContextWhenCanceled(t *testing.T) {
+	c := NewClient()
+
+	httpmock.ActivateNonDefault(c.Client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	url1 := "https://api.twitter.com/1.1/guest/activate.json"
+	httpmock.RegisterResponder("POST", url1, NewJsonResponse(200, `{ "guest_token": "1234" }`))
+
+	// every page has a next page
+	url2 := `=~^https://twitter\.com/i/api/2/search/adaptive\.json`
+	res2 := `{
+    "globalObjects": { "tweets": { "1": { "id": 1 } } },
+    "timeline": {
+      "instructions": [{
+        "addEntries": {
+          "entries": [{
+            "entryId": "sq-cursor-bottom",
+            "content": { "operation": { "cursor": { "value": "scroll:deadbeef" } } }
+          }]
+        }
+      }]
+    }
+  }`
+	httpmock.RegisterResponder("GET", url2, NewJsonResponse(200, res2))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := c.SearchAllContext(ctx, SearchOptions{Query: Query{Text: "foo"}})
+
+	actual1 := <-ch
+	assert.NoError(t, actual1.Error)
+
+	cancel()
+	for range ch {
+	}
+
+	assert.LessOrEqual(t, httpmock.GetCallCountInfo()["GET "+url2], 3)
+}
@@ -0,0 +1,194 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/akiomik/squawks/api/json"
+)
+
+const (
+	DefaultWatchInterval    = 1 * time.Minute
+	DefaultWatchMinInterval = 10 * time.Second
+	DefaultWatchMaxInterval = 15 * time.Minute
+)
+
+type WatchOptions struct {
+	SearchOptions SearchOptions
+	// Interval is the initial poll interval.
+	Interval    time.Duration
+	MinInterval time.Duration
+	MaxInterval time.Duration
+}
+
+// NextInterval returns the poll interval following a poll that found n new tweets.
+// The interval is halved when a poll returns at least a full page, doubled when it
+// returns nothing, and set to the maximum when the poll was rate limited.
+func (opts *WatchOptions) NextInterval(current time.Duration, n int, rateLimited bool) time.Duration {
+	if rateLimited {
+		return opts.MaxInterval
+	}
+
+	pageSize := opts.SearchOptions.PageSize
+	if pageSize == 0 {
+		pageSize = DefaultPageSize
+	}
+
+	next := current
+	if n == 0 {
+		next = current * 2
+	} else if n >= int(pageSize) {
+		next = current / 2
+	}
+
+	if next < opts.MinInterval {
+		return opts.MinInterval
+	}
+
+	if next > opts.MaxInterval {
+		return opts.MaxInterval
+	}
+
+	return next
+}
+
+func MaxTweetId(j *json.Adaptive) uint64 {
+	max := uint64(0)
+	for _, t := range j.GlobalObjects.Tweets {
+		if t.Id > max {
+			max = t.Id
+		}
+	}
+
+	return max
+}
+
+func IsRateLimited(err error) bool {
+	var res *json.ErrorResponse
+	return errors.As(err, &res) && res.IsRateLimited()
+}
+
+// Watch polls the latest search results until ctx is done and sends pages containing
+// tweets newer than the ones already seen. The first poll fetches a single page to
+// find the most recent tweet id; later polls fetch every page newer than it.
+// Errors are sent to the channel and polling continues.
+func (c *Client) Watch(ctx context.Context, opts WatchOptions) <-chan *SearchResult {
+	ch := make(chan *SearchResult)
+
+	if opts.Interval == 0 {
+		opts.Interval = DefaultWatchInterval
+	}
+
+	if opts.MinInterval == 0 {
+		opts.MinInterval = DefaultWatchMinInterval
+	}
+
+	if opts.MaxInterval == 0 {
+		opts.MaxInterval = DefaultWatchMaxInterval
+	}
+
+	go func() {
+		defer close(ch)
+
+		searchOpts := opts.SearchOptions
+		searchOpts.Mode = SearchModeLatest
+		searchOpts.Cursor = ""
+		interval := opts.Interval
+
+		for {
+			n, rateLimited, ok := c.poll(ctx, ch, &searchOpts, opts.SearchOptions.MaxPages)
+			if !ok {
+				return
+			}
+
+			interval = opts.NextInterval(interval, n, rateLimited)
+
+			select {
+			case <-time.After(interval):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch
+}
+
+// poll runs a single search newer than opts.Query.SinceId and advances it to the
+// highest tweet id found once every page was fetched, so that a failed poll is
+// retried from the same id. The guest token of the search, which may have been
+// refreshed, is kept for the next poll. It returns the number of tweets found, whether the
+// search was rate limited, and false if ctx was done before all results were sent.
+func (c *Client) poll(ctx context.Context, ch chan<- *SearchResult, opts *SearchOptions, maxPages uint) (int, bool, bool) {
+	if opts.GuestToken == "" {
+		guestToken, err := c.guestToken()
+		if err != nil {
+			select {
+			case ch <- &SearchResult{Error: fmt.Errorf("failed to get guest token: %w", err)}:
+				return 0, IsRateLimited(err), true
+			case <-ctx.Done():
+				return 0, false, false
+			}
+		}
+
+		opts.GuestToken = guestToken
+	}
+
+	if opts.Query.SinceId == 0 {
+		opts.MaxPages = 1
+	} else {
+		opts.MaxPages = maxPages
+	}
+
+	n := 0
+	rateLimited := false
+	failed := false
+	sinceId := opts.Query.SinceId
+	results := c.SearchAll(*opts)
+	for res := range results {
+		if res.Error != nil {
+			failed = true
+			rateLimited = rateLimited || IsRateLimited(res.Error)
+			opts.GuestToken = ""
+		} else {
+			opts.GuestToken = res.GuestToken
+			n += len(res.Adaptive.GlobalObjects.Tweets)
+			if id := MaxTweetId(res.Adaptive); id > sinceId {
+				sinceId = id
+			}
+		}
+
+		select {
+		case ch <- res:
+		case <-ctx.Done():
+			go func() {
+				for range results {
+				}
+			}()
+
+			return n, rateLimited, false
+		}
+	}
+
+	if !failed {
+		opts.Query.SinceId = sinceId
+	}
+
+	return n, rateLimited, true
+}
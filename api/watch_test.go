@@ -0,0 +1,340 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build small
+// +build small
+
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/akiomik/squawks/api/json"
+)
+
+func TestNextInterval(t *testing.T) {
+	examples := map[string]struct {
+		current     time.Duration
+		n           int
+		rateLimited bool
+		expected    time.Duration
+	}{
+		"no-tweets": {
+			current:     time.Minute,
+			n:           0,
+			rateLimited: false,
+			expected:    2 * time.Minute,
+		},
+		"no-tweets-max": {
+			current:     8 * time.Minute,
+			n:           0,
+			rateLimited: false,
+			expected:    10 * time.Minute,
+		},
+		"some-tweets": {
+			current:     time.Minute,
+			n:           10,
+			rateLimited: false,
+			expected:    time.Minute,
+		},
+		"full-page": {
+			current:     time.Minute,
+			n:           40,
+			rateLimited: false,
+			expected:    30 * time.Second,
+		},
+		"full-page-min": {
+			current:     15 * time.Second,
+			n:           80,
+			rateLimited: false,
+			expected:    10 * time.Second,
+		},
+		"rate-limited": {
+			current:     time.Minute,
+			n:           40,
+			rateLimited: true,
+			expected:    10 * time.Minute,
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			opts := WatchOptions{MinInterval: 10 * time.Second, MaxInterval: 10 * time.Minute}
+			actual := opts.NextInterval(e.current, e.n, e.rateLimited)
+			assert.Equal(t, e.expected, actual)
+		})
+	}
+}
+
+func TestMaxTweetId(t *testing.T) {
+	j := &json.Adaptive{
+		GlobalObjects: json.GlobalObjects{
+			Tweets: map[string]json.Tweet{
+				"10": json.Tweet{Id: 10},
+				"30": json.Tweet{Id: 30},
+				"20": json.Tweet{Id: 20},
+			},
+		},
+	}
+
+	assert.Equal(t, uint64(30), MaxTweetId(j))
+	assert.Equal(t, uint64(0), MaxTweetId(&json.Adaptive{}))
+}
+
+func TestIsRateLimited(t *testing.T) {
+	rateLimited := &json.ErrorResponse{Errors: []json.Error{json.Error{Code: 88, Message: "Rate limit exceeded"}}}
+	forbidden := &json.ErrorResponse{Errors: []json.Error{json.Error{Code: 200, Message: "forbidden"}}}
+
+	assert.True(t, IsRateLimited(rateLimited))
+	assert.True(t, IsRateLimited(fmt.Errorf("retry limit exceeded: %w", rateLimited)))
+	assert.False(t, IsRateLimited(forbidden))
+	assert.False(t, IsRateLimited(errors.New("foo")))
+}
+
+func TestWatch(t *testing.T) {
+	c := NewClient()
+
+	httpmock.ActivateNonDefault(c.Client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	url1 := "https://api.twitter.com/1.1/guest/activate.json"
+	httpmock.RegisterResponder("POST", url1, NewJsonResponse(200, `{ "guest_token": "1234" }`))
+
+	// first poll fetches a single page only
+	url2 := "https://twitter.com/i/api/2/search/adaptive.json?count=40&include_quote_count=true&include_reply_count=1&q=foo&query_source=typed_query&tweet_mode=extended&tweet_search_mode=live"
+	res2 := `{
+    "globalObjects": { "tweets": { "5": { "id": 5 }, "3": { "id": 3 } } },
+    "timeline": {
+      "instructions": [{
+        "addEntries": {
+          "entries": [{
+            "entryId": "sq-cursor-bottom",
+            "content": { "operation": { "cursor": { "value": "scroll:deadbeef" } } }
+          }]
+        }
+      }]
+    }
+  }`
+	httpmock.RegisterResponder("GET", url2, NewJsonResponse(200, res2))
+
+	url3 := "https://twitter.com/i/api/2/search/adaptive.json?count=40&include_quote_count=true&include_reply_count=1&q=foo+since_id%3A5&query_source=typed_query&tweet_mode=extended&tweet_search_mode=live"
+	res3 := `{
+    "globalObjects": { "tweets": { "7": { "id": 7 } } },
+    "timeline": {
+      "instructions": [{
+        "addEntries": {
+          "entries": [{
+            "entryId": "sq-cursor-bottom",
+            "content": { "operation": { "cursor": { "value": "scroll:cafebabe" } } }
+          }]
+        }
+      }]
+    }
+  }`
+	httpmock.RegisterResponder("GET", url3, NewJsonResponse(200, res3))
+
+	url3WithCursor := "https://twitter.com/i/api/2/search/adaptive.json?count=40&cursor=scroll%3Acafebabe&include_quote_count=true&include_reply_count=1&q=foo+since_id%3A5&query_source=typed_query&tweet_mode=extended&tweet_search_mode=live"
+	httpmock.RegisterResponder("GET", url3WithCursor, NewJsonResponse(200, `{}`))
+
+	url4 := "https://twitter.com/i/api/2/search/adaptive.json?count=40&include_quote_count=true&include_reply_count=1&q=foo+since_id%3A7&query_source=typed_query&tweet_mode=extended&tweet_search_mode=live"
+	httpmock.RegisterResponder("GET", url4, NewJsonResponse(200, `{}`))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	opts := WatchOptions{
+		SearchOptions: SearchOptions{Query: Query{Text: "foo"}, Mode: SearchModeTop},
+		Interval:      time.Millisecond,
+		MinInterval:   time.Millisecond,
+		MaxInterval:   time.Millisecond,
+	}
+	ch := c.Watch(ctx, opts)
+
+	actual1 := <-ch
+	assert.NoError(t, actual1.Error)
+	assert.Equal(t, uint64(5), MaxTweetId(actual1.Adaptive))
+
+	actual2 := <-ch
+	assert.NoError(t, actual2.Error)
+	assert.Equal(t, uint64(7), MaxTweetId(actual2.Adaptive))
+
+	// page without tweets ends the second poll
+	actual3 := <-ch
+	AssertSearchResult(t, &SearchResult{Adaptive: &json.Adaptive{}}, actual3)
+
+	actual4 := <-ch
+	AssertSearchResult(t, &SearchResult{Adaptive: &json.Adaptive{}}, actual4)
+
+	cancel()
+	for range ch {
+	}
+
+	info := httpmock.GetCallCountInfo()
+	assert.Equal(t, 1, info["POST "+url1])
+	assert.Equal(t, 1, info["GET "+url2])
+	assert.Equal(t, 1, info["GET "+url3])
+	assert.Equal(t, 1, info["GET "+url3WithCursor])
+	assert.GreaterOrEqual(t, info["GET "+url4], 1)
+}
+
+func TestWatchFailedPage(t *testing.T) {
+	c := NewClient()
+	c.MaxRetryAttempts = 0
+
+	httpmock.ActivateNonDefault(c.Client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	url1 := "https://api.twitter.com/1.1/guest/activate.json"
+	httpmock.RegisterResponder("POST", url1, NewJsonResponse(200, `{ "guest_token": "1234" }`))
+
+	url2 := "https://twitter.com/i/api/2/search/adaptive.json?count=40&include_quote_count=true&include_reply_count=1&q=foo&query_source=typed_query&tweet_mode=extended&tweet_search_mode=live"
+	httpmock.RegisterResponder("GET", url2, NewJsonResponse(200, `{ "globalObjects": { "tweets": { "5": { "id": 5 } } } }`))
+
+	url3 := "https://twitter.com/i/api/2/search/adaptive.json?count=40&include_quote_count=true&include_reply_count=1&q=foo+since_id%3A5&query_source=typed_query&tweet_mode=extended&tweet_search_mode=live"
+	res3 := `{
+    "globalObjects": { "tweets": { "7": { "id": 7 } } },
+    "timeline": {
+      "instructions": [{
+        "addEntries": {
+          "entries": [{
+            "entryId": "sq-cursor-bottom",
+            "content": { "operation": { "cursor": { "value": "scroll:cafebabe" } } }
+          }]
+        }
+      }]
+    }
+  }`
+	httpmock.RegisterResponder("GET", url3, NewJsonResponse(200, res3))
+
+	// the second page fails once, so the next poll starts from since_id:5 again
+	url3WithCursor := "https://twitter.com/i/api/2/search/adaptive.json?count=40&cursor=scroll%3Acafebabe&include_quote_count=true&include_reply_count=1&q=foo+since_id%3A5&query_source=typed_query&tweet_mode=extended&tweet_search_mode=live"
+	failed := false
+	httpmock.RegisterResponder("GET", url3WithCursor, func(req *http.Request) (*http.Response, error) {
+		if !failed {
+			failed = true
+			return NewJsonResponse(403, `{ "errors": [{ "code": 200, "message": "forbidden" }] }`)(req)
+		}
+
+		return NewJsonResponse(200, `{}`)(req)
+	})
+
+	url4 := "https://twitter.com/i/api/2/search/adaptive.json?count=40&include_quote_count=true&include_reply_count=1&q=foo+since_id%3A7&query_source=typed_query&tweet_mode=extended&tweet_search_mode=live"
+	httpmock.RegisterResponder("GET", url4, NewJsonResponse(200, `{}`))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	opts := WatchOptions{
+		SearchOptions: SearchOptions{Query: Query{Text: "foo"}},
+		Interval:      time.Millisecond,
+		MinInterval:   time.Millisecond,
+		MaxInterval:   time.Millisecond,
+	}
+	ch := c.Watch(ctx, opts)
+
+	actual1 := <-ch
+	assert.NoError(t, actual1.Error)
+	assert.Equal(t, uint64(5), MaxTweetId(actual1.Adaptive))
+
+	actual2 := <-ch
+	assert.NoError(t, actual2.Error)
+	assert.Equal(t, uint64(7), MaxTweetId(actual2.Adaptive))
+
+	actual3 := <-ch
+	assert.EqualError(t, actual3.Error, "failed to search: 200: forbidden")
+
+	actual4 := <-ch
+	assert.NoError(t, actual4.Error)
+	assert.Equal(t, uint64(7), MaxTweetId(actual4.Adaptive))
+
+	actual5 := <-ch
+	AssertSearchResult(t, &SearchResult{Adaptive: &json.Adaptive{}}, actual5)
+
+	actual6 := <-ch
+	AssertSearchResult(t, &SearchResult{Adaptive: &json.Adaptive{}}, actual6)
+
+	cancel()
+	for range ch {
+	}
+
+	info := httpmock.GetCallCountInfo()
+	assert.Equal(t, 2, info["POST "+url1])
+	assert.Equal(t, 1, info["GET "+url2])
+	assert.Equal(t, 2, info["GET "+url3])
+	assert.Equal(t, 2, info["GET "+url3WithCursor])
+	assert.GreaterOrEqual(t, info["GET "+url4], 1)
+}
+
+func TestWatchRefreshedGuestToken(t *testing.T) {
+	c := NewClient()
+	c.MaxRetryAttempts = 1
+
+	httpmock.ActivateNonDefault(c.Client.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	url1 := "https://api.twitter.com/1.1/guest/activate.json"
+	activated := 0
+	httpmock.RegisterResponder("POST", url1, func(req *http.Request) (*http.Response, error) {
+		activated++
+		return NewJsonResponse(200, fmt.Sprintf(`{ "guest_token": "%d" }`, activated))(req)
+	})
+
+	// the first guest token is rejected, so the search refreshes it
+	url2 := "https://twitter.com/i/api/2/search/adaptive.json?count=40&include_quote_count=true&include_reply_count=1&q=foo&query_source=typed_query&tweet_mode=extended&tweet_search_mode=live"
+	httpmock.RegisterResponder("GET", url2, func(req *http.Request) (*http.Response, error) {
+		if req.Header.Get("x-guest-token") == "1" {
+			return NewJsonResponse(403, `{ "errors": [{ "code": 200, "message": "forbidden" }] }`)(req)
+		}
+
+		return NewJsonResponse(200, `{ "globalObjects": { "tweets": { "5": { "id": 5 } } } }`)(req)
+	})
+
+	url3 := "https://twitter.com/i/api/2/search/adaptive.json?count=40&include_quote_count=true&include_reply_count=1&q=foo+since_id%3A5&query_source=typed_query&tweet_mode=extended&tweet_search_mode=live"
+	var tokens []string
+	httpmock.RegisterResponder("GET", url3, func(req *http.Request) (*http.Response, error) {
+		tokens = append(tokens, req.Header.Get("x-guest-token"))
+		return NewJsonResponse(200, `{}`)(req)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	opts := WatchOptions{
+		SearchOptions: SearchOptions{Query: Query{Text: "foo"}},
+		Interval:      time.Millisecond,
+		MinInterval:   time.Millisecond,
+		MaxInterval:   time.Millisecond,
+	}
+	ch := c.Watch(ctx, opts)
+
+	actual1 := <-ch
+	assert.NoError(t, actual1.Error)
+	assert.Equal(t, "2", actual1.GuestToken)
+
+	actual2 := <-ch
+	AssertSearchResult(t, &SearchResult{Adaptive: &json.Adaptive{}}, actual2)
+
+	cancel()
+	for range ch {
+	}
+
+	// later polls use the refreshed guest token
+	assert.Equal(t, 2, activated)
+	assert.NotEmpty(t, tokens)
+	for _, token := range tokens {
+		assert.Equal(t, "2", token)
+	}
+}
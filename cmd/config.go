@@ -0,0 +1,82 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/akiomik/squawks/api"
+	"github.com/akiomik/squawks/config"
+)
+
+func NewConfigCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config <command>",
+		Short: "Manage configuration",
+	}
+
+	cmd.AddCommand(NewConfigShowCommand())
+
+	return cmd
+}
+
+func NewConfigShowCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Show the effective configuration from the config file and environment variables",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := cmd.Flag("config").Value.String()
+			if len(path) == 0 {
+				defaultPath, err := config.DefaultPath()
+				if err != nil {
+					return err
+				}
+
+				path = defaultPath
+			}
+
+			c := *config.FromContext(cmd.Context())
+
+			// show built-in defaults for unset client settings
+			client := api.NewClientWithConfig(c.Client)
+			c.Client.AuthToken = client.AuthToken
+			c.Client.UserAgent = client.UserAgent
+			c.Client.MaxRetryAttempts = &client.MaxRetryAttempts
+			c.Client.ApiBaseUrl = client.ApiBaseUrl
+			c.Client.SearchBaseUrl = client.SearchBaseUrl
+			if len(c.Client.AuthToken) > 8 {
+				c.Client.AuthToken = c.Client.AuthToken[:4] + "..." + c.Client.AuthToken[len(c.Client.AuthToken)-4:]
+			}
+
+			// hide the password of the proxy
+			if u, err := url.Parse(c.Client.Proxy); err == nil {
+				c.Client.Proxy = u.Redacted()
+			}
+
+			fmt.Printf("# %s\n", path)
+
+			e := yaml.NewEncoder(os.Stdout)
+			e.SetIndent(2)
+			return e.Encode(c)
+		},
+	}
+
+	return cmd
+}
@@ -21,6 +21,19 @@ import (
 	"github.com/spf13/pflag"
 )
 
+func StringEnumVarP(flags *pflag.FlagSet, p *string, name string, shorthand string, defaultValue string, usage string, options []string) *pflag.Flag {
+	formattedOptions := "[" + strings.Join(options[:], "|") + "]"
+	validator := func(value string) error {
+		if Includes(options, value) {
+			return nil
+		}
+
+		return fmt.Errorf(`valid values are %s`, formattedOptions)
+	}
+
+	return StringWithValidationVarP(flags, p, name, shorthand, defaultValue, usage+" "+formattedOptions, validator)
+}
+
 func StringSliceEnumVarP(flags *pflag.FlagSet, p *[]string, name string, shorthand string, defaults []string, usage string, options []string) *pflag.Flag {
 	formattedOptions := "[" + strings.Join(options[:], "|") + "]"
 	validator := func(values []string) error {
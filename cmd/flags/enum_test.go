@@ -24,6 +24,55 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+func TestStringEnumVarP(t *testing.T) {
+	examples := map[string]struct {
+		input    []string
+		expected string
+		msg      string
+	}{
+		"none": {
+			input:    []string{},
+			expected: "foo",
+			msg:      "",
+		},
+		"valid": {
+			input:    []string{"--arg=foobar"},
+			expected: "foobar",
+			msg:      "",
+		},
+		"invalid": {
+			input:    []string{"--arg=bar"},
+			expected: "foo",
+			msg:      `invalid argument "bar" for "--arg" flag: valid values are [foo|foobar]`,
+		},
+		"empty": {
+			input:    []string{"--arg="},
+			expected: "foo",
+			msg:      `invalid argument "" for "--arg" flag: valid values are [foo|foobar]`,
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			var arg string
+
+			flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			StringEnumVarP(flags, &arg, "arg", "", "foo", "arg for testing", []string{"foo", "foobar"})
+			err := flags.Parse(e.input)
+
+			if len(e.msg) == 0 {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, e.msg)
+			}
+
+			actual, err := flags.GetString("arg")
+			assert.NoError(t, err)
+			assert.Equal(t, e.expected, actual)
+		})
+	}
+}
+
 func TestStringSliceEnumVarP(t *testing.T) {
 	examples := map[string]struct {
 		input    []string
@@ -14,6 +14,10 @@
 
 package flags
 
+import (
+	"github.com/spf13/pflag"
+)
+
 func All[T any](xs []T, f func(x T) bool) bool {
 	for _, x := range xs {
 		if !f(x) {
@@ -37,3 +41,18 @@ func Any[T any](xs []T, f func(x T) bool) bool {
 func Includes[T comparable](xs []T, n T) bool {
 	return Any(xs, func(x T) bool { return x == n })
 }
+
+// SetIfUnchanged sets *p to value unless value is zero or the flag was given on
+// the command line, so that flags take precedence over configured values.
+func SetIfUnchanged[T comparable](flags *pflag.FlagSet, name string, p *T, value T) {
+	var zero T
+	if value != zero && !flags.Changed(name) {
+		*p = value
+	}
+}
+
+func SetSliceIfUnchanged[T any](flags *pflag.FlagSet, name string, p *[]T, value []T) {
+	if len(value) != 0 && !flags.Changed(name) {
+		*p = value
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -116,3 +117,79 @@ func TestIncludes(t *testing.T) {
 		})
 	}
 }
+
+func TestSetIfUnchanged(t *testing.T) {
+	examples := map[string]struct {
+		input    []string
+		value    string
+		expected string
+	}{
+		"unchanged": {
+			input:    []string{},
+			value:    "configured",
+			expected: "configured",
+		},
+		"unchanged-zero": {
+			input:    []string{},
+			value:    "",
+			expected: "default",
+		},
+		"changed": {
+			input:    []string{"--arg=flag"},
+			value:    "configured",
+			expected: "flag",
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			var arg string
+
+			flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			flags.StringVarP(&arg, "arg", "", "default", "arg for testing")
+			err := flags.Parse(e.input)
+			assert.NoError(t, err)
+
+			SetIfUnchanged(flags, "arg", &arg, e.value)
+			assert.Equal(t, e.expected, arg)
+		})
+	}
+}
+
+func TestSetSliceIfUnchanged(t *testing.T) {
+	examples := map[string]struct {
+		input    []string
+		value    []string
+		expected []string
+	}{
+		"unchanged": {
+			input:    []string{},
+			value:    []string{"foo", "bar"},
+			expected: []string{"foo", "bar"},
+		},
+		"unchanged-empty": {
+			input:    []string{},
+			value:    []string{},
+			expected: []string{},
+		},
+		"changed": {
+			input:    []string{"--arg=baz"},
+			value:    []string{"foo", "bar"},
+			expected: []string{"baz"},
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			var args []string
+
+			flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			flags.StringSliceVarP(&args, "arg", "", []string{}, "args for testing")
+			err := flags.Parse(e.input)
+			assert.NoError(t, err)
+
+			SetSliceIfUnchanged(flags, "arg", &args, e.value)
+			assert.Equal(t, e.expected, args)
+		})
+	}
+}
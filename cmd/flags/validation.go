@@ -21,6 +21,35 @@ import (
 	"github.com/spf13/pflag"
 )
 
+func StringWithValidationVarP(flags *pflag.FlagSet, p *string, name string, shorthand string, defaultValue string, usage string, validator func(string) error) *pflag.Flag {
+	*p = defaultValue
+	v := &StringValueWithValidation{Value: p, Validator: validator}
+	return flags.VarPF(v, name, shorthand, usage)
+}
+
+type StringValueWithValidation struct {
+	Value     *string
+	Validator func(string) error
+}
+
+func (e *StringValueWithValidation) Set(v string) error {
+	err := e.Validator(v)
+	if err != nil {
+		return err
+	}
+
+	*e.Value = v
+	return nil
+}
+
+func (e *StringValueWithValidation) String() string {
+	return *e.Value
+}
+
+func (e *StringValueWithValidation) Type() string {
+	return "string"
+}
+
 func StringSliceWithValidationVarP(flags *pflag.FlagSet, p *[]string, name string, shorthand string, defaults []string, usage string, validator func([]string) error) *pflag.Flag {
 	*p = defaults
 	v := &StringSliceValueWithValidation{Values: p, Validator: validator}
@@ -26,6 +26,59 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+func TestStringWithValidationVarP(t *testing.T) {
+	examples := map[string]struct {
+		input    []string
+		expected string
+		msg      string
+	}{
+		"none": {
+			input:    []string{},
+			expected: "",
+			msg:      "",
+		},
+		"valid": {
+			input:    []string{"--arg=foobar"},
+			expected: "foobar",
+			msg:      "",
+		},
+		"invalid": {
+			input:    []string{"--arg=bar"},
+			expected: "",
+			msg:      `invalid argument "bar" for "--arg" flag: string starting with "foo" are supported`,
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			var arg string
+
+			flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			prefix := "foo"
+			validator := func(value string) error {
+				if strings.HasPrefix(value, prefix) {
+					return nil
+				}
+
+				return fmt.Errorf(`string starting with "%s" are supported`, prefix)
+			}
+
+			StringWithValidationVarP(flags, &arg, "arg", "", "", "arg for testing", validator)
+			err := flags.Parse(e.input)
+
+			if len(e.msg) == 0 {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, e.msg)
+			}
+
+			actual, err := flags.GetString("arg")
+			assert.NoError(t, err)
+			assert.Equal(t, e.expected, actual)
+		})
+	}
+}
+
 func TestStringSliceWithValidationVarP(t *testing.T) {
 	examples := map[string]struct {
 		input    []string
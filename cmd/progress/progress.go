@@ -0,0 +1,237 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/akiomik/squawks/api"
+	"github.com/akiomik/squawks/export"
+)
+
+const (
+	TerminalInterval = 200 * time.Millisecond
+	LogInterval      = 10 * time.Second
+)
+
+// Stderr is the standard error shared by the progress line and the logger, so that
+// log lines are written above the progress line instead of running into it.
+var Stderr = NewStatusWriter(os.Stderr)
+
+// StatusWriter writes to out below which a status line is kept. The status line
+// is cleared before each write and drawn again after it.
+type StatusWriter struct {
+	mu     sync.Mutex
+	out    io.Writer
+	status string
+}
+
+func NewStatusWriter(out io.Writer) *StatusWriter {
+	return &StatusWriter{out: out}
+}
+
+// IsTerminal reports whether the writer writes to a terminal.
+func (w *StatusWriter) IsTerminal() bool {
+	f, ok := w.out.(*os.File)
+	return ok && IsTerminal(f)
+}
+
+func (w *StatusWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.status) == 0 {
+		return w.out.Write(b)
+	}
+
+	if _, err := io.WriteString(w.out, "\r\033[K"); err != nil {
+		return 0, err
+	}
+
+	n, err := w.out.Write(b)
+	if err != nil {
+		return n, err
+	}
+
+	_, err = io.WriteString(w.out, w.status)
+	return n, err
+}
+
+// SetStatus replaces the status line with s.
+func (w *StatusWriter) SetStatus(s string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.status = s
+	fmt.Fprint(w.out, "\r\033[K"+s)
+}
+
+// EndStatus leaves the status line as is and moves to the next line.
+func (w *StatusWriter) EndStatus() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.status) != 0 {
+		fmt.Fprintln(w.out)
+	}
+
+	w.status = ""
+}
+
+// Progress reports the state of a crawl. On a terminal a single status line is
+// redrawn in place; otherwise a log line is written periodically.
+type Progress struct {
+	Out        *StatusWriter
+	IsTerminal bool
+	Interval   time.Duration
+	// Since is the lower bound of the search. The distance from the oldest tweet
+	// to it is reported when it is set.
+	Since time.Time
+	Stats func() api.Stats
+
+	mu      sync.Mutex
+	pages   uint64
+	tweets  uint64
+	oldest  time.Time
+	started time.Time
+	now     func() time.Time
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func New(out *StatusWriter, stats func() api.Stats) *Progress {
+	p := &Progress{
+		Out:        out,
+		IsTerminal: out.IsTerminal(),
+		Stats:      stats,
+		now:        time.Now,
+	}
+
+	if p.IsTerminal {
+		p.Interval = TerminalInterval
+	} else {
+		p.Interval = LogInterval
+	}
+
+	return p
+}
+
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Track counts the records passing through ch as exported.
+func (p *Progress) Track(ch <-chan []export.Record) <-chan []export.Record {
+	out := make(chan []export.Record)
+
+	go func() {
+		defer close(out)
+
+		for records := range ch {
+			p.mu.Lock()
+			p.pages++
+			p.tweets += uint64(len(records))
+			for _, r := range records {
+				createdAt := time.Time(r.CreatedAt)
+				if p.oldest.IsZero() || createdAt.Before(p.oldest) {
+					p.oldest = createdAt
+				}
+			}
+			p.mu.Unlock()
+
+			out <- records
+		}
+	}()
+
+	return out
+}
+
+func (p *Progress) String() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s := fmt.Sprintf("pages: %d, tweets: %d", p.pages, p.tweets)
+
+	if !p.oldest.IsZero() {
+		oldest := export.Iso8601Date(p.oldest)
+		s += ", oldest: " + oldest.String()
+		if !p.Since.IsZero() {
+			s += fmt.Sprintf(" (%s to since)", p.oldest.Sub(p.Since).Truncate(time.Minute))
+		}
+	}
+
+	if p.Stats != nil {
+		stats := p.Stats()
+		elapsed := p.now().Sub(p.started).Seconds()
+		rate := 0.0
+		if elapsed > 0 {
+			rate = float64(stats.Requests) / elapsed
+		}
+
+		s += fmt.Sprintf(", %.1f req/s, retries: %d, guest tokens: %d", rate, stats.Retries, stats.GuestTokens)
+	}
+
+	return s
+}
+
+func (p *Progress) print() {
+	if p.IsTerminal {
+		p.Out.SetStatus(p.String())
+	} else {
+		fmt.Fprintln(p.Out, p.String())
+	}
+}
+
+// Start reports progress every Interval until Stop is called.
+func (p *Progress) Start() {
+	p.started = p.now()
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+
+	go func() {
+		defer close(p.done)
+
+		ticker := time.NewTicker(p.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				p.print()
+			case <-p.stop:
+				p.print()
+				if p.IsTerminal {
+					p.Out.EndStatus()
+				}
+				return
+			}
+		}
+	}()
+}
+
+// Stop reports the final progress and waits for the reporter to finish.
+func (p *Progress) Stop() {
+	close(p.stop)
+	<-p.done
+}
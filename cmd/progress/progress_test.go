@@ -0,0 +1,144 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build small
+// +build small
+
+package progress
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/akiomik/squawks/api"
+	"github.com/akiomik/squawks/export"
+)
+
+func TestIsTerminal(t *testing.T) {
+	f, err := os.CreateTemp(os.TempDir(), "squawks-test-is-terminal-")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	assert.False(t, IsTerminal(f))
+}
+
+func TestStatusWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewStatusWriter(&buf)
+
+	fmt.Fprintln(w, "foo")
+	w.SetStatus("pages: 1")
+	fmt.Fprintln(w, "bar")
+	w.SetStatus("pages: 2")
+	w.EndStatus()
+	fmt.Fprintln(w, "baz")
+
+	expected := "foo\n\r\033[Kpages: 1\r\033[Kbar\npages: 1\r\033[Kpages: 2\nbaz\n"
+	assert.Equal(t, expected, buf.String())
+	assert.False(t, w.IsTerminal())
+}
+
+func TestTrack(t *testing.T) {
+	p := &Progress{now: time.Now}
+
+	ch := make(chan []export.Record)
+	go func() {
+		defer close(ch)
+
+		ch <- []export.Record{
+			export.Record{Id: 3, CreatedAt: export.Iso8601Date(time.Date(2020, 9, 6, 12, 0, 0, 0, time.UTC))},
+			export.Record{Id: 2, CreatedAt: export.Iso8601Date(time.Date(2020, 9, 6, 6, 0, 0, 0, time.UTC))},
+		}
+		ch <- []export.Record{}
+		ch <- []export.Record{
+			export.Record{Id: 1, CreatedAt: export.Iso8601Date(time.Date(2020, 9, 5, 18, 0, 0, 0, time.UTC))},
+		}
+	}()
+
+	n := 0
+	for records := range p.Track(ch) {
+		n += len(records)
+	}
+
+	assert.Equal(t, 3, n)
+	assert.Equal(t, uint64(3), p.pages)
+	assert.Equal(t, uint64(3), p.tweets)
+	assert.Equal(t, time.Date(2020, 9, 5, 18, 0, 0, 0, time.UTC), p.oldest)
+}
+
+func TestString(t *testing.T) {
+	started := time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC)
+	stats := func() api.Stats { return api.Stats{Requests: 30, Retries: 2, GuestTokens: 3} }
+
+	examples := map[string]struct {
+		progress *Progress
+		expected string
+	}{
+		"empty": {
+			progress: &Progress{},
+			expected: "pages: 0, tweets: 0",
+		},
+		"without-since": {
+			progress: &Progress{pages: 3, tweets: 120, oldest: time.Date(2020, 9, 6, 12, 0, 0, 0, time.UTC)},
+			expected: "pages: 3, tweets: 120, oldest: 2020-09-06T12:00:00+00:00",
+		},
+		"with-since": {
+			progress: &Progress{pages: 3, tweets: 120, oldest: time.Date(2020, 9, 6, 12, 0, 0, 0, time.UTC), Since: time.Date(2020, 9, 5, 0, 0, 0, 0, time.UTC)},
+			expected: "pages: 3, tweets: 120, oldest: 2020-09-06T12:00:00+00:00 (36h0m0s to since)",
+		},
+		"with-stats": {
+			progress: &Progress{pages: 3, tweets: 120, Stats: stats, started: started, now: func() time.Time { return started.Add(20 * time.Second) }},
+			expected: "pages: 3, tweets: 120, 1.5 req/s, retries: 2, guest tokens: 3",
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, e.expected, e.progress.String())
+		})
+	}
+}
+
+func TestStartStop(t *testing.T) {
+	examples := map[string]struct {
+		isTerminal bool
+		expected   string
+	}{
+		"terminal": {
+			isTerminal: true,
+			expected:   "\r\033[Kpages: 0, tweets: 0\n",
+		},
+		"not-terminal": {
+			isTerminal: false,
+			expected:   "pages: 0, tweets: 0\n",
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			p := &Progress{Out: NewStatusWriter(&buf), IsTerminal: e.isTerminal, Interval: time.Hour, now: time.Now}
+
+			p.Start()
+			p.Stop()
+
+			assert.Equal(t, e.expected, buf.String())
+		})
+	}
+}
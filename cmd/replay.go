@@ -0,0 +1,73 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/akiomik/squawks/api"
+	"github.com/akiomik/squawks/cmd/flags"
+	"github.com/akiomik/squawks/cmd/search"
+	"github.com/akiomik/squawks/config"
+	"github.com/akiomik/squawks/export"
+)
+
+func NewReplayCommand() *cobra.Command {
+	var (
+		output        search.OutputFlags
+		dedup         string
+		dedupCapacity uint64
+		quiet         bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "replay DIR [--out FILENAME]",
+		Short: "Export tweets from the raw responses saved by search tweets --raw-dir",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output.ListColumns {
+				search.PrintColumns(os.Stdout)
+				return nil
+			}
+
+			cmd.SilenceUsage = true
+
+			c := config.FromContext(cmd.Context())
+			output.ApplyConfig(cmd.Flags().Changed, c.Export)
+			flags.SetIfUnchanged(cmd.Flags(), "dedup", &dedup, c.Export.Dedup)
+			flags.SetIfUnchanged(cmd.Flags(), "dedup-capacity", &dedupCapacity, c.Export.DedupCapacity)
+
+			pl := &search.Pipeline{Output: &output, Dedup: dedup, DedupCapacity: dedupCapacity, Quiet: quiet}
+			if err := pl.Open(); err != nil {
+				return err
+			}
+			defer pl.Close()
+
+			return pl.Run(cmd.Context(), func(ctx context.Context) <-chan *api.SearchResult {
+				return api.ReplayArchive(args[0])
+			})
+		},
+	}
+
+	output.AddFlags(cmd.Flags())
+	flags.StringEnumVarP(cmd.Flags(), &dedup, "dedup", "", "memory", "drop duplicate tweets using an in-memory set or a bloom filter", []string{"memory", "bloom", "none"})
+	cmd.Flags().Uint64VarP(&dedupCapacity, "dedup-capacity", "", export.DefaultBloomCapacity, "expected number of tweets for --dedup bloom")
+	cmd.Flags().BoolVarP(&quiet, "quiet", "", false, "suppress progress and summary on stderr")
+
+	return cmd
+}
@@ -15,19 +15,107 @@
 package cmd
 
 import (
+	"fmt"
+
 	"github.com/spf13/cobra"
 
+	"github.com/akiomik/squawks/api"
+	"github.com/akiomik/squawks/cmd/flags"
+	"github.com/akiomik/squawks/cmd/progress"
 	"github.com/akiomik/squawks/config"
+	"github.com/akiomik/squawks/logging"
 )
 
 func NewRootCommand() *cobra.Command {
+	var (
+		configPath string
+		logLevel   string
+		logFormat  string
+		record     string
+		replay     string
+		cassette   *api.Cassette
+	)
+
 	cmd := &cobra.Command{
 		Use:     "squawks <command>",
 		Short:   "squawks v" + config.Version,
 		Version: config.Version,
+		// Errors are printed by main.
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			level, err := logging.ParseLevel(logLevel)
+			if err != nil {
+				return err
+			}
+
+			logging.SetDefault(logging.New(progress.Stderr, level, logging.Format(logFormat)))
+
+			c, err := config.Load(configPath)
+			if err != nil {
+				cmd.SilenceUsage = true
+				return err
+			}
+
+			switch {
+			case len(record) != 0 && len(replay) != 0:
+				cmd.SilenceUsage = true
+				return fmt.Errorf("--record and --replay cannot be used together")
+			case len(record) != 0:
+				cassette, err = api.CreateCassette(record)
+				if err != nil {
+					cmd.SilenceUsage = true
+					return err
+				}
+
+				c.Client.WrapTransport = cassette.Recorder
+			case len(replay) != 0:
+				cassette, err = api.LoadCassette(replay)
+				if err != nil {
+					cmd.SilenceUsage = true
+					return err
+				}
+
+				c.Client.WrapTransport = cassette.Replayer
+			}
+
+			// PersistentPostRunE is skipped when the command fails, so the cassette
+			// is also closed when RunE returns an error.
+			if run := cmd.RunE; cassette != nil && run != nil {
+				cmd.RunE = func(cmd *cobra.Command, args []string) error {
+					err := run(cmd, args)
+					if err != nil {
+						if cerr := cassette.Close(); cerr != nil {
+							logging.Default().Error("failed to close the cassette", "error", cerr)
+						}
+					}
+
+					return err
+				}
+			}
+
+			cmd.SetContext(config.NewContext(cmd.Context(), c))
+			return nil
+		},
+		PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
+			if cassette == nil {
+				return nil
+			}
+
+			return cassette.Close()
+		},
 	}
 
+	cmd.PersistentFlags().StringVarP(&configPath, "config", "", "", "config file (default $XDG_CONFIG_HOME/squawks/config.yaml)")
+	cmd.PersistentFlags().StringVarP(&record, "record", "", "", "record the http requests and responses to a cassette file")
+	cmd.PersistentFlags().StringVarP(&replay, "replay", "", "", "respond to http requests from a cassette file recorded with --record instead of the network")
+	flags.StringEnumVarP(cmd.PersistentFlags(), &logLevel, "log-level", "", "warn", "log level", []string{"debug", "info", "warn", "error"})
+	flags.StringEnumVarP(cmd.PersistentFlags(), &logFormat, "log-format", "", "text", "log format", []string{"text", "json"})
+
+	cmd.AddCommand(NewConfigCommand())
+	cmd.AddCommand(NewReplayCommand())
+	cmd.AddCommand(NewRunCommand())
 	cmd.AddCommand(NewSearchCommand())
+	cmd.AddCommand(NewWatchCommand())
 
 	return cmd
 }
@@ -0,0 +1,52 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build medium
+// +build medium
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordFailedSearch(t *testing.T) {
+	srv := newSearchServer(t)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("SQUAWKS_API_BASE_URL", srv.URL)
+	t.Setenv("SQUAWKS_SEARCH_BASE_URL", srv.URL)
+	t.Setenv("SQUAWKS_MAX_RETRY_ATTEMPTS", "0")
+
+	dir := t.TempDir()
+	cassette := filepath.Join(dir, "session.jsonl")
+
+	cmd := NewRootCommand()
+	cmd.SetArgs([]string{"search", "tweets", "-q", "flaky", "--quiet", "--columns", "id", "-o", filepath.Join(dir, "recorded.csv"), "--record", cassette})
+	assert.EqualError(t, cmd.Execute(), "failed to search: 200: forbidden")
+
+	// The cassette of the failed search is complete without the server.
+	srv.Close()
+
+	cmd = NewRootCommand()
+	cmd.SetArgs([]string{"search", "tweets", "-q", "flaky", "--quiet", "--columns", "id", "-o", filepath.Join(dir, "replayed.csv"), "--replay", cassette})
+	assert.EqualError(t, cmd.Execute(), "failed to search: 200: forbidden")
+
+	replayed, err := os.ReadFile(filepath.Join(dir, "replayed.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, "id\n2\n1\n", string(replayed))
+}
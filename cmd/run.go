@@ -0,0 +1,186 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/akiomik/squawks/api"
+	"github.com/akiomik/squawks/cmd/search"
+	"github.com/akiomik/squawks/config"
+	"github.com/akiomik/squawks/logging"
+)
+
+type jobResult struct {
+	Tweets  int
+	Dropped uint64
+	Skipped uint64
+	Err     error
+}
+
+func NewRunCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "run JOBFILE",
+		Short: "Run the searches defined in a job file",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			c := config.FromContext(cmd.Context())
+			f, err := config.LoadJobFile(args[0])
+			if err != nil {
+				return err
+			}
+
+			pool := api.NewGuestTokenPool(api.NewClientWithConfig(c.Client), f.GuestTokens)
+
+			results := make([]jobResult, len(f.Jobs))
+			sem := make(chan struct{}, f.Concurrency)
+			var wg sync.WaitGroup
+			for i, j := range f.Jobs {
+				wg.Add(1)
+				go func(i int, j config.Job) {
+					defer wg.Done()
+
+					sem <- struct{}{}
+					defer func() { <-sem }()
+
+					results[i] = runJob(c, pool, j)
+				}(i, j)
+			}
+			wg.Wait()
+
+			failed := 0
+			w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "JOB\tOUT\tTWEETS\tDUPLICATES\tEXISTING\tERROR")
+			for i, j := range f.Jobs {
+				r := results[i]
+				msg := ""
+				if r.Err != nil {
+					failed++
+					msg = r.Err.Error()
+				}
+
+				fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%s\n", j.Name, j.Out, r.Tweets, r.Dropped, r.Skipped, msg)
+			}
+			w.Flush()
+
+			if failed != 0 {
+				return fmt.Errorf("%d of %d jobs failed", failed, len(f.Jobs))
+			}
+
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func runJob(c *config.Config, pool *api.GuestTokenPool, j config.Job) jobResult {
+	logger := logging.With(logging.Default(), "job", j.Name)
+
+	q := search.NewQuery(j.Search)
+	if q.IsEmpty() {
+		return jobResult{Err: fmt.Errorf("one or more queries are required")}
+	}
+
+	opts := api.SearchOptions{Query: q, Mode: api.SearchMode(j.Mode), PageSize: j.PageSize}
+	if _, err := opts.Mode.Params(); err != nil {
+		return jobResult{Err: err}
+	}
+
+	dedup := c.Export.Dedup
+	if len(dedup) == 0 {
+		dedup = "memory"
+	}
+
+	output := search.OutputFlags{
+		Out:              j.Out,
+		Format:           j.Format,
+		Delimiter:        j.Delimiter,
+		Quote:            j.Quote,
+		Bom:              j.Bom,
+		Crlf:             j.Crlf,
+		EscapeNewlines:   j.EscapeNewlines,
+		Header:           j.Header == nil || *j.Header,
+		SanitizeFormulas: j.SanitizeFormulas,
+		TimeFormat:       j.TimeFormat,
+		Timezone:         j.Timezone,
+		Append:           j.OutputMode == "append",
+		Overwrite:        j.OutputMode == "overwrite",
+		SkipExisting:     j.SkipExisting,
+		Compress:         j.Compress,
+		RotateSize:       j.RotateSize,
+		RotateRecords:    j.RotateRecords,
+		PartitionBy:      j.PartitionBy,
+		Columns:          j.Columns,
+		RowGroupSize:     j.RowGroupSize,
+		GeoPlaces:        j.GeoPlaces,
+		GeoFallback:      j.GeoFallback,
+		EsIndex:          j.EsIndex,
+		EsUrl:            j.EsUrl,
+		EsBatchSize:      j.EsBatchSize,
+		Template:         j.Template,
+		TemplateHeader:   j.TemplateHeader,
+		TemplateFooter:   j.TemplateFooter,
+		HtmlTitle:        j.HtmlTitle,
+		Logger:           logger,
+	}
+
+	// The format of a job is always set by the job file.
+	output.ApplyConfig(func(name string) bool {
+		switch name {
+		case "columns":
+			return len(output.Columns) != 0
+		case "time-format":
+			return len(output.TimeFormat) != 0
+		case "timezone":
+			return len(output.Timezone) != 0
+		case "compress":
+			return len(output.Compress) != 0
+		default:
+			return true
+		}
+	}, c.Export)
+
+	p := &search.Pipeline{Output: &output, Dedup: dedup, DedupCapacity: c.Export.DedupCapacity, Quiet: true}
+	if err := p.Open(); err != nil {
+		return jobResult{Err: err}
+	}
+	defer p.Close()
+
+	client := api.NewClientWithConfig(c.Client)
+	client.Logger = logger
+	client.GuestTokenPool = pool
+
+	logger.Info("job started", "query", q.Encode())
+
+	err := p.Run(context.Background(), func(ctx context.Context) <-chan *api.SearchResult {
+		return client.SearchAllContext(ctx, opts)
+	})
+	if err != nil {
+		logger.Error("job failed", "error", err)
+	}
+
+	r := jobResult{Tweets: p.Tweets, Dropped: p.Dropped, Skipped: p.Skipped, Err: err}
+	logger.Info("job finished", "tweets", r.Tweets, "duplicates", r.Dropped, "existing", r.Skipped)
+	return r
+}
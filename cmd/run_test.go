@@ -0,0 +1,133 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build medium
+// +build medium
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/akiomik/squawks/api"
+	"github.com/akiomik/squawks/config"
+)
+
+func TestRunJob(t *testing.T) {
+	srv := newSearchServer(t)
+	attempts := uint(0)
+	c := &config.Config{Client: config.ClientConfig{ApiBaseUrl: srv.URL, SearchBaseUrl: srv.URL, MaxRetryAttempts: &attempts}}
+
+	examples := map[string]struct {
+		job            config.Job
+		expectedTweets int
+		expectedLines  int
+		msg            string
+	}{
+		"csv": {
+			job:            config.Job{Name: "foo", Out: "foo.csv", Format: "csv", Search: config.Search{Text: "foo"}},
+			expectedTweets: 2,
+			expectedLines:  3,
+			msg:            "",
+		},
+		"tsv-without-header": {
+			job:            config.Job{Name: "foo", Out: "foo.tsv", Format: "tsv", Header: new(bool), Search: config.Search{Text: "foo"}},
+			expectedTweets: 2,
+			expectedLines:  2,
+			msg:            "",
+		},
+		"search-error": {
+			job:            config.Job{Name: "fail", Out: "fail.csv", Format: "csv", Search: config.Search{Text: "fail"}},
+			expectedTweets: 0,
+			expectedLines:  0,
+			msg:            "failed to search: 200: forbidden",
+		},
+		"unknown-mode": {
+			job:            config.Job{Name: "foo", Out: "foo.csv", Format: "csv", Search: config.Search{Text: "foo", Mode: "users"}},
+			expectedTweets: 0,
+			expectedLines:  -1,
+			msg:            "unknown search mode: users",
+		},
+		"no-query": {
+			job:            config.Job{Name: "foo", Out: "foo.csv", Format: "csv"},
+			expectedTweets: 0,
+			expectedLines:  -1,
+			msg:            "one or more queries are required",
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			e.job.Out = filepath.Join(t.TempDir(), e.job.Out)
+			pool := api.NewGuestTokenPool(api.NewClientWithConfig(c.Client), 1)
+
+			actual := runJob(c, pool, e.job)
+			if len(e.msg) == 0 {
+				assert.NoError(t, actual.Err)
+			} else {
+				assert.EqualError(t, actual.Err, e.msg)
+			}
+
+			assert.Equal(t, e.expectedTweets, actual.Tweets)
+
+			buf, err := os.ReadFile(e.job.Out)
+			if e.expectedLines < 0 {
+				assert.ErrorIs(t, err, os.ErrNotExist)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, e.expectedLines, strings.Count(string(buf), "\n"))
+			}
+		})
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	srv := newSearchServer(t)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("SQUAWKS_API_BASE_URL", srv.URL)
+	t.Setenv("SQUAWKS_SEARCH_BASE_URL", srv.URL)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "jobs.yaml")
+	content := `concurrency: 2
+jobs:
+  - out: ` + filepath.Join(dir, "foo.csv") + `
+    query: foo
+  - name: bar
+    out: ` + filepath.Join(dir, "bar.tsv") + `
+    format: tsv
+    query: bar
+    columns: [id, username]
+`
+	err := os.WriteFile(path, []byte(content), 0644)
+	assert.NoError(t, err)
+
+	cmd := NewRootCommand()
+	cmd.SetArgs([]string{"run", path})
+	assert.NoError(t, cmd.Execute())
+
+	foo, err := os.ReadFile(filepath.Join(dir, "foo.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, strings.Count(string(foo), "\n"))
+	assert.Contains(t, string(foo), "2,watson,2020-09-06T13:00:00+00:00,bar")
+
+	bar, err := os.ReadFile(filepath.Join(dir, "bar.tsv"))
+	assert.NoError(t, err)
+	assert.Equal(t, "id\tusername\n2\twatson\n1\twatson\n", string(bar))
+}
@@ -0,0 +1,694 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package search
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/pflag"
+
+	"github.com/akiomik/squawks/cmd/flags"
+	"github.com/akiomik/squawks/cmd/progress"
+	"github.com/akiomik/squawks/config"
+	"github.com/akiomik/squawks/export"
+	"github.com/akiomik/squawks/logging"
+)
+
+// OutputFlags selects where and how a csv export is written.
+type OutputFlags struct {
+	Out            string
+	Format         string
+	Delimiter      string
+	Quote          string
+	Bom            bool
+	Crlf           bool
+	EscapeNewlines bool
+	Header         bool
+	// SanitizeFormulas is one of auto, always or never.
+	SanitizeFormulas string
+	TimeFormat       string
+	Timezone         string
+	Append           bool
+	Overwrite        bool
+	SkipExisting     bool
+	Compress         string
+	RotateSize       string
+	RotateRecords    uint64
+	PartitionBy      string
+	Columns          []string
+	ListColumns      bool
+	RowGroupSize     string
+	// GeoPlaces is one of none, polygon or centroid.
+	GeoPlaces string
+	// GeoFallback is one of none or centroid.
+	GeoFallback string
+	EsIndex     string
+	EsUrl       string
+	EsBatchSize int
+	// Template, TemplateHeader and TemplateFooter are template files.
+	Template       string
+	TemplateHeader string
+	TemplateFooter string
+	HtmlTitle      string
+	// Logger is the logger of the exporter. It defaults to logging.Default().
+	Logger logging.Logger
+}
+
+// AddFlags adds --out and the flags controlling the output file.
+func (o *OutputFlags) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVarP(&o.Out, "out", "o", "", "output filename, or - for stdout (default stdout)")
+	flags.StringEnumVarP(fs, &o.Format, "format", "", "csv", "output format (sqlite writes normalized tables, updating tweets already in the database with --append)", []string{"csv", "tsv", "sqlite", "parquet", "geojson", "kml", "es-bulk", "xlsx", "template", "html"})
+	flags.StringWithValidationVarP(fs, &o.Delimiter, "delimiter", "", "", "field delimiter, e.g. ; or tab (default , for csv and tab for tsv)", func(v string) error {
+		_, err := export.ParseDelimiter(v)
+		return err
+	})
+	flags.StringEnumVarP(fs, &o.Quote, "quote", "", "", "quote fields when needed, always or never (never escapes delimiters and line breaks with a backslash; default minimal for csv and none for tsv)", []string{"minimal", "all", "none"})
+	fs.BoolVarP(&o.Bom, "bom", "", false, "write a UTF-8 byte order mark (e.g. for Excel)")
+	fs.BoolVarP(&o.Crlf, "crlf", "", false, "end lines with CRLF (e.g. for Excel)")
+	fs.BoolVarP(&o.EscapeNewlines, "escape-newlines", "", false, "write line breaks in fields as \\n (implied by --quote none)")
+	fs.BoolVarP(&o.Header, "header", "", true, "write the header row")
+	fs.StringVarP(&o.TimeFormat, "time-format", "", "", "format of timestamps: rfc3339, unix, unixms or a Go layout such as 2006-01-02 (default 2006-01-02T15:04:05-07:00)")
+	fs.StringVarP(&o.Timezone, "timezone", "", "", "time zone of timestamps and date partitions, e.g. Asia/Tokyo (default UTC)")
+	flags.StringEnumVarP(fs, &o.SanitizeFormulas, "sanitize-formulas", "", "auto", "prefix text starting with =, +, -, @ with ' so that spreadsheets do not run it as a formula (auto sanitizes all output except to a terminal)", []string{"auto", "always", "never"})
+	fs.BoolVarP(&o.Append, "append", "", false, "append to the output file if it exists")
+	fs.BoolVarP(&o.Overwrite, "overwrite", "", false, "overwrite the output file if it exists")
+	fs.BoolVarP(&o.SkipExisting, "skip-existing", "", false, "skip tweets already in the output file (requires --append)")
+	flags.StringEnumVarP(fs, &o.Compress, "compress", "", "auto", "compress the output (auto infers it from a .gz or .zst extension)", []string{"auto", "none", "gzip", "zstd"})
+	flags.StringWithValidationVarP(fs, &o.RotateSize, "rotate-size", "", "", "start a new numbered output file once it reaches a size (e.g. 500MB)", func(v string) error {
+		_, err := export.ParseSize(v)
+		return err
+	})
+	fs.Uint64VarP(&o.RotateRecords, "rotate-records", "", 0, "start a new numbered output file every number of tweets")
+	fs.StringSliceVarP(&o.Columns, "columns", "", nil, "comma-separated columns to export in order (default all, see --list-columns)")
+	fs.BoolVarP(&o.ListColumns, "list-columns", "", false, "print the available columns and exit")
+	flags.StringWithValidationVarP(fs, &o.RowGroupSize, "row-group-size", "", "", "size of parquet row groups (default 128MB)", func(v string) error {
+		_, err := export.ParseSize(v)
+		return err
+	})
+	flags.StringEnumVarP(fs, &o.GeoPlaces, "geo-places", "", string(export.GeoPlacesNone), "export tweets located only by their place as its bounding box or centroid (geojson and kml; others are skipped)", []string{"none", "polygon", "centroid"})
+	flags.StringEnumVarP(fs, &o.GeoFallback, "geo-fallback", "", string(export.GeoFallbackNone), "write the centroid of the place of tweets without exact coordinates to place_latitude and place_longitude, which are added to the default columns with geo_source (csv and tsv)", []string{"none", "centroid"})
+	fs.StringVarP(&o.EsIndex, "es-index", "", export.DefaultEsIndex, "index of es-bulk documents, which may contain the creation date of tweets in --timezone (e.g. tweets-{yyyy.MM})")
+	fs.StringVarP(&o.EsUrl, "es-url", "", "", "post es-bulk documents to an Elasticsearch or OpenSearch url (e.g. http://localhost:9200) instead of writing them to --out")
+	fs.IntVarP(&o.EsBatchSize, "es-batch-size", "", export.DefaultEsBatchSize, "number of documents per bulk request with --es-url")
+	fs.StringVarP(&o.Template, "template", "", "", "Go text/template file rendered for each tweet with --format template (see README)")
+	fs.StringVarP(&o.TemplateHeader, "template-header", "", "", "template file rendered before the tweets with --format template")
+	fs.StringVarP(&o.TemplateFooter, "template-footer", "", "", "template file rendered after the tweets with --format template, e.g. with {{.Tweets}}")
+	fs.StringVarP(&o.HtmlTitle, "html-title", "", export.DefaultHtmlTitle, "title of the html report")
+	flags.StringEnumVarP(fs, &o.PartitionBy, "partition-by", "", string(export.PartitionNone), "write tweets to date-stamped output files by their creation time in --timezone", []string{"none", "hour", "day", "month", "year"})
+}
+
+// Mode returns the output mode selected by --append and --overwrite.
+func (o *OutputFlags) Mode() export.OutputMode {
+	if o.Append {
+		return export.OutputAppend
+	}
+
+	if o.Overwrite {
+		return export.OutputOverwrite
+	}
+
+	return export.OutputCreate
+}
+
+// Compression returns the compression selected by --compress for the output name.
+func (o *OutputFlags) Compression() export.Compression {
+	if len(o.Compress) == 0 || o.Compress == "auto" {
+		return export.CompressionForName(o.Out)
+	}
+
+	return export.Compression(o.Compress)
+}
+
+func (o *OutputFlags) Rotation() (export.Rotation, error) {
+	partition, err := export.ParsePartition(o.PartitionBy)
+	if err != nil {
+		return export.Rotation{}, err
+	}
+
+	r := export.Rotation{MaxRecords: o.RotateRecords, PartitionBy: partition}
+	if len(o.RotateSize) != 0 {
+		size, err := export.ParseSize(o.RotateSize)
+		if err != nil {
+			return r, err
+		}
+
+		r.MaxBytes = size
+	}
+
+	return r, nil
+}
+
+// stdoutIsTerminal reports whether stdout is a terminal, where --sanitize-formulas
+// auto leaves text as is. Tests replace it.
+var stdoutIsTerminal = func() bool {
+	return progress.IsTerminal(os.Stdout)
+}
+
+// CsvExporter returns the csv options selected by --format and the dialect flags.
+func (o *OutputFlags) CsvExporter() (export.CsvExporter, error) {
+	var e export.CsvExporter
+	switch o.Format {
+	case "", "csv":
+		e = export.CsvExporter{}
+	case "tsv":
+		e = export.NewTsvExporter()
+	default:
+		return e, fmt.Errorf("unsupported format: %s", o.Format)
+	}
+
+	if len(o.Delimiter) != 0 {
+		comma, err := export.ParseDelimiter(o.Delimiter)
+		if err != nil {
+			return e, err
+		}
+
+		e.Comma = comma
+	}
+
+	if len(o.Quote) != 0 {
+		quoting, err := export.ParseQuoting(o.Quote)
+		if err != nil {
+			return e, err
+		}
+
+		e.Quoting = quoting
+	}
+
+	timeFormat, err := export.ParseTimeFormat(o.TimeFormat, o.Timezone)
+	if err != nil {
+		return e, err
+	}
+
+	e.TimeFormat = timeFormat
+	e.GeoFallback, err = export.ParseGeoFallback(o.GeoFallback)
+	if err != nil {
+		return e, err
+	}
+
+	e.Bom = o.Bom
+	e.UseCRLF = o.Crlf
+	e.EscapeNewlines = e.EscapeNewlines || o.EscapeNewlines
+	e.SkipHeader = !o.Header
+	e.Logger = o.Logger
+
+	switch o.SanitizeFormulas {
+	case "", "auto":
+		e.SanitizeFormulas = !export.IsStdout(o.Out) || !stdoutIsTerminal()
+	case "always":
+		e.SanitizeFormulas = true
+	case "never":
+		e.SanitizeFormulas = false
+	default:
+		return e, fmt.Errorf("unknown sanitize-formulas: %s", o.SanitizeFormulas)
+	}
+
+	return e, nil
+}
+
+// ApplyConfig sets the output options that are not changed to the defaults of
+// the config file, skipping the ones that do not apply to the format.
+func (o *OutputFlags) ApplyConfig(changed func(name string) bool, c config.ExportConfig) {
+	set := func(name string, p *string, value string) {
+		if len(value) != 0 && !changed(name) {
+			*p = value
+		}
+	}
+
+	set("format", &o.Format, c.Format)
+	set("timezone", &o.Timezone, c.Timezone)
+
+	switch o.Format {
+	case "", "csv", "tsv", "xlsx":
+		if len(c.Columns) != 0 && !changed("columns") {
+			o.Columns = c.Columns
+		}
+	}
+
+	switch o.Format {
+	case "xlsx", "sqlite", "parquet", "es-bulk":
+	default:
+		set("time-format", &o.TimeFormat, c.TimeFormat)
+	}
+
+	switch o.Format {
+	case "xlsx", "sqlite":
+	default:
+		set("compress", &o.Compress, c.Compress)
+	}
+}
+
+// NewExporter validates the flags and opens the output. If --skip-existing is
+// set, the ids of the tweets in the output file are added to ids.
+func (o *OutputFlags) NewExporter(ids export.IdSet) (export.Exporter, error) {
+	if o.Append && o.Overwrite {
+		return nil, fmt.Errorf("--append and --overwrite cannot be used together")
+	}
+
+	if o.Format != "template" && len(o.Template)+len(o.TemplateHeader)+len(o.TemplateFooter) != 0 {
+		return nil, fmt.Errorf("--template requires --format template")
+	}
+
+	if o.Format != "" && o.Format != "csv" && o.Format != "tsv" {
+		if name, ok := o.findCsvFlag(); ok {
+			return nil, fmt.Errorf("--%s cannot be used with %s output", name, o.Format)
+		}
+	}
+
+	switch o.Format {
+	case "sqlite":
+		return o.newSqliteExporter()
+	case "parquet":
+		return o.newParquetExporter()
+	case "geojson", "kml":
+		return o.newGeoExporter()
+	case "es-bulk":
+		return o.newEsBulkExporter()
+	case "xlsx":
+		return o.newXlsxExporter()
+	case "template":
+		return o.newTemplateExporter()
+	case "html":
+		return o.newHtmlExporter()
+	}
+
+	if o.SkipExisting && !o.Append {
+		return nil, fmt.Errorf("--skip-existing requires --append")
+	}
+
+	rotation, err := o.Rotation()
+	if err != nil {
+		return nil, err
+	}
+
+	if !rotation.IsZero() {
+		if export.IsStdout(o.Out) {
+			return nil, fmt.Errorf("rotating output requires --out")
+		}
+
+		if o.SkipExisting {
+			return nil, fmt.Errorf("--skip-existing cannot be used with a rotating output")
+		}
+	}
+
+	csv, err := o.CsvExporter()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(o.Columns) != 0 {
+		csv.Columns, err = export.ParseColumns(o.Columns)
+		if err != nil {
+			return nil, err
+		}
+
+		// The place coordinates are only set with the centroid fallback.
+		if name, ok := findPlaceColumn(csv.Columns); ok && csv.GeoFallback != export.GeoFallbackCentroid {
+			return nil, fmt.Errorf("--columns %s requires --geo-fallback centroid", name)
+		}
+	}
+
+	e := &export.CsvFileExporter{
+		Rotation:    rotation,
+		Name:        o.Out,
+		Mode:        o.Mode(),
+		Compression: o.Compression(),
+		Csv:         csv,
+		Logger:      o.Logger,
+	}
+
+	if o.SkipExisting {
+		e.Ids = ids
+	}
+
+	if err := e.Open(); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (o *OutputFlags) newSqliteExporter() (export.Exporter, error) {
+	if export.IsStdout(o.Out) {
+		return nil, fmt.Errorf("sqlite output requires --out")
+	}
+
+	rotation, err := o.Rotation()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case !rotation.IsZero():
+		return nil, fmt.Errorf("sqlite output cannot be rotated")
+	case o.Compression() != export.CompressionNone:
+		return nil, fmt.Errorf("sqlite output cannot be compressed")
+	case o.SkipExisting:
+		return nil, fmt.Errorf("--skip-existing cannot be used with sqlite output, which updates existing tweets")
+	case len(o.Columns) != 0:
+		return nil, fmt.Errorf("--columns cannot be used with sqlite output")
+	}
+
+	e := &export.SqliteExporter{Name: o.Out, Mode: o.Mode(), Logger: o.Logger}
+	if err := e.Open(); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (o *OutputFlags) newParquetExporter() (export.Exporter, error) {
+	if export.IsStdout(o.Out) {
+		return nil, fmt.Errorf("parquet output requires --out")
+	}
+
+	rotation, err := o.Rotation()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case !rotation.IsZero():
+		return nil, fmt.Errorf("parquet output cannot be rotated")
+	case o.Append:
+		return nil, fmt.Errorf("parquet output cannot be appended to")
+	case len(o.Columns) != 0:
+		return nil, fmt.Errorf("--columns cannot be used with parquet output")
+	case o.SkipExisting:
+		return nil, fmt.Errorf("--skip-existing cannot be used with parquet output")
+	}
+
+	e := &export.ParquetExporter{Name: o.Out, Mode: o.Mode(), Logger: o.Logger}
+	if len(o.Compress) != 0 && o.Compress != "auto" {
+		e.Compression = export.Compression(o.Compress)
+	}
+
+	if len(o.RowGroupSize) != 0 {
+		e.RowGroupSize, err = export.ParseSize(o.RowGroupSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := e.Open(); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (o *OutputFlags) newGeoExporter() (export.Exporter, error) {
+	rotation, err := o.Rotation()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case !rotation.IsZero():
+		return nil, fmt.Errorf("%s output cannot be rotated", o.Format)
+	case o.Append:
+		return nil, fmt.Errorf("%s output cannot be appended to", o.Format)
+	case len(o.Columns) != 0:
+		return nil, fmt.Errorf("--columns cannot be used with %s output", o.Format)
+	case o.SkipExisting:
+		return nil, fmt.Errorf("--skip-existing cannot be used with %s output", o.Format)
+	}
+
+	places, err := export.ParseGeoPlaces(o.GeoPlaces)
+	if err != nil {
+		return nil, err
+	}
+
+	timeFormat, err := export.ParseTimeFormat(o.TimeFormat, o.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &export.GeoExporter{
+		Name:        o.Out,
+		Format:      o.Format,
+		Mode:        o.Mode(),
+		Compression: o.Compression(),
+		Places:      places,
+		TimeFormat:  timeFormat,
+		Logger:      o.Logger,
+	}
+
+	if err := e.Open(); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (o *OutputFlags) newEsBulkExporter() (export.Exporter, error) {
+	rotation, err := o.Rotation()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case !rotation.IsZero():
+		return nil, fmt.Errorf("es-bulk output cannot be rotated")
+	case len(o.Columns) != 0:
+		return nil, fmt.Errorf("--columns cannot be used with es-bulk output")
+	case o.SkipExisting:
+		return nil, fmt.Errorf("--skip-existing cannot be used with es-bulk output")
+	case len(o.EsUrl) != 0 && !export.IsStdout(o.Out):
+		return nil, fmt.Errorf("--es-url cannot be used with --out")
+	}
+
+	pattern := o.EsIndex
+	if len(pattern) == 0 {
+		pattern = export.DefaultEsIndex
+	}
+
+	index, err := export.ParseIndexPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	timeFormat, err := export.ParseTimeFormat("", o.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &export.EsBulkExporter{
+		Name:        o.Out,
+		Mode:        o.Mode(),
+		Compression: o.Compression(),
+		Index:       index,
+		Location:    timeFormat.Location,
+		BatchSize:   o.EsBatchSize,
+		Logger:      o.Logger,
+	}
+
+	if len(o.EsUrl) != 0 {
+		e.Client, err = export.NewEsBulkClient(o.EsUrl, export.DefaultEsMaxRetryAttempts)
+		if err != nil {
+			return nil, err
+		}
+
+		e.Client.Logger = o.Logger
+	}
+
+	if err := e.Open(); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (o *OutputFlags) newXlsxExporter() (export.Exporter, error) {
+	if export.IsStdout(o.Out) {
+		return nil, fmt.Errorf("xlsx output requires --out")
+	}
+
+	rotation, err := o.Rotation()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case !rotation.IsZero():
+		return nil, fmt.Errorf("xlsx output cannot be rotated")
+	case o.Append:
+		return nil, fmt.Errorf("xlsx output cannot be appended to")
+	case o.Compression() != export.CompressionNone:
+		return nil, fmt.Errorf("xlsx output cannot be compressed")
+	case len(o.TimeFormat) != 0:
+		return nil, fmt.Errorf("--time-format cannot be used with xlsx output, which writes date cells")
+	case o.SkipExisting:
+		return nil, fmt.Errorf("--skip-existing cannot be used with xlsx output")
+	}
+
+	timeFormat, err := export.ParseTimeFormat("", o.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &export.XlsxExporter{Name: o.Out, Mode: o.Mode(), Location: timeFormat.Location, Logger: o.Logger}
+	if len(o.Columns) != 0 {
+		e.Columns, err = export.ParseColumns(o.Columns)
+		if err != nil {
+			return nil, err
+		}
+
+		if name, ok := findPlaceColumn(e.Columns); ok {
+			return nil, fmt.Errorf("--columns %s cannot be used with xlsx output, which has no --geo-fallback", name)
+		}
+	}
+
+	if err := e.Open(); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (o *OutputFlags) newTemplateExporter() (export.Exporter, error) {
+	rotation, err := o.Rotation()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(o.Template) == 0:
+		return nil, fmt.Errorf("template output requires --template")
+	case !rotation.IsZero():
+		return nil, fmt.Errorf("template output cannot be rotated")
+	case len(o.Columns) != 0:
+		return nil, fmt.Errorf("--columns cannot be used with template output")
+	case o.SkipExisting:
+		return nil, fmt.Errorf("--skip-existing cannot be used with template output")
+	}
+
+	timeFormat, err := export.ParseTimeFormat(o.TimeFormat, o.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &export.TemplateExporter{Name: o.Out, Mode: o.Mode(), Compression: o.Compression(), Logger: o.Logger}
+	e.Template, err = export.ParseTemplateFile(o.Template, timeFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(o.TemplateHeader) != 0 {
+		e.Header, err = export.ParseTemplateFile(o.TemplateHeader, timeFormat)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(o.TemplateFooter) != 0 {
+		e.Footer, err = export.ParseTemplateFile(o.TemplateFooter, timeFormat)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := e.Open(); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (o *OutputFlags) newHtmlExporter() (export.Exporter, error) {
+	rotation, err := o.Rotation()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case !rotation.IsZero():
+		return nil, fmt.Errorf("html output cannot be rotated")
+	case o.Append:
+		return nil, fmt.Errorf("html output cannot be appended to")
+	case len(o.Columns) != 0:
+		return nil, fmt.Errorf("--columns cannot be used with html output")
+	case o.SkipExisting:
+		return nil, fmt.Errorf("--skip-existing cannot be used with html output")
+	}
+
+	timeFormat, err := export.ParseTimeFormat(o.TimeFormat, o.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &export.HtmlExporter{
+		Name:        o.Out,
+		Mode:        o.Mode(),
+		Compression: o.Compression(),
+		Title:       o.HtmlTitle,
+		TimeFormat:  timeFormat,
+		Logger:      o.Logger,
+	}
+
+	if err := e.Open(); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// findCsvFlag returns the name of the first flag set to a value that only csv
+// and tsv output support.
+func (o *OutputFlags) findCsvFlag() (string, bool) {
+	switch {
+	case len(o.Delimiter) != 0:
+		return "delimiter", true
+	case len(o.Quote) != 0:
+		return "quote", true
+	case o.Bom:
+		return "bom", true
+	case o.Crlf:
+		return "crlf", true
+	case o.EscapeNewlines:
+		return "escape-newlines", true
+	case !o.Header:
+		return "header", true
+	case len(o.SanitizeFormulas) != 0 && o.SanitizeFormulas != "auto":
+		return "sanitize-formulas", true
+	case len(o.GeoFallback) != 0 && o.GeoFallback != string(export.GeoFallbackNone):
+		return "geo-fallback", true
+	}
+
+	return "", false
+}
+
+// findPlaceColumn returns the name of the first place centroid column in columns.
+func findPlaceColumn(columns []export.Column) (string, bool) {
+	for _, c := range columns {
+		if c.Name == "place_latitude" || c.Name == "place_longitude" {
+			return c.Name, true
+		}
+	}
+
+	return "", false
+}
+
+// PrintColumns prints the available columns and their types.
+func PrintColumns(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTYPE")
+	for _, c := range export.Columns {
+		fmt.Fprintf(w, "%s\t%s\n", c.Name, c.Type)
+	}
+	w.Flush()
+}
@@ -0,0 +1,256 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build small
+// +build small
+
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/akiomik/squawks/config"
+)
+
+func TestOutputFlagsCsvExporter(t *testing.T) {
+	examples := map[string]struct {
+		flags            OutputFlags
+		terminal         bool
+		expectedComma    rune
+		expectedSanitize bool
+		msg              string
+	}{
+		"csv-file": {
+			flags:            OutputFlags{Out: "out.csv", Header: true, SanitizeFormulas: "auto"},
+			terminal:         false,
+			expectedComma:    0,
+			expectedSanitize: true,
+			msg:              "",
+		},
+		"compressed-csv-file": {
+			flags:            OutputFlags{Out: "out.CSV.gz", Header: true, SanitizeFormulas: "auto"},
+			terminal:         false,
+			expectedComma:    0,
+			expectedSanitize: true,
+			msg:              "",
+		},
+		"txt-file": {
+			flags:            OutputFlags{Out: "out.txt", Header: true, SanitizeFormulas: "auto"},
+			terminal:         false,
+			expectedComma:    0,
+			expectedSanitize: true,
+			msg:              "",
+		},
+		"stdout": {
+			flags:            OutputFlags{Out: "", Header: true, SanitizeFormulas: "auto"},
+			terminal:         false,
+			expectedComma:    0,
+			expectedSanitize: true,
+			msg:              "",
+		},
+		"terminal": {
+			flags:            OutputFlags{Out: "-", Header: true, SanitizeFormulas: "auto"},
+			terminal:         true,
+			expectedComma:    0,
+			expectedSanitize: false,
+			msg:              "",
+		},
+		"terminal-file": {
+			flags:            OutputFlags{Out: "out.csv", Header: true, SanitizeFormulas: "auto"},
+			terminal:         true,
+			expectedComma:    0,
+			expectedSanitize: true,
+			msg:              "",
+		},
+		"tsv": {
+			flags:            OutputFlags{Out: "out.csv", Format: "tsv", Header: true, SanitizeFormulas: "auto"},
+			terminal:         false,
+			expectedComma:    '\t',
+			expectedSanitize: true,
+			msg:              "",
+		},
+		"always": {
+			flags:            OutputFlags{Out: "-", Delimiter: ";", Header: true, SanitizeFormulas: "always"},
+			terminal:         true,
+			expectedComma:    ';',
+			expectedSanitize: true,
+			msg:              "",
+		},
+		"never": {
+			flags:            OutputFlags{Out: "out.csv", Header: true, SanitizeFormulas: "never"},
+			terminal:         false,
+			expectedComma:    0,
+			expectedSanitize: false,
+			msg:              "",
+		},
+		"unknown-geo-fallback": {
+			flags:            OutputFlags{Out: "out.csv", GeoFallback: "bbox"},
+			terminal:         false,
+			expectedComma:    0,
+			expectedSanitize: false,
+			msg:              "unknown geo fallback: bbox",
+		},
+		"unsupported-format": {
+			flags:            OutputFlags{Out: "out.json", Format: "json"},
+			terminal:         false,
+			expectedComma:    0,
+			expectedSanitize: false,
+			msg:              "unsupported format: json",
+		},
+	}
+
+	isTerminal := stdoutIsTerminal
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			stdoutIsTerminal = func() bool { return e.terminal }
+			defer func() { stdoutIsTerminal = isTerminal }()
+
+			actual, err := e.flags.CsvExporter()
+			if len(e.msg) == 0 {
+				assert.NoError(t, err)
+				assert.Equal(t, e.expectedComma, actual.Comma)
+				assert.Equal(t, e.expectedSanitize, actual.SanitizeFormulas)
+			} else {
+				assert.EqualError(t, err, e.msg)
+			}
+		})
+	}
+}
+
+func TestOutputFlagsApplyConfig(t *testing.T) {
+	c := config.ExportConfig{
+		Format:     "tsv",
+		Columns:    []string{"id"},
+		TimeFormat: "unix",
+		Timezone:   "Asia/Tokyo",
+		Compress:   "gzip",
+	}
+
+	examples := map[string]struct {
+		flags    OutputFlags
+		changed  []string
+		expected OutputFlags
+	}{
+		"unchanged": {
+			flags:    OutputFlags{Format: "csv", Compress: "auto"},
+			changed:  []string{},
+			expected: OutputFlags{Format: "tsv", Columns: []string{"id"}, TimeFormat: "unix", Timezone: "Asia/Tokyo", Compress: "gzip"},
+		},
+		"changed": {
+			flags:    OutputFlags{Format: "csv", Columns: []string{"username"}, Compress: "none"},
+			changed:  []string{"format", "columns", "compress"},
+			expected: OutputFlags{Format: "csv", Columns: []string{"username"}, TimeFormat: "unix", Timezone: "Asia/Tokyo", Compress: "none"},
+		},
+		"sqlite": {
+			flags:    OutputFlags{Format: "sqlite", Compress: "auto"},
+			changed:  []string{"format"},
+			expected: OutputFlags{Format: "sqlite", Timezone: "Asia/Tokyo", Compress: "auto"},
+		},
+		"xlsx": {
+			flags:    OutputFlags{Format: "xlsx", Compress: "auto"},
+			changed:  []string{"format"},
+			expected: OutputFlags{Format: "xlsx", Columns: []string{"id"}, Timezone: "Asia/Tokyo", Compress: "auto"},
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			changed := func(name string) bool {
+				for _, c := range e.changed {
+					if c == name {
+						return true
+					}
+				}
+
+				return false
+			}
+
+			e.flags.ApplyConfig(changed, c)
+			assert.Equal(t, e.expected, e.flags)
+		})
+	}
+}
+
+func TestOutputFlagsNewExporterError(t *testing.T) {
+	examples := map[string]struct {
+		flags OutputFlags
+		msg   string
+	}{
+		"sqlite-stdout": {
+			flags: OutputFlags{Format: "sqlite", Header: true},
+			msg:   "sqlite output requires --out",
+		},
+		"parquet-stdout": {
+			flags: OutputFlags{Format: "parquet", Header: true},
+			msg:   "parquet output requires --out",
+		},
+		"place-columns-without-centroid": {
+			flags: OutputFlags{Format: "csv", Columns: []string{"id", "place_latitude"}, Header: true},
+			msg:   "--columns place_latitude requires --geo-fallback centroid",
+		},
+		"place-columns-xlsx": {
+			flags: OutputFlags{Format: "xlsx", Out: "tweets.xlsx", Columns: []string{"place_longitude"}, Header: true},
+			msg:   "--columns place_longitude cannot be used with xlsx output, which has no --geo-fallback",
+		},
+		"xlsx-stdout": {
+			flags: OutputFlags{Format: "xlsx", Out: "-", Header: true},
+			msg:   "xlsx output requires --out",
+		},
+		"delimiter-parquet": {
+			flags: OutputFlags{Format: "parquet", Out: "tweets.parquet", Delimiter: ";", Header: true},
+			msg:   "--delimiter cannot be used with parquet output",
+		},
+		"bom-xlsx": {
+			flags: OutputFlags{Format: "xlsx", Out: "tweets.xlsx", Bom: true, Header: true},
+			msg:   "--bom cannot be used with xlsx output",
+		},
+		"no-header-geojson": {
+			flags: OutputFlags{Format: "geojson", Header: false},
+			msg:   "--header cannot be used with geojson output",
+		},
+		"sanitize-formulas-html": {
+			flags: OutputFlags{Format: "html", SanitizeFormulas: "always", Header: true},
+			msg:   "--sanitize-formulas cannot be used with html output",
+		},
+		"geo-fallback-sqlite": {
+			flags: OutputFlags{Format: "sqlite", Out: "tweets.db", GeoFallback: "centroid", Header: true},
+			msg:   "--geo-fallback cannot be used with sqlite output",
+		},
+		"skip-existing-parquet": {
+			flags: OutputFlags{Format: "parquet", Out: "tweets.parquet", SkipExisting: true, Header: true},
+			msg:   "--skip-existing cannot be used with parquet output",
+		},
+		"skip-existing-kml": {
+			flags: OutputFlags{Format: "kml", SkipExisting: true, Header: true},
+			msg:   "--skip-existing cannot be used with kml output",
+		},
+		"skip-existing-xlsx": {
+			flags: OutputFlags{Format: "xlsx", Out: "tweets.xlsx", SkipExisting: true, Header: true},
+			msg:   "--skip-existing cannot be used with xlsx output",
+		},
+		"skip-existing-html": {
+			flags: OutputFlags{Format: "html", SkipExisting: true, Header: true},
+			msg:   "--skip-existing cannot be used with html output",
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			_, err := e.flags.NewExporter(nil)
+			assert.EqualError(t, err, e.msg)
+		})
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package search
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/akiomik/squawks/api"
+	"github.com/akiomik/squawks/cmd/progress"
+	"github.com/akiomik/squawks/export"
+	"github.com/akiomik/squawks/logging"
+)
+
+// Pipeline exports the tweets of search results to the output, dropping
+// duplicates and reporting the progress and a summary on stderr. It is shared
+// by search tweets, watch, replay and the jobs of run.
+type Pipeline struct {
+	Output        *OutputFlags
+	Dedup         string
+	DedupCapacity uint64
+	Quiet         bool
+	// Since and Stats are reported by the progress.
+	Since time.Time
+	Stats func() api.Stats
+	// LogSearchErrors logs the errors of the results instead of returning them,
+	// for sources that go on after an error such as watch.
+	LogSearchErrors bool
+
+	// Tweets, Dropped and Skipped are the numbers of exported, duplicate and
+	// already exported tweets, set by Run. Dropped and Skipped are only counted
+	// when the export did not fail.
+	Tweets  int
+	Dropped uint64
+	Skipped uint64
+
+	exporter export.Exporter
+	ids      export.IdSet
+	existing export.MemoryIdSet
+}
+
+// Open validates the output flags and opens the output.
+func (p *Pipeline) Open() error {
+	ids, err := export.NewIdSet(p.Dedup, p.DedupCapacity)
+	if err != nil {
+		return err
+	}
+
+	// The tweets already in the output are kept apart from the duplicates, so
+	// that they are counted separately and --dedup none drops nothing else.
+	var existing export.IdSet
+	if p.Output.SkipExisting {
+		p.existing = export.NewMemoryIdSet()
+		existing = p.existing
+	}
+
+	// Writing to a closed pipe should fail with EPIPE rather than kill the process.
+	signal.Ignore(syscall.SIGPIPE)
+
+	e, err := p.Output.NewExporter(existing)
+	if err != nil {
+		return err
+	}
+
+	p.exporter = e
+	p.ids = ids
+	return nil
+}
+
+// Close closes the output.
+func (p *Pipeline) Close() error {
+	if p.exporter == nil {
+		return nil
+	}
+
+	return p.exporter.Close()
+}
+
+// Run exports the tweets of the results of source, which is stopped by
+// canceling its context when the export fails. The first error of the results
+// is returned once everything found before it has been exported, so that the
+// output can be closed before exiting.
+func (p *Pipeline) Run(ctx context.Context, source func(ctx context.Context) <-chan *api.SearchResult) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	total := 0
+	searched := make(chan error, 1)
+	ch := make(chan []export.Record)
+	go func() {
+		defer close(ch)
+
+		var err error
+		defer func() { searched <- err }()
+
+		results := source(ctx)
+		defer func() {
+			// Sources that do not stop with ctx are drained in the background.
+			go func() {
+				for range results {
+				}
+			}()
+		}()
+
+		for res := range results {
+			if res.Error != nil {
+				if p.LogSearchErrors {
+					logging.Default().Error("failed to search", "error", res.Error)
+				} else if err == nil {
+					err = res.Error
+				}
+
+				continue
+			}
+
+			records := export.NewRecordsFromAdaptive(res.Adaptive)
+			select {
+			case ch <- records:
+				total += len(records)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	records := (<-chan []export.Record)(ch)
+
+	var d *export.Deduplicator
+	if p.ids != nil {
+		d = export.NewDeduplicator(p.ids)
+		records = d.Deduplicate(ctx, records)
+	}
+
+	var sk *export.Skipper
+	if p.existing != nil {
+		sk = export.NewSkipper(p.existing)
+		records = sk.Skip(ctx, records)
+	}
+
+	var pr *progress.Progress
+	if !p.Quiet {
+		pr = progress.New(progress.Stderr, p.Stats)
+		pr.Since = p.Since
+		records = pr.Track(records)
+		pr.Start()
+	}
+
+	err := <-p.exporter.Export(records)
+	if err != nil {
+		cancel()
+	}
+
+	searchErr := <-searched
+	if pr != nil {
+		pr.Stop()
+	}
+
+	p.Tweets = total
+	if err == nil {
+		if d != nil {
+			p.Tweets -= int(d.Dropped)
+			p.Dropped = d.Dropped
+		}
+
+		if sk != nil {
+			p.Tweets -= int(sk.Skipped)
+			p.Skipped = sk.Skipped
+		}
+	}
+
+	// The reader of the pipe exited early (e.g. head), which is not a failure.
+	if export.IsBrokenPipe(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !p.Quiet {
+		var details []string
+		if d != nil {
+			details = append(details, fmt.Sprintf("%d duplicates dropped", p.Dropped))
+		}
+
+		if sk != nil {
+			details = append(details, fmt.Sprintf("%d already in output", p.Skipped))
+		}
+
+		if len(details) == 0 {
+			fmt.Fprintf(os.Stderr, "Exported %d tweets\n", p.Tweets)
+		} else {
+			fmt.Fprintf(os.Stderr, "Exported %d tweets (%s)\n", p.Tweets, strings.Join(details, ", "))
+		}
+	}
+
+	return searchErr
+}
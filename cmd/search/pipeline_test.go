@@ -0,0 +1,170 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build medium
+// +build medium
+
+package search
+
+import (
+	"context"
+	stdjson "encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/akiomik/squawks/api"
+	"github.com/akiomik/squawks/api/json"
+)
+
+func newAdaptive(t *testing.T, id string) *json.Adaptive {
+	page := `{
+  "globalObjects": {
+    "tweets": { "` + id + `": { "id": ` + id + `, "user_id": 10, "full_text": "foo", "created_at": "Sun Sep 06 12:00:00 +0000 2020" } },
+    "users": { "10": { "id": 10, "screen_name": "watson" } }
+  },
+  "timeline": {
+    "instructions": [{
+      "addEntries": {
+        "entries": [{
+          "entryId": "sq-I-t-` + id + `",
+          "sortIndex": "` + id + `",
+          "content": { "item": { "content": { "tweet": { "id": "` + id + `", "displayType": "Tweet" } } } }
+        }]
+      }
+    }]
+  }
+}`
+
+	var j json.Adaptive
+	assert.NoError(t, stdjson.Unmarshal([]byte(page), &j))
+	return &j
+}
+
+func TestPipelineRun(t *testing.T) {
+	examples := map[string]struct {
+		results         []*api.SearchResult
+		dedup           string
+		logSearchErrors bool
+		existing        string
+		expected        string
+		expectedTweets  int
+		expectedDropped uint64
+		expectedSkipped uint64
+		msg             string
+	}{
+		"dedup": {
+			results:         []*api.SearchResult{{Adaptive: newAdaptive(t, "2")}, {Adaptive: newAdaptive(t, "1")}, {Adaptive: newAdaptive(t, "1")}},
+			dedup:           "memory",
+			logSearchErrors: false,
+			existing:        "",
+			expected:        "id,username\n2,watson\n1,watson\n",
+			expectedTweets:  2,
+			expectedDropped: 1,
+			expectedSkipped: 0,
+			msg:             "",
+		},
+		"source-error": {
+			results:         []*api.SearchResult{{Adaptive: newAdaptive(t, "2")}, {Error: errors.New("failed to search")}},
+			dedup:           "memory",
+			logSearchErrors: false,
+			existing:        "",
+			expected:        "id,username\n2,watson\n",
+			expectedTweets:  1,
+			expectedDropped: 0,
+			expectedSkipped: 0,
+			msg:             "failed to search",
+		},
+		"log-search-errors": {
+			results:         []*api.SearchResult{{Error: errors.New("failed to search")}, {Adaptive: newAdaptive(t, "1")}},
+			dedup:           "memory",
+			logSearchErrors: true,
+			existing:        "",
+			expected:        "id,username\n1,watson\n",
+			expectedTweets:  1,
+			expectedDropped: 0,
+			expectedSkipped: 0,
+			msg:             "",
+		},
+		"skip-existing": {
+			results:         []*api.SearchResult{{Adaptive: newAdaptive(t, "3")}, {Adaptive: newAdaptive(t, "2")}, {Adaptive: newAdaptive(t, "1")}, {Adaptive: newAdaptive(t, "1")}},
+			dedup:           "memory",
+			logSearchErrors: false,
+			existing:        "id,username\n3,watson\n2,watson\n",
+			expected:        "id,username\n3,watson\n2,watson\n1,watson\n",
+			expectedTweets:  1,
+			expectedDropped: 1,
+			expectedSkipped: 2,
+			msg:             "",
+		},
+		"skip-existing-without-dedup": {
+			results:         []*api.SearchResult{{Adaptive: newAdaptive(t, "2")}, {Adaptive: newAdaptive(t, "1")}, {Adaptive: newAdaptive(t, "1")}},
+			dedup:           "none",
+			logSearchErrors: false,
+			existing:        "id,username\n2,watson\n",
+			expected:        "id,username\n2,watson\n1,watson\n1,watson\n",
+			expectedTweets:  2,
+			expectedDropped: 0,
+			expectedSkipped: 1,
+			msg:             "",
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "out.csv")
+			output := OutputFlags{Out: out, Format: "csv", Header: true, SanitizeFormulas: "auto", Columns: []string{"id", "username"}}
+			if len(e.existing) != 0 {
+				assert.NoError(t, os.WriteFile(out, []byte(e.existing), 0644))
+				output.Append = true
+				output.SkipExisting = true
+			}
+
+			p := &Pipeline{Output: &output, Dedup: e.dedup, Quiet: true, LogSearchErrors: e.logSearchErrors}
+			if !assert.NoError(t, p.Open()) {
+				return
+			}
+
+			err := p.Run(context.Background(), func(ctx context.Context) <-chan *api.SearchResult {
+				ch := make(chan *api.SearchResult)
+				go func() {
+					defer close(ch)
+
+					for _, res := range e.results {
+						ch <- res
+					}
+				}()
+
+				return ch
+			})
+			if len(e.msg) == 0 {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, e.msg)
+			}
+
+			assert.NoError(t, p.Close())
+			assert.Equal(t, e.expectedTweets, p.Tweets)
+			assert.Equal(t, e.expectedDropped, p.Dropped)
+			assert.Equal(t, e.expectedSkipped, p.Skipped)
+
+			actual, err := os.ReadFile(out)
+			assert.NoError(t, err)
+			assert.Equal(t, e.expected, string(actual))
+		})
+	}
+}
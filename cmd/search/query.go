@@ -0,0 +1,74 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package search
+
+import (
+	"github.com/spf13/pflag"
+
+	"github.com/akiomik/squawks/api"
+	"github.com/akiomik/squawks/cmd/flags"
+	"github.com/akiomik/squawks/config"
+)
+
+func AddQueryFlags(fs *pflag.FlagSet, q *api.Query) {
+	flags.StringSliceEnumVarP(fs, &q.Excludes, "exclude", "", []string{}, "exclude tweets by type of tweet", []string{"hashtags", "nativeretweets", "retweets", "replies"})
+	flags.StringSliceEnumVarP(fs, &q.Filters, "filter", "", []string{}, "find tweets by type of account or tweet", []string{"verified", "follows", "media", "images", "twimg", "videos", "periscope", "vine", "consumer_video", "pro_video", "native_video", "links", "hashtags", "nativeretweets", "retweets", "replies", "safe", "news"})
+	fs.StringVarP(&q.From, "from", "", "", "find tweets sent from a certain user")
+	fs.StringVarP(&q.Geocode, "geocode", "", "", "find tweets sent from certain coordinates (e.g. 35.6851508,139.7526768,0.1km)")
+	flags.StringSliceEnumVarP(fs, &q.Includes, "include", "", []string{}, "include tweets by type of tweet", []string{"hashtags", "nativeretweets", "retweets", "replies"})
+	fs.StringVarP(&q.Lang, "lang", "", "", "find tweets by a certain language (e.g. en, es, fr)")
+	fs.StringVarP(&q.Near, "near", "", "", "find tweets nearby a certain location (e.g. tokyo)")
+	fs.StringVarP(&q.Text, "query", "q", "", "query text to search")
+	fs.StringVarP(&q.Since, "since", "", "", "find tweets since a certain day (e.g. 2014-07-21)")
+	fs.StringVarP(&q.To, "to", "", "", "find tweets sent in reply to a certain user")
+	fs.StringVarP(&q.Until, "until", "", "", "find tweets until a certain day (e.g. 2020-09-06)")
+	fs.StringVarP(&q.Url, "url", "", "", "find tweets containing a certain url (e.g. www.example.com)")
+	fs.StringVarP(&q.Within, "within", "", "", "find tweets nearby a certain location (e.g. 1km)")
+}
+
+// ApplySearch fills the query with a saved search. Flags given on the command line take precedence.
+func ApplySearch(fs *pflag.FlagSet, q *api.Query, s config.Search) {
+	flags.SetSliceIfUnchanged(fs, "exclude", &q.Excludes, s.Excludes)
+	flags.SetSliceIfUnchanged(fs, "filter", &q.Filters, s.Filters)
+	flags.SetIfUnchanged(fs, "from", &q.From, s.From)
+	flags.SetIfUnchanged(fs, "geocode", &q.Geocode, s.Geocode)
+	flags.SetSliceIfUnchanged(fs, "include", &q.Includes, s.Includes)
+	flags.SetIfUnchanged(fs, "lang", &q.Lang, s.Lang)
+	flags.SetIfUnchanged(fs, "near", &q.Near, s.Near)
+	flags.SetIfUnchanged(fs, "query", &q.Text, s.Text)
+	flags.SetIfUnchanged(fs, "since", &q.Since, s.Since)
+	flags.SetIfUnchanged(fs, "to", &q.To, s.To)
+	flags.SetIfUnchanged(fs, "until", &q.Until, s.Until)
+	flags.SetIfUnchanged(fs, "url", &q.Url, s.Url)
+	flags.SetIfUnchanged(fs, "within", &q.Within, s.Within)
+}
+
+func NewQuery(s config.Search) api.Query {
+	return api.Query{
+		Text:     s.Text,
+		Since:    s.Since,
+		Until:    s.Until,
+		From:     s.From,
+		To:       s.To,
+		Lang:     s.Lang,
+		Filters:  s.Filters,
+		Includes: s.Includes,
+		Excludes: s.Excludes,
+		Geocode:  s.Geocode,
+		Near:     s.Near,
+		Within:   s.Within,
+		Url:      s.Url,
+	}
+}
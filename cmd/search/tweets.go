@@ -15,108 +15,122 @@
 package search
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/akiomik/squawks/api"
 	"github.com/akiomik/squawks/cmd/flags"
+	"github.com/akiomik/squawks/config"
 	"github.com/akiomik/squawks/export"
 )
 
 var (
-	out       string
-	text      string
-	since     string
-	until     string
-	from      string
-	to        string
-	lang      string
-	filters   []string
-	includes  []string
-	excludes  []string
-	geocode   string
-	url       string
-	near      string
-	within    string
-	top       bool
-	userAgent string
+	output        OutputFlags
+	q             api.Query
+	top           bool
+	mode          string
+	pageSize      uint
+	dedup         string
+	dedupCapacity uint64
+	quiet         bool
+	profile       string
+	userAgent     string
+	rawDir        string
 )
 
 func NewTweetsCommand() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "tweets --out FILENAME",
+		Use:   "tweets [--out FILENAME]",
 		Short: "Search for tweets",
-		Run: func(cmd *cobra.Command, args []string) {
-			q := api.Query{
-				Text:     text,
-				Since:    since,
-				Until:    until,
-				From:     from,
-				To:       to,
-				Lang:     lang,
-				Filters:  filters,
-				Includes: includes,
-				Excludes: excludes,
-				Geocode:  geocode,
-				Near:     near,
-				Within:   within,
-				Url:      url,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output.ListColumns {
+				PrintColumns(os.Stdout)
+				return nil
 			}
+
+			cmd.SilenceUsage = true
+
+			c := config.FromContext(cmd.Context())
+			if len(profile) != 0 {
+				s, err := c.FindSearch(profile)
+				if err != nil {
+					return err
+				}
+
+				if _, err := api.SearchMode(s.Mode).Params(); err != nil {
+					return fmt.Errorf("saved search %s: %w", profile, err)
+				}
+
+				ApplySearch(cmd.Flags(), &q, s)
+				flags.SetIfUnchanged(cmd.Flags(), "mode", &mode, s.Mode)
+				flags.SetIfUnchanged(cmd.Flags(), "page-size", &pageSize, s.PageSize)
+			}
+
+			output.ApplyConfig(cmd.Flags().Changed, c.Export)
+			flags.SetIfUnchanged(cmd.Flags(), "dedup", &dedup, c.Export.Dedup)
+			flags.SetIfUnchanged(cmd.Flags(), "dedup-capacity", &dedupCapacity, c.Export.DedupCapacity)
+
 			if q.IsEmpty() {
-				fmt.Fprintln(os.Stderr, "Error: One or more queries are required")
-				os.Exit(1)
+				return fmt.Errorf("one or more queries are required")
 			}
 
-			f, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-				os.Exit(1)
+			if top {
+				mode = string(api.SearchModeTop)
 			}
-			defer f.Close()
 
-			client := api.NewClient()
-			if len(userAgent) > 0 {
-				client.UserAgent = userAgent
+			if pageSize == 0 {
+				return fmt.Errorf("--page-size must be greater than 0")
 			}
 
-			ch := make(chan []export.Record)
-			go func() {
-				defer close(ch)
+			pl := &Pipeline{Output: &output, Dedup: dedup, DedupCapacity: dedupCapacity, Quiet: quiet}
+			if len(q.Since) != 0 {
+				pl.Since, _ = time.Parse("2006-01-02", q.Since)
+			}
 
-				opts := api.SearchOptions{Query: q, Top: top}
-				for res := range client.SearchAll(opts) {
-					if res.Error != nil {
-						fmt.Fprintln(os.Stderr, "Error: %w", res.Error)
-						os.Exit(1)
-					}
+			if err := pl.Open(); err != nil {
+				return err
+			}
+			defer pl.Close()
 
-					ch <- export.NewRecordsFromAdaptive(res.Adaptive)
+			client := api.NewClientWithConfig(c.Client)
+			if len(userAgent) > 0 {
+				client.UserAgent = userAgent
+			}
+
+			if len(rawDir) != 0 {
+				archive, err := api.OpenArchive(rawDir)
+				if err != nil {
+					return err
 				}
-			}()
+				defer archive.Close()
+
+				client.Archive = archive
+			}
 
-			<-export.ExportCsv(f, ch)
+			pl.Stats = client.Stats
+			opts := api.SearchOptions{Query: q, Mode: api.SearchMode(mode), PageSize: pageSize}
+			return pl.Run(cmd.Context(), func(ctx context.Context) <-chan *api.SearchResult {
+				return client.SearchAllContext(ctx, opts)
+			})
 		},
 	}
 
-	flags.StringSliceEnumVarP(cmd.Flags(), &excludes, "exclude", "", []string{}, "exclude tweets by type of tweet", []string{"hashtags", "nativeretweets", "retweets", "replies"})
-	flags.StringSliceEnumVarP(cmd.Flags(), &filters, "filter", "", []string{}, "find tweets by type of account or tweet", []string{"verified", "follows", "media", "images", "twimg", "videos", "periscope", "vine", "consumer_video", "pro_video", "native_video", "links", "hashtags", "nativeretweets", "retweets", "replies", "safe", "news"})
-	cmd.Flags().StringVarP(&from, "from", "", "", "find tweets sent from a certain user")
-	cmd.Flags().StringVarP(&geocode, "geocode", "", "", "find tweets sent from certain coordinates (e.g. 35.6851508,139.7526768,0.1km)")
-	flags.StringSliceEnumVarP(cmd.Flags(), &includes, "include", "", []string{}, "include tweets by type of tweet", []string{"hashtags", "nativeretweets", "retweets", "replies"})
-	cmd.Flags().StringVarP(&lang, "lang", "", "", "find tweets by a certain language (e.g. en, es, fr)")
-	cmd.Flags().StringVarP(&near, "near", "", "", "find tweets nearby a certain location (e.g. tokyo)")
-	cmd.Flags().StringVarP(&out, "out", "o", "", "output csv filename (required)")
-	cmd.Flags().StringVarP(&text, "query", "q", "", "query text to search")
-	cmd.Flags().StringVarP(&since, "since", "", "", "find tweets since a certain day (e.g. 2014-07-21)")
-	cmd.Flags().StringVarP(&to, "to", "", "", "find tweets sent in reply to a certain user")
-	cmd.Flags().BoolVarP(&top, "top", "", false, "find top tweets")
-	cmd.Flags().StringVarP(&until, "until", "", "", "find tweets until a certain day (e.g. 2020-09-06)")
-	cmd.Flags().StringVarP(&url, "url", "", "", "find tweets containing a certain url (e.g. www.example.com)")
+	AddQueryFlags(cmd.Flags(), &q)
+	output.AddFlags(cmd.Flags())
+	flags.StringEnumVarP(cmd.Flags(), &dedup, "dedup", "", "memory", "drop duplicate tweets using an in-memory set or a bloom filter", []string{"memory", "bloom", "none"})
+	cmd.Flags().Uint64VarP(&dedupCapacity, "dedup-capacity", "", export.DefaultBloomCapacity, "expected number of tweets for --dedup bloom")
+	flags.StringEnumVarP(cmd.Flags(), &mode, "mode", "", string(api.SearchModeLatest), "search mode", []string{"latest", "top", "people", "photos", "videos"})
+	cmd.Flags().UintVarP(&pageSize, "page-size", "", api.DefaultPageSize, "number of tweets requested per page")
+	cmd.Flags().StringVarP(&profile, "profile", "", "", "use a saved search from the config file")
+	cmd.Flags().BoolVarP(&quiet, "quiet", "", false, "suppress progress and summary on stderr")
+	cmd.Flags().BoolVarP(&top, "top", "", false, "find top tweets (same as --mode top)")
 	cmd.Flags().StringVarP(&userAgent, "user-agent", "", "", "set custom user-agent")
-	cmd.Flags().StringVarP(&within, "within", "", "", "find tweets nearby a certain location (e.g. 1km)")
-	cmd.MarkFlagRequired("out")
+	cmd.Flags().StringVarP(&rawDir, "raw-dir", "", "", "save the raw responses to a directory, which can be exported again with squawks replay")
+	cmd.MarkFlagsMutuallyExclusive("top", "mode")
 
 	return cmd
 }
@@ -0,0 +1,85 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build medium
+// +build medium
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchTweetsCommand(t *testing.T) {
+	srv := newSearchServer(t)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("SQUAWKS_API_BASE_URL", srv.URL)
+	t.Setenv("SQUAWKS_SEARCH_BASE_URL", srv.URL)
+	t.Setenv("SQUAWKS_MAX_RETRY_ATTEMPTS", "0")
+
+	examples := map[string]struct {
+		args     []string
+		out      string
+		expected string
+		msg      string
+	}{
+		"csv": {
+			args:     []string{"--columns", "id,username"},
+			out:      "foo.csv",
+			expected: "id,username\n2,watson\n1,watson\n",
+			msg:      "",
+		},
+		"csv-with-search-error": {
+			args:     []string{"-q", "flaky", "--columns", "id,username"},
+			out:      "flaky.csv",
+			expected: "id,username\n2,watson\n1,watson\n",
+			msg:      "failed to search: 200: forbidden",
+		},
+		"parquet-with-search-error": {
+			args:     []string{"-q", "flaky", "--format", "parquet"},
+			out:      "flaky.parquet",
+			expected: "PAR1",
+			msg:      "failed to search: 200: forbidden",
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), e.out)
+			args := append([]string{"search", "tweets", "-q", "foo", "--quiet", "-o", path}, e.args...)
+
+			cmd := NewRootCommand()
+			cmd.SetArgs(args)
+			err := cmd.Execute()
+			if len(e.msg) == 0 {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, e.msg)
+			}
+
+			buf, err := os.ReadFile(path)
+			assert.NoError(t, err)
+			if filepath.Ext(path) == ".parquet" {
+				assert.True(t, strings.HasSuffix(string(buf), e.expected))
+			} else {
+				assert.Equal(t, e.expected, string(buf))
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build medium
+// +build medium
+
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newSearchServer returns a server answering searches with two pages, the first
+// with two tweets. Queries starting with fail are rejected.
+func newSearchServer(t *testing.T) *httptest.Server {
+	page := `{
+  "globalObjects": {
+    "tweets": {
+      "1": { "id": 1, "user_id": 10, "full_text": "foo", "created_at": "Sun Sep 06 12:00:00 +0000 2020" },
+      "2": { "id": 2, "user_id": 10, "full_text": "bar", "created_at": "Sun Sep 06 13:00:00 +0000 2020" }
+    },
+    "users": { "10": { "id": 10, "screen_name": "watson" } }
+  },
+  "timeline": {
+    "instructions": [{
+      "addEntries": {
+        "entries": [{
+          "entryId": "sq-I-t-2",
+          "sortIndex": "2",
+          "content": { "item": { "content": { "tweet": { "id": "2", "displayType": "Tweet" } } } }
+        }, {
+          "entryId": "sq-I-t-1",
+          "sortIndex": "1",
+          "content": { "item": { "content": { "tweet": { "id": "1", "displayType": "Tweet" } } } }
+        }, {
+          "entryId": "sq-cursor-bottom",
+          "content": { "operation": { "cursor": { "value": "scroll:deadbeef" } } }
+        }]
+      }
+    }]
+  }
+}`
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/1.1/guest/activate.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{ "guest_token": "1234" }`))
+	})
+	mux.HandleFunc("/i/api/2/search/adaptive.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasPrefix(r.URL.Query().Get("q"), "fail"):
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{ "errors": [{ "code": 200, "message": "forbidden" }] }`))
+		case len(r.URL.Query().Get("cursor")) == 0:
+			w.Write([]byte(page))
+		case strings.HasPrefix(r.URL.Query().Get("q"), "flaky"):
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{ "errors": [{ "code": 200, "message": "forbidden" }] }`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
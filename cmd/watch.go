@@ -0,0 +1,131 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/akiomik/squawks/api"
+	"github.com/akiomik/squawks/cmd/flags"
+	"github.com/akiomik/squawks/cmd/search"
+	"github.com/akiomik/squawks/config"
+	"github.com/akiomik/squawks/export"
+)
+
+func NewWatchCommand() *cobra.Command {
+	var (
+		output        search.OutputFlags
+		q             api.Query
+		pageSize      uint
+		interval      time.Duration
+		minInterval   time.Duration
+		maxInterval   time.Duration
+		profile       string
+		userAgent     string
+		dedup         string
+		dedupCapacity uint64
+		quiet         bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "watch [--out FILENAME]",
+		Short: "Watch for new tweets until interrupted",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output.ListColumns {
+				search.PrintColumns(os.Stdout)
+				return nil
+			}
+
+			cmd.SilenceUsage = true
+
+			c := config.FromContext(cmd.Context())
+			if len(profile) != 0 {
+				s, err := c.FindSearch(profile)
+				if err != nil {
+					return err
+				}
+
+				search.ApplySearch(cmd.Flags(), &q, s)
+				flags.SetIfUnchanged(cmd.Flags(), "page-size", &pageSize, s.PageSize)
+			}
+
+			output.ApplyConfig(cmd.Flags().Changed, c.Export)
+			flags.SetIfUnchanged(cmd.Flags(), "dedup", &dedup, c.Export.Dedup)
+			flags.SetIfUnchanged(cmd.Flags(), "dedup-capacity", &dedupCapacity, c.Export.DedupCapacity)
+
+			if q.IsEmpty() {
+				return fmt.Errorf("one or more queries are required")
+			}
+
+			if pageSize == 0 {
+				return fmt.Errorf("--page-size must be greater than 0")
+			}
+
+			if minInterval <= 0 || minInterval > maxInterval {
+				return fmt.Errorf("--min-interval must be greater than 0 and less than or equal to --max-interval")
+			}
+
+			pl := &search.Pipeline{Output: &output, Dedup: dedup, DedupCapacity: dedupCapacity, Quiet: quiet, LogSearchErrors: true}
+			if err := pl.Open(); err != nil {
+				return err
+			}
+			defer pl.Close()
+
+			client := api.NewClientWithConfig(c.Client)
+			if len(userAgent) > 0 {
+				client.UserAgent = userAgent
+			}
+
+			pl.Stats = client.Stats
+			opts := api.WatchOptions{
+				SearchOptions: api.SearchOptions{Query: q, PageSize: pageSize},
+				Interval:      interval,
+				MinInterval:   minInterval,
+				MaxInterval:   maxInterval,
+			}
+
+			// An interrupt only stops the watch, so that the tweets found before it
+			// are still exported.
+			stop := func() {}
+			defer func() { stop() }()
+
+			return pl.Run(cmd.Context(), func(ctx context.Context) <-chan *api.SearchResult {
+				ctx, stop = signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+				return client.Watch(ctx, opts)
+			})
+		},
+	}
+
+	search.AddQueryFlags(cmd.Flags(), &q)
+	output.AddFlags(cmd.Flags())
+	cmd.Flags().DurationVarP(&interval, "interval", "", api.DefaultWatchInterval, "initial poll interval")
+	cmd.Flags().DurationVarP(&maxInterval, "max-interval", "", api.DefaultWatchMaxInterval, "maximum poll interval when few tweets are found or rate limited")
+	cmd.Flags().DurationVarP(&minInterval, "min-interval", "", api.DefaultWatchMinInterval, "minimum poll interval when many tweets are found")
+	cmd.Flags().UintVarP(&pageSize, "page-size", "", api.DefaultPageSize, "number of tweets requested per page")
+	cmd.Flags().StringVarP(&profile, "profile", "", "", "use a saved search from the config file")
+	cmd.Flags().StringVarP(&userAgent, "user-agent", "", "", "set custom user-agent")
+	flags.StringEnumVarP(cmd.Flags(), &dedup, "dedup", "", "memory", "drop duplicate tweets using an in-memory set or a bloom filter", []string{"memory", "bloom", "none"})
+	cmd.Flags().Uint64VarP(&dedupCapacity, "dedup-capacity", "", export.DefaultBloomCapacity, "expected number of tweets for --dedup bloom")
+	cmd.Flags().BoolVarP(&quiet, "quiet", "", false, "suppress progress and summary on stderr")
+
+	return cmd
+}
@@ -0,0 +1,196 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Zero values mean "not configured" so that built-in defaults and flags apply.
+
+type ClientConfig struct {
+	AuthToken        string `yaml:"auth_token,omitempty"`
+	UserAgent        string `yaml:"user_agent,omitempty"`
+	MaxRetryAttempts *uint  `yaml:"max_retry_attempts,omitempty"`
+	Proxy            string `yaml:"proxy,omitempty"`
+	ApiBaseUrl       string `yaml:"api_base_url,omitempty"`
+	SearchBaseUrl    string `yaml:"search_base_url,omitempty"`
+	// WrapTransport wraps the http transport of clients, e.g. to record or
+	// replay their requests. It is set by flags rather than the config file.
+	WrapTransport func(http.RoundTripper) http.RoundTripper `yaml:"-"`
+}
+
+// ExportConfig holds the default export options. Options that do not apply to
+// the output format, such as columns of a sqlite output, are ignored.
+type ExportConfig struct {
+	Format        string   `yaml:"format,omitempty"`
+	Columns       []string `yaml:"columns,omitempty"`
+	TimeFormat    string   `yaml:"time_format,omitempty"`
+	Timezone      string   `yaml:"timezone,omitempty"`
+	Compress      string   `yaml:"compress,omitempty"`
+	Dedup         string   `yaml:"dedup,omitempty"`
+	DedupCapacity uint64   `yaml:"dedup_capacity,omitempty"`
+}
+
+type Search struct {
+	Text     string   `yaml:"query,omitempty"`
+	Since    string   `yaml:"since,omitempty"`
+	Until    string   `yaml:"until,omitempty"`
+	From     string   `yaml:"from,omitempty"`
+	To       string   `yaml:"to,omitempty"`
+	Lang     string   `yaml:"lang,omitempty"`
+	Filters  []string `yaml:"filters,omitempty"`
+	Includes []string `yaml:"includes,omitempty"`
+	Excludes []string `yaml:"excludes,omitempty"`
+	Geocode  string   `yaml:"geocode,omitempty"`
+	Near     string   `yaml:"near,omitempty"`
+	Within   string   `yaml:"within,omitempty"`
+	Url      string   `yaml:"url,omitempty"`
+	Mode     string   `yaml:"mode,omitempty"`
+	PageSize uint     `yaml:"page_size,omitempty"`
+}
+
+type Config struct {
+	Client   ClientConfig      `yaml:"client,omitempty"`
+	Export   ExportConfig      `yaml:"export,omitempty"`
+	Searches map[string]Search `yaml:"searches,omitempty"`
+}
+
+// DefaultPath returns $XDG_CONFIG_HOME/squawks/config.yaml, falling back to
+// ~/.config/squawks/config.yaml.
+func DefaultPath() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if len(dir) == 0 {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+
+		dir = filepath.Join(home, ".config")
+	}
+
+	return filepath.Join(dir, "squawks", "config.yaml"), nil
+}
+
+// Load reads the config file at path and applies environment variable overrides.
+// If path is empty, the default path is used and a missing file is not an error.
+func Load(path string) (*Config, error) {
+	c := &Config{}
+
+	optional := len(path) == 0
+	if optional {
+		defaultPath, err := DefaultPath()
+		if err != nil {
+			return nil, err
+		}
+
+		path = defaultPath
+	}
+
+	buf, err := os.ReadFile(path)
+	if err != nil && !(optional && errors.Is(err, fs.ErrNotExist)) {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	if err == nil {
+		if err := yaml.Unmarshal(buf, c); err != nil {
+			return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+		}
+	}
+
+	if err := c.ApplyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// ApplyEnv overrides the config with SQUAWKS_* environment variables.
+func (c *Config) ApplyEnv(lookup func(string) (string, bool)) error {
+	vars := map[string]*string{
+		"SQUAWKS_AUTH_TOKEN":      &c.Client.AuthToken,
+		"SQUAWKS_USER_AGENT":      &c.Client.UserAgent,
+		"SQUAWKS_PROXY":           &c.Client.Proxy,
+		"SQUAWKS_API_BASE_URL":    &c.Client.ApiBaseUrl,
+		"SQUAWKS_SEARCH_BASE_URL": &c.Client.SearchBaseUrl,
+		"SQUAWKS_FORMAT":          &c.Export.Format,
+		"SQUAWKS_TIME_FORMAT":     &c.Export.TimeFormat,
+		"SQUAWKS_TIMEZONE":        &c.Export.Timezone,
+		"SQUAWKS_COMPRESS":        &c.Export.Compress,
+		"SQUAWKS_DEDUP":           &c.Export.Dedup,
+	}
+
+	for name, p := range vars {
+		if v, ok := lookup(name); ok {
+			*p = v
+		}
+	}
+
+	if v, ok := lookup("SQUAWKS_MAX_RETRY_ATTEMPTS"); ok {
+		n, err := strconv.ParseUint(v, 10, 0)
+		if err != nil {
+			return fmt.Errorf("invalid SQUAWKS_MAX_RETRY_ATTEMPTS: %w", err)
+		}
+
+		attempts := uint(n)
+		c.Client.MaxRetryAttempts = &attempts
+	}
+
+	if v, ok := lookup("SQUAWKS_DEDUP_CAPACITY"); ok {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid SQUAWKS_DEDUP_CAPACITY: %w", err)
+		}
+
+		c.Export.DedupCapacity = n
+	}
+
+	return nil
+}
+
+func (c *Config) FindSearch(name string) (Search, error) {
+	s, ok := c.Searches[name]
+	if !ok {
+		return Search{}, fmt.Errorf("saved search not found: %s", name)
+	}
+
+	return s, nil
+}
+
+type contextKey struct{}
+
+func NewContext(ctx context.Context, c *Config) context.Context {
+	return context.WithValue(ctx, contextKey{}, c)
+}
+
+// FromContext returns the config stored by NewContext, or an empty config.
+func FromContext(ctx context.Context) *Config {
+	if ctx != nil {
+		if c, ok := ctx.Value(contextKey{}).(*Config); ok {
+			return c
+		}
+	}
+
+	return &Config{}
+}
@@ -0,0 +1,196 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build medium
+// +build medium
+
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultPath(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/tmp/xdg")
+	actual, err := DefaultPath()
+	assert.NoError(t, err)
+	assert.Equal(t, "/tmp/xdg/squawks/config.yaml", actual)
+
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("HOME", "/tmp/home")
+	actual, err = DefaultPath()
+	assert.NoError(t, err)
+	assert.Equal(t, "/tmp/home/.config/squawks/config.yaml", actual)
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	content := `client:
+  user_agent: my-user-agent
+  max_retry_attempts: 5
+  proxy: http://localhost:8080
+export:
+  format: tsv
+  columns: [id, full_text]
+  dedup: bloom
+searches:
+  refugees:
+    query: europe refugees
+    lang: en
+    filters: [verified, links]
+    mode: top
+`
+	err := os.WriteFile(path, []byte(content), 0644)
+	assert.NoError(t, err)
+
+	t.Setenv("SQUAWKS_USER_AGENT", "env-user-agent")
+
+	actual, err := Load(path)
+	assert.NoError(t, err)
+
+	attempts := uint(5)
+	expected := &Config{
+		Client: ClientConfig{
+			UserAgent:        "env-user-agent",
+			MaxRetryAttempts: &attempts,
+			Proxy:            "http://localhost:8080",
+		},
+		Export: ExportConfig{Format: "tsv", Columns: []string{"id", "full_text"}, Dedup: "bloom"},
+		Searches: map[string]Search{
+			"refugees": Search{
+				Text:    "europe refugees",
+				Lang:    "en",
+				Filters: []string{"verified", "links"},
+				Mode:    "top",
+			},
+		},
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func TestLoadWhenFileIsMissing(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	actual, err := Load("")
+	assert.NoError(t, err)
+	assert.Equal(t, &Config{}, actual)
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestLoadWhenFileIsInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte("client: [foo"), 0644)
+	assert.NoError(t, err)
+
+	_, err = Load(path)
+	assert.Error(t, err)
+}
+
+func TestApplyEnv(t *testing.T) {
+	examples := map[string]struct {
+		env         map[string]string
+		expected    *Config
+		expectError bool
+	}{
+		"none": {
+			env:         map[string]string{},
+			expected:    &Config{Client: ClientConfig{UserAgent: "file-user-agent"}},
+			expectError: false,
+		},
+		"all": {
+			env: map[string]string{
+				"SQUAWKS_AUTH_TOKEN":         "token",
+				"SQUAWKS_USER_AGENT":         "env-user-agent",
+				"SQUAWKS_MAX_RETRY_ATTEMPTS": "0",
+				"SQUAWKS_PROXY":              "http://localhost:8080",
+				"SQUAWKS_API_BASE_URL":       "http://localhost:8081",
+				"SQUAWKS_SEARCH_BASE_URL":    "http://localhost:8082",
+				"SQUAWKS_FORMAT":             "tsv",
+				"SQUAWKS_TIME_FORMAT":        "unix",
+				"SQUAWKS_TIMEZONE":           "Asia/Tokyo",
+				"SQUAWKS_COMPRESS":           "gzip",
+				"SQUAWKS_DEDUP":              "none",
+				"SQUAWKS_DEDUP_CAPACITY":     "1000",
+			},
+			expected: &Config{
+				Client: ClientConfig{
+					AuthToken:        "token",
+					UserAgent:        "env-user-agent",
+					MaxRetryAttempts: new(uint),
+					Proxy:            "http://localhost:8080",
+					ApiBaseUrl:       "http://localhost:8081",
+					SearchBaseUrl:    "http://localhost:8082",
+				},
+				Export: ExportConfig{
+					Format:        "tsv",
+					TimeFormat:    "unix",
+					Timezone:      "Asia/Tokyo",
+					Compress:      "gzip",
+					Dedup:         "none",
+					DedupCapacity: 1000,
+				},
+			},
+			expectError: false,
+		},
+		"invalid": {
+			env:         map[string]string{"SQUAWKS_MAX_RETRY_ATTEMPTS": "foo"},
+			expected:    &Config{Client: ClientConfig{UserAgent: "file-user-agent"}},
+			expectError: true,
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			actual := &Config{Client: ClientConfig{UserAgent: "file-user-agent"}}
+			err := actual.ApplyEnv(func(key string) (string, bool) {
+				v, ok := e.env[key]
+				return v, ok
+			})
+
+			if e.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, e.expected, actual)
+		})
+	}
+}
+
+func TestFindSearch(t *testing.T) {
+	c := &Config{Searches: map[string]Search{"foo": Search{Text: "foo"}}}
+
+	actual, err := c.FindSearch("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, Search{Text: "foo"}, actual)
+
+	_, err = c.FindSearch("bar")
+	assert.EqualError(t, err, "saved search not found: bar")
+}
+
+func TestContext(t *testing.T) {
+	c := &Config{Client: ClientConfig{UserAgent: "foo"}}
+
+	assert.Equal(t, c, FromContext(NewContext(context.Background(), c)))
+	assert.Equal(t, &Config{}, FromContext(context.Background()))
+}
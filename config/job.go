@@ -0,0 +1,145 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	DefaultJobConcurrency = 4
+	DefaultJobGuestTokens = 2
+	DefaultJobFormat      = "csv"
+)
+
+type Job struct {
+	Name   string `yaml:"name,omitempty"`
+	Out    string `yaml:"out"`
+	Format string `yaml:"format,omitempty"`
+	// OutputMode is one of create, overwrite or append.
+	OutputMode   string `yaml:"output_mode,omitempty"`
+	SkipExisting bool   `yaml:"skip_existing,omitempty"`
+	// Compress is one of auto, none, gzip or zstd.
+	Compress      string   `yaml:"compress,omitempty"`
+	RotateSize    string   `yaml:"rotate_size,omitempty"`
+	RotateRecords uint64   `yaml:"rotate_records,omitempty"`
+	PartitionBy   string   `yaml:"partition_by,omitempty"`
+	Columns       []string `yaml:"columns,omitempty"`
+	RowGroupSize  string   `yaml:"row_group_size,omitempty"`
+	// GeoPlaces is one of none, polygon or centroid.
+	GeoPlaces string `yaml:"geo_places,omitempty"`
+	// GeoFallback is one of none or centroid.
+	GeoFallback string `yaml:"geo_fallback,omitempty"`
+	EsIndex     string `yaml:"es_index,omitempty"`
+	// EsUrl posts es-bulk documents instead of writing them to Out.
+	EsUrl       string `yaml:"es_url,omitempty"`
+	EsBatchSize int    `yaml:"es_batch_size,omitempty"`
+	// Template, TemplateHeader and TemplateFooter are template files.
+	Template       string `yaml:"template,omitempty"`
+	TemplateHeader string `yaml:"template_header,omitempty"`
+	TemplateFooter string `yaml:"template_footer,omitempty"`
+	HtmlTitle      string `yaml:"html_title,omitempty"`
+	// Delimiter, Quote, Bom, Crlf, EscapeNewlines and Header set the csv dialect.
+	Delimiter      string `yaml:"delimiter,omitempty"`
+	Quote          string `yaml:"quote,omitempty"`
+	Bom            bool   `yaml:"bom,omitempty"`
+	Crlf           bool   `yaml:"crlf,omitempty"`
+	EscapeNewlines bool   `yaml:"escape_newlines,omitempty"`
+	Header         *bool  `yaml:"header,omitempty"`
+	// SanitizeFormulas is one of auto, always or never.
+	SanitizeFormulas string `yaml:"sanitize_formulas,omitempty"`
+	TimeFormat       string `yaml:"time_format,omitempty"`
+	Timezone         string `yaml:"timezone,omitempty"`
+	Search           `yaml:",inline"`
+}
+
+type JobFile struct {
+	Concurrency int   `yaml:"concurrency,omitempty"`
+	GuestTokens int   `yaml:"guest_tokens,omitempty"`
+	Jobs        []Job `yaml:"jobs"`
+}
+
+// LoadJobFile reads a job file and fills in defaults. Jobs without a name are named after their output.
+func LoadJobFile(path string) (*JobFile, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job file: %w", err)
+	}
+
+	f := &JobFile{}
+	if err := yaml.Unmarshal(buf, f); err != nil {
+		return nil, fmt.Errorf("failed to parse job file %s: %w", path, err)
+	}
+
+	if err := f.normalize(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (f *JobFile) normalize() error {
+	if f.Concurrency <= 0 {
+		f.Concurrency = DefaultJobConcurrency
+	}
+
+	if f.GuestTokens <= 0 {
+		f.GuestTokens = DefaultJobGuestTokens
+	}
+
+	if len(f.Jobs) == 0 {
+		return fmt.Errorf("no jobs defined")
+	}
+
+	names := map[string]bool{}
+	for i := range f.Jobs {
+		j := &f.Jobs[i]
+		if len(j.Out) == 0 && len(j.EsUrl) == 0 {
+			return fmt.Errorf("job %d: out is required", i+1)
+		}
+
+		if len(j.Name) == 0 {
+			j.Name = j.Out
+		}
+
+		if len(j.Name) == 0 {
+			j.Name = j.EsUrl
+		}
+
+		if len(j.Format) == 0 {
+			j.Format = DefaultJobFormat
+		}
+
+		switch j.OutputMode {
+		case "", "create", "overwrite", "append":
+		default:
+			return fmt.Errorf("job %d: unknown output_mode: %s", i+1, j.OutputMode)
+		}
+
+		if j.SkipExisting && j.OutputMode != "append" {
+			return fmt.Errorf("job %d: skip_existing requires output_mode append", i+1)
+		}
+
+		if names[j.Name] {
+			return fmt.Errorf("job %d: duplicate name: %s", i+1, j.Name)
+		}
+		names[j.Name] = true
+	}
+
+	return nil
+}
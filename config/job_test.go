@@ -0,0 +1,138 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build medium
+// +build medium
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadJobFile(t *testing.T) {
+	examples := map[string]struct {
+		content  string
+		expected *JobFile
+		msg      string
+	}{
+		"defaults": {
+			content: `jobs:
+  - out: foo.csv
+    query: foo
+  - name: bar
+    out: bar.csv
+    format: csv
+    from: bar
+    mode: top
+`,
+			expected: &JobFile{
+				Concurrency: 4,
+				GuestTokens: 2,
+				Jobs: []Job{
+					Job{Name: "foo.csv", Out: "foo.csv", Format: "csv", Search: Search{Text: "foo"}},
+					Job{Name: "bar", Out: "bar.csv", Format: "csv", Search: Search{From: "bar", Mode: "top"}},
+				},
+			},
+			msg: "",
+		},
+		"concurrency": {
+			content: `concurrency: 8
+guest_tokens: 3
+jobs:
+  - out: foo.csv
+    query: foo
+`,
+			expected: &JobFile{
+				Concurrency: 8,
+				GuestTokens: 3,
+				Jobs:        []Job{Job{Name: "foo.csv", Out: "foo.csv", Format: "csv", Search: Search{Text: "foo"}}},
+			},
+			msg: "",
+		},
+		"append": {
+			content: `jobs:
+  - out: foo.csv
+    query: foo
+    output_mode: append
+    skip_existing: true
+`,
+			expected: &JobFile{
+				Concurrency: 4,
+				GuestTokens: 2,
+				Jobs:        []Job{Job{Name: "foo.csv", Out: "foo.csv", Format: "csv", OutputMode: "append", SkipExisting: true, Search: Search{Text: "foo"}}},
+			},
+			msg: "",
+		},
+		"es-url-without-out": {
+			content: `jobs:
+  - format: es-bulk
+    es_url: http://localhost:9200
+    query: foo
+`,
+			expected: &JobFile{
+				Concurrency: 4,
+				GuestTokens: 2,
+				Jobs:        []Job{Job{Name: "http://localhost:9200", Format: "es-bulk", EsUrl: "http://localhost:9200", Search: Search{Text: "foo"}}},
+			},
+			msg: "",
+		},
+		"skip-existing-without-append": {
+			content:  "jobs:\n  - out: foo.csv\n    skip_existing: true\n",
+			expected: nil,
+			msg:      "job 1: skip_existing requires output_mode append",
+		},
+		"unknown-output-mode": {
+			content:  "jobs:\n  - out: foo.csv\n    output_mode: truncate\n",
+			expected: nil,
+			msg:      "job 1: unknown output_mode: truncate",
+		},
+		"no-jobs": {
+			content:  "concurrency: 8\n",
+			expected: nil,
+			msg:      "no jobs defined",
+		},
+		"no-out": {
+			content:  "jobs:\n  - query: foo\n",
+			expected: nil,
+			msg:      "job 1: out is required",
+		},
+		"duplicate-name": {
+			content:  "jobs:\n  - out: foo.csv\n  - name: foo.csv\n    out: bar.csv\n",
+			expected: nil,
+			msg:      "job 2: duplicate name: foo.csv",
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "jobs.yaml")
+			err := os.WriteFile(path, []byte(e.content), 0644)
+			assert.NoError(t, err)
+
+			actual, err := LoadJobFile(path)
+			if len(e.msg) == 0 {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, e.msg)
+			}
+
+			assert.Equal(t, e.expected, actual)
+		})
+	}
+}
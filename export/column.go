@@ -0,0 +1,131 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Column is a field of Record that can be exported.
+type Column struct {
+	Name string
+	// Type is the type of the values: int, str, float or datetime.
+	Type  string
+	Value func(r *Record) string
+	// Time returns the value of a datetime column, which is formatted by the exporter.
+	Time func(r *Record) time.Time
+}
+
+// Format returns the value of the column for r, formatting timestamps with f.
+func (c *Column) Format(r *Record, f TimeFormat) string {
+	if c.Time != nil {
+		return f.Format(c.Time(r))
+	}
+
+	return c.Value(r)
+}
+
+func formatFloat(f *float64) string {
+	if f == nil {
+		return ""
+	}
+
+	return strconv.FormatFloat(*f, 'f', -1, 64)
+}
+
+// Columns lists every exportable column.
+var Columns = []Column{
+	{Name: "id", Type: "int", Value: func(r *Record) string { return strconv.FormatUint(r.Id, 10) }},
+	{Name: "username", Type: "str", Value: func(r *Record) string { return r.Username }},
+	{Name: "created_at", Type: "datetime", Time: func(r *Record) time.Time { return time.Time(r.CreatedAt) }},
+	{Name: "full_text", Type: "str", Value: func(r *Record) string { return r.FullText }},
+	{Name: "retweet_count", Type: "int", Value: func(r *Record) string { return strconv.FormatUint(r.RetweetCount, 10) }},
+	{Name: "favorite_count", Type: "int", Value: func(r *Record) string { return strconv.FormatUint(r.FavoriteCount, 10) }},
+	{Name: "reply_count", Type: "int", Value: func(r *Record) string { return strconv.FormatUint(r.ReplyCount, 10) }},
+	{Name: "quote_count", Type: "int", Value: func(r *Record) string { return strconv.FormatUint(r.QuoteCount, 10) }},
+	{Name: "latitude", Type: "float", Value: func(r *Record) string { return formatFloat(r.Latitude) }},
+	{Name: "longitude", Type: "float", Value: func(r *Record) string { return formatFloat(r.Longitude) }},
+	{Name: "lang", Type: "str", Value: func(r *Record) string { return r.Lang }},
+	{Name: "source", Type: "str", Value: func(r *Record) string { return r.Source }},
+	{Name: "user_created_at", Type: "datetime", Time: func(r *Record) time.Time { return time.Time(r.UserCreatedAt) }},
+	{Name: "place_id", Type: "str", Value: func(r *Record) string { return r.PlaceId }},
+	{Name: "place_name", Type: "str", Value: func(r *Record) string { return r.PlaceName }},
+	{Name: "place_full_name", Type: "str", Value: func(r *Record) string { return r.PlaceFullName }},
+	{Name: "place_type", Type: "str", Value: func(r *Record) string { return r.PlaceType }},
+	{Name: "country_code", Type: "str", Value: func(r *Record) string { return r.CountryCode }},
+	{Name: "place_latitude", Type: "float", Value: func(r *Record) string { return formatFloat(r.PlaceLatitude) }},
+	{Name: "place_longitude", Type: "float", Value: func(r *Record) string { return formatFloat(r.PlaceLongitude) }},
+	{Name: "geo_source", Type: "str", Value: func(r *Record) string { return r.GeoSource }},
+}
+
+// DefaultColumns are the columns exported when none are selected.
+var DefaultColumns = mustParseColumns("id", "username", "created_at", "full_text", "retweet_count", "favorite_count", "reply_count", "quote_count", "latitude", "longitude", "lang", "source")
+
+// PlaceCentroidColumns are added to DefaultColumns when exporting place centroids.
+var PlaceCentroidColumns = mustParseColumns("place_latitude", "place_longitude", "geo_source")
+
+func FindColumn(name string) (Column, bool) {
+	for _, c := range Columns {
+		if c.Name == name {
+			return c, true
+		}
+	}
+
+	return Column{}, false
+}
+
+// ParseColumns returns the columns with the given names in the given order.
+func ParseColumns(names []string) ([]Column, error) {
+	cs := make([]Column, 0, len(names))
+	seen := map[string]bool{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		c, ok := FindColumn(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown column: %s (available: %s)", name, strings.Join(ColumnNames(Columns), ","))
+		}
+
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate column: %s", name)
+		}
+		seen[name] = true
+
+		cs = append(cs, c)
+	}
+
+	if len(cs) == 0 {
+		return nil, fmt.Errorf("no columns selected")
+	}
+
+	return cs, nil
+}
+
+func mustParseColumns(names ...string) []Column {
+	cs, err := ParseColumns(names)
+	if err != nil {
+		panic(err)
+	}
+
+	return cs
+}
+
+func ColumnNames(cs []Column) []string {
+	return Map(cs, func(c Column) string {
+		return c.Name
+	})
+}
@@ -0,0 +1,89 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build small
+// +build small
+
+package export
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseColumns(t *testing.T) {
+	examples := map[string]struct {
+		names    []string
+		expected []string
+		msg      string
+	}{
+		"reordered": {
+			names:    []string{"created_at", "id", " full_text"},
+			expected: []string{"created_at", "id", "full_text"},
+			msg:      "",
+		},
+		"unknown": {
+			names:    []string{"id", "text"},
+			expected: nil,
+			msg:      "unknown column: text (available: id,username,created_at,full_text,retweet_count,favorite_count,reply_count,quote_count,latitude,longitude,lang,source,user_created_at,place_id,place_name,place_full_name,place_type,country_code,place_latitude,place_longitude,geo_source)",
+		},
+		"duplicate": {
+			names:    []string{"id", "username", "id"},
+			expected: nil,
+			msg:      "duplicate column: id",
+		},
+		"empty": {
+			names:    []string{},
+			expected: nil,
+			msg:      "no columns selected",
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			actual, err := ParseColumns(e.names)
+			if len(e.msg) == 0 {
+				assert.NoError(t, err)
+				assert.Equal(t, e.expected, ColumnNames(actual))
+			} else {
+				assert.EqualError(t, err, e.msg)
+				assert.Nil(t, actual)
+			}
+		})
+	}
+}
+
+func TestColumnsValue(t *testing.T) {
+	latitude := 35.6851508
+	r := Record{Id: 1, Username: "watson", RetweetCount: 3, Latitude: &latitude}
+
+	expected := map[string]string{
+		"id":            "1",
+		"username":      "watson",
+		"created_at":    "",
+		"retweet_count": "3",
+		"latitude":      "35.6851508",
+		"longitude":     "",
+	}
+
+	for name, value := range expected {
+		c, ok := FindColumn(name)
+		assert.True(t, ok)
+		assert.Equal(t, value, c.Format(&r, TimeFormat{}), name)
+	}
+
+	_, ok := FindColumn("text")
+	assert.False(t, ok)
+}
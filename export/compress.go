@@ -0,0 +1,132 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+type Compression string
+
+const (
+	CompressionNone Compression = "none"
+	CompressionGzip Compression = "gzip"
+	CompressionZstd Compression = "zstd"
+)
+
+// CompressionForName infers the compression from the extension of a file name.
+func CompressionForName(name string) Compression {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".gz":
+		return CompressionGzip
+	case ".zst":
+		return CompressionZstd
+	default:
+		return CompressionNone
+	}
+}
+
+type frameEncoder interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+// FrameWriter compresses data written to it. Each Flush ends the current gzip
+// member or zstd frame, so that everything flushed can be decompressed even if
+// the output is truncated later. Concatenated members and frames are read back
+// as a single stream.
+type FrameWriter struct {
+	w       io.Writer
+	enc     frameEncoder
+	dirty   bool
+	flushed bool
+}
+
+// NewCompressWriter returns a writer compressing to w, or w itself if c is CompressionNone.
+func NewCompressWriter(w io.Writer, c Compression) (io.Writer, error) {
+	switch c {
+	case "", CompressionNone:
+		return w, nil
+	case CompressionGzip:
+		return &FrameWriter{w: w, enc: gzip.NewWriter(w)}, nil
+	case CompressionZstd:
+		enc, err := zstd.NewWriter(w, zstd.WithZeroFrames(true))
+		if err != nil {
+			return nil, err
+		}
+
+		return &FrameWriter{w: w, enc: enc}, nil
+	default:
+		return nil, fmt.Errorf("unknown compression: %s", c)
+	}
+}
+
+func (f *FrameWriter) Write(p []byte) (int, error) {
+	f.dirty = true
+	return f.enc.Write(p)
+}
+
+// Flush ends the current frame if anything was written since the last Flush.
+func (f *FrameWriter) Flush() error {
+	if !f.dirty {
+		return nil
+	}
+
+	if err := f.enc.Close(); err != nil {
+		return err
+	}
+
+	f.enc.Reset(f.w)
+	f.dirty = false
+	f.flushed = true
+	return nil
+}
+
+// Close ends the last frame. The underlying writer is not closed.
+func (f *FrameWriter) Close() error {
+	if !f.dirty && f.flushed {
+		return nil
+	}
+
+	// An empty output still gets a frame so that it is a valid compressed file.
+	f.dirty = false
+	f.flushed = true
+	return f.enc.Close()
+}
+
+// NewDecompressReader returns a reader decompressing r, or r itself if c is CompressionNone.
+func NewDecompressReader(r io.Reader, c Compression) (io.ReadCloser, error) {
+	switch c {
+	case "", CompressionNone:
+		return io.NopCloser(r), nil
+	case CompressionGzip:
+		return gzip.NewReader(r)
+	case CompressionZstd:
+		dec, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return dec.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unknown compression: %s", c)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build small
+// +build small
+
+package export
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressionForName(t *testing.T) {
+	examples := map[string]struct {
+		name     string
+		expected Compression
+	}{
+		"csv":   {name: "out.csv", expected: CompressionNone},
+		"gzip":  {name: "out.csv.gz", expected: CompressionGzip},
+		"zstd":  {name: "out.csv.zst", expected: CompressionZstd},
+		"upper": {name: "OUT.CSV.GZ", expected: CompressionGzip},
+		"stdin": {name: "-", expected: CompressionNone},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, e.expected, CompressionForName(e.name))
+		})
+	}
+}
+
+func TestFrameWriter(t *testing.T) {
+	for _, c := range []Compression{CompressionGzip, CompressionZstd} {
+		t.Run(string(c), func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewCompressWriter(&buf, c)
+			assert.NoError(t, err)
+
+			f := w.(*FrameWriter)
+			f.Write([]byte("foo\n"))
+			assert.NoError(t, f.Flush())
+			assert.NoError(t, f.Flush())
+			f.Write([]byte("bar\n"))
+			assert.NoError(t, f.Flush())
+
+			// A crash before Close leaves the flushed frames readable.
+			flushed := append([]byte{}, buf.Bytes()...)
+			f.Write([]byte("baz\n"))
+
+			r, err := NewDecompressReader(bytes.NewReader(flushed), c)
+			assert.NoError(t, err)
+			actual, err := io.ReadAll(r)
+			assert.NoError(t, err)
+			assert.Equal(t, "foo\nbar\n", string(actual))
+
+			assert.NoError(t, f.Close())
+			r, err = NewDecompressReader(&buf, c)
+			assert.NoError(t, err)
+			actual, err = io.ReadAll(r)
+			assert.NoError(t, err)
+			assert.Equal(t, "foo\nbar\nbaz\n", string(actual))
+		})
+	}
+}
+
+func TestFrameWriterEmpty(t *testing.T) {
+	for _, c := range []Compression{CompressionGzip, CompressionZstd} {
+		t.Run(string(c), func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewCompressWriter(&buf, c)
+			assert.NoError(t, err)
+			assert.NoError(t, w.(*FrameWriter).Close())
+			assert.NotEmpty(t, buf.Bytes())
+
+			r, err := NewDecompressReader(&buf, c)
+			assert.NoError(t, err)
+			actual, err := io.ReadAll(r)
+			assert.NoError(t, err)
+			assert.Empty(t, actual)
+		})
+	}
+}
+
+func TestNewCompressWriterNone(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewCompressWriter(&buf, CompressionNone)
+	assert.NoError(t, err)
+	assert.Equal(t, &buf, w)
+
+	_, err = NewCompressWriter(&buf, Compression("lz4"))
+	assert.EqualError(t, err, "unknown compression: lz4")
+}
@@ -15,64 +15,357 @@
 package export
 
 import (
+	"bufio"
 	"encoding/csv"
 	"fmt"
-	"os"
+	"io"
 	"strconv"
+	"strings"
+
+	"github.com/akiomik/squawks/logging"
 )
 
-func ExportCsv(f *os.File, ch <-chan []Record) <-chan struct{} {
-	done := make(chan struct{})
+type CsvExporter struct {
+	// SkipHeader omits the header row.
+	SkipHeader bool
+	// Columns are the columns to write in order. All columns are written if empty.
+	Columns []Column
+	// Comma is the field delimiter. It defaults to ','.
+	Comma   rune
+	Quoting Quoting
+	UseCRLF bool
+	// Bom writes a UTF-8 byte order mark at the beginning of the output.
+	Bom bool
+	// EscapeNewlines replaces line breaks in fields with \n and \r, and backslashes with \\.
+	// It is implied by QuoteNone.
+	EscapeNewlines bool
+	// SanitizeFormulas prefixes text fields that a spreadsheet would evaluate as a
+	// formula with a single quote. See SanitizeFormula.
+	SanitizeFormulas bool
+	TimeFormat       TimeFormat
+	// GeoFallback selects the place coordinates of tweets without exact coordinates.
+	// With GeoFallbackCentroid, PlaceCentroidColumns are added to the default columns.
+	GeoFallback GeoFallback
+	// Logger defaults to logging.Default().
+	Logger logging.Logger
+
+	// appending is true when writing after the existing content of a csv.
+	appending bool
+}
+
+// NewTsvExporter returns an exporter writing tab-separated values without quotes,
+// escaping tabs and line breaks in fields.
+func NewTsvExporter() CsvExporter {
+	return CsvExporter{Comma: '\t', Quoting: QuoteNone, EscapeNewlines: true}
+}
+
+func (e *CsvExporter) comma() rune {
+	if e.Comma == 0 {
+		return ','
+	}
+
+	return e.Comma
+}
+
+func (e *CsvExporter) columns() []Column {
+	if len(e.Columns) == 0 {
+		if e.GeoFallback == GeoFallbackCentroid {
+			return append(append([]Column{}, DefaultColumns...), PlaceCentroidColumns...)
+		}
+
+		return DefaultColumns
+	}
+
+	return e.Columns
+}
+
+// Header returns the header row.
+func (e *CsvExporter) Header() []string {
+	return ColumnNames(e.columns())
+}
+
+// ExportCsv writes records to w as csv with the default options.
+func ExportCsv(w io.Writer, ch <-chan []Record) <-chan error {
+	return (&CsvExporter{}).Export(w, ch)
+}
+
+// Export writes records to w as csv, flushing after each page. The returned
+// channel receives the first write error, or nil, once ch is closed or writing
+// failed. Records received after a failure are discarded.
+func (e *CsvExporter) Export(w io.Writer, ch <-chan []Record) <-chan error {
+	return runExport(orDefault(e.Logger), e.format(), ch, func() error {
+		return e.export(w, ch)
+	})
+}
+
+func (e *CsvExporter) format() string {
+	if e.comma() == '\t' {
+		return "tsv"
+	}
+
+	return "csv"
+}
+
+// orDefault returns l, or the default logger if l is nil.
+func orDefault(l logging.Logger) logging.Logger {
+	if l == nil {
+		return logging.Default()
+	}
+
+	return l
+}
+
+// runExport runs export in the background and sends its result to the returned
+// channel. If export fails, the rest of ch is drained so that producers do not block.
+func runExport(logger logging.Logger, format string, ch <-chan []Record, export func() error) <-chan error {
+	done := make(chan error, 1)
 
 	go func() {
 		defer close(done)
 
-		w := csv.NewWriter(f)
-		err := w.Write([]string{"id", "username", "created_at", "full_text", "retweet_count", "favorite_count", "reply_count", "quote_count", "latitude", "longitude", "lang", "source"})
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			panic(err)
+		err := export()
+		if IsBrokenPipe(err) {
+			logger.Debug("export stopped", "format", format, "error", err)
+		} else if err != nil {
+			logger.Error("failed to export", "format", format, "error", err)
 		}
 
-		for records := range ch {
-			for _, record := range records {
-				latitude := ""
-				if record.Latitude != nil {
-					latitude = strconv.FormatFloat(*record.Latitude, 'f', -1, 64)
+		if err != nil {
+			go func() {
+				for range ch {
 				}
+			}()
+		}
 
-				longitude := ""
-				if record.Longitude != nil {
-					longitude = strconv.FormatFloat(*record.Longitude, 'f', -1, 64)
-				}
+		done <- err
+	}()
 
-				row := []string{
-					strconv.FormatUint(record.Id, 10),
-					record.Username,
-					record.CreatedAt.String(),
-					record.FullText,
-					strconv.FormatUint(record.RetweetCount, 10),
-					strconv.FormatUint(record.FavoriteCount, 10),
-					strconv.FormatUint(record.ReplyCount, 10),
-					strconv.FormatUint(record.QuoteCount, 10),
-					latitude,
-					longitude,
-					record.Lang,
-					record.Source,
-				}
+	return done
+}
 
-				err = w.Write(row)
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-					panic(err)
-				}
+func (e *CsvExporter) export(out io.Writer, ch <-chan []Record) error {
+	logger := orDefault(e.Logger)
+	logger.Info("export started", "format", e.format(), "file", Name(out), "skip_header", e.SkipHeader)
+
+	w := e.newWriter(out)
+	pages := 0
+	total := 0
+	for records := range ch {
+		if err := w.WritePage(records); err != nil {
+			return err
+		}
+
+		pages++
+		total += len(records)
+		logger.Debug("exported page", "page", pages, "records", len(records), "total", total)
+	}
+
+	logger.Info("export finished", "format", e.format(), "file", Name(out), "pages", pages, "records", total)
+
+	return nil
+}
+
+// csvWriter writes pages of records to a single output. The byte order mark and
+// the header are written along with the first page.
+type csvWriter struct {
+	out      io.Writer
+	w        rowWriter
+	columns  []Column
+	time     TimeFormat
+	escape   *strings.Replacer
+	sanitize bool
+	centroid bool
+	bom      bool
+	header   bool
+}
+
+func (e *CsvExporter) newWriter(out io.Writer) *csvWriter {
+	w := &csvWriter{
+		out:      out,
+		columns:  e.columns(),
+		time:     e.TimeFormat,
+		escape:   e.escaper(),
+		sanitize: e.SanitizeFormulas,
+		centroid: e.GeoFallback == GeoFallbackCentroid,
+		bom:      e.Bom && !e.appending,
+		header:   !e.SkipHeader && !e.appending,
+	}
+
+	switch e.Quoting {
+	case QuoteAll, QuoteNone:
+		w.w = &quotingWriter{w: bufio.NewWriter(out), comma: e.comma(), quote: e.Quoting == QuoteAll, useCRLF: e.UseCRLF}
+	default:
+		cw := csv.NewWriter(out)
+		cw.Comma = e.comma()
+		cw.UseCRLF = e.UseCRLF
+		w.w = cw
+	}
+
+	return w
+}
+
+func (e *CsvExporter) escaper() *strings.Replacer {
+	if e.Quoting == QuoteNone {
+		comma := string(e.comma())
+		escaped := `\` + comma
+		if comma == "\t" {
+			escaped = `\t`
+		}
+
+		return strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`, comma, escaped)
+	}
+
+	if e.EscapeNewlines {
+		return strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`)
+	}
+
+	return nil
+}
+
+// WritePage writes records and flushes them to the output.
+func (w *csvWriter) WritePage(records []Record) error {
+	if w.bom {
+		if _, err := io.WriteString(w.out, bom); err != nil {
+			return err
+		}
+		w.bom = false
+	}
+
+	if w.header {
+		if err := w.w.Write(ColumnNames(w.columns)); err != nil {
+			return err
+		}
+		w.header = false
+	}
+
+	row := make([]string, len(w.columns))
+	for i := range records {
+		r := &records[i]
+		if w.centroid {
+			located := *r
+			located.SetPlaceCentroid()
+			r = &located
+		}
+
+		for j, c := range w.columns {
+			row[j] = c.Format(r, w.time)
+			if w.sanitize && c.Type == "str" {
+				row[j] = SanitizeFormula(row[j])
 			}
 
-			w.Flush()
+			if w.escape != nil {
+				row[j] = w.escape.Replace(row[j])
+			}
 		}
 
-		done <- struct{}{}
-	}()
+		if err := w.w.Write(row); err != nil {
+			return err
+		}
+	}
 
-	return done
+	w.w.Flush()
+	if err := w.w.Error(); err != nil {
+		return err
+	}
+
+	if f, ok := w.out.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SanitizeFormula neutralizes a field starting with a character that makes a
+// spreadsheet evaluate it as a formula (=, +, -, @, tab or carriage return) by
+// prefixing it with a single quote.
+func SanitizeFormula(s string) string {
+	if len(s) != 0 && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+
+	return s
+}
+
+func (e *CsvExporter) newReader(r io.Reader) rowReader {
+	if e.Quoting == QuoteNone {
+		s := bufio.NewScanner(r)
+		s.Buffer(nil, 1024*1024)
+		return &unquotedReader{s: s, comma: e.comma()}
+	}
+
+	cr := csv.NewReader(r)
+	cr.Comma = e.comma()
+	cr.ReuseRecord = true
+	return &csvReader{r: cr}
+}
+
+// PrepareAppend checks that the csv read from r has the columns written by e and,
+// if ids is not nil, adds the ids of its tweets to ids so that they are not exported
+// again. Without a header, only the number of columns is checked. It returns false
+// if r is empty, in which case the byte order mark and header are still written.
+func (e *CsvExporter) PrepareAppend(r io.Reader, ids IdSet) (bool, error) {
+	reader := e.newReader(r)
+
+	row, err := reader.Read()
+	if err == io.EOF {
+		e.appending = false
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("failed to read csv header: %w", err)
+	}
+
+	e.appending = true
+	row[0] = strings.TrimPrefix(row[0], bom)
+
+	header := e.Header()
+	if !e.SkipHeader {
+		if expected := strings.Join(header, ","); strings.Join(row, ",") != expected {
+			return false, fmt.Errorf("incompatible csv columns: expected %s, got %s", expected, strings.Join(row, ","))
+		}
+	} else if len(row) != len(header) {
+		return false, fmt.Errorf("incompatible csv columns: expected %d columns, got %d", len(header), len(row))
+	}
+
+	if ids == nil {
+		return true, nil
+	}
+
+	idIndex := -1
+	for i, name := range header {
+		if name == "id" {
+			idIndex = i
+		}
+	}
+
+	if idIndex < 0 {
+		return false, fmt.Errorf("cannot skip existing tweets without the id column")
+	}
+
+	// Without a header, the first row is already a tweet.
+	if !e.SkipHeader {
+		row, err = reader.Read()
+	}
+
+	for ; err != io.EOF; row, err = reader.Read() {
+		if err != nil {
+			return false, fmt.Errorf("failed to read csv: %w", err)
+		}
+
+		if len(row) <= idIndex {
+			return false, fmt.Errorf("missing tweet id on line %d", reader.Line())
+		}
+
+		id, err := strconv.ParseUint(row[idIndex], 10, 64)
+		if err != nil {
+			return false, fmt.Errorf("invalid tweet id on line %d: %w", reader.Line(), err)
+		}
+
+		ids.Add(id)
+	}
+
+	return true, nil
 }
@@ -0,0 +1,172 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+type Quoting string
+
+const (
+	// QuoteMinimal quotes fields containing the delimiter, quotes or line breaks.
+	QuoteMinimal Quoting = "minimal"
+	QuoteAll     Quoting = "all"
+	// QuoteNone never quotes. Backslashes, line breaks and delimiters in fields are
+	// escaped with a backslash instead.
+	QuoteNone Quoting = "none"
+)
+
+func ParseQuoting(s string) (Quoting, error) {
+	switch q := Quoting(s); q {
+	case "", QuoteMinimal:
+		return QuoteMinimal, nil
+	case QuoteAll, QuoteNone:
+		return q, nil
+	default:
+		return QuoteMinimal, fmt.Errorf("unknown quoting: %s", s)
+	}
+}
+
+// bom is the UTF-8 byte order mark.
+const bom = "\ufeff"
+
+// ParseDelimiter parses a field delimiter. "\t" and "tab" are accepted for a tab.
+func ParseDelimiter(s string) (rune, error) {
+	switch s {
+	case `\t`, "tab":
+		return '\t', nil
+	}
+
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 || size != len(s) || r == utf8.RuneError || r == '"' || r == '\\' || r == '\r' || r == '\n' {
+		return 0, fmt.Errorf("invalid delimiter: %q", s)
+	}
+
+	return r, nil
+}
+
+type rowWriter interface {
+	Write(row []string) error
+	Flush()
+	Error() error
+}
+
+type rowReader interface {
+	Read() ([]string, error)
+	// Line returns the line number of the row read last.
+	Line() int
+}
+
+// quotingWriter writes rows with every field quoted, or with none quoted.
+type quotingWriter struct {
+	w       *bufio.Writer
+	comma   rune
+	quote   bool
+	useCRLF bool
+}
+
+func (w *quotingWriter) Write(row []string) error {
+	for i, field := range row {
+		if i > 0 {
+			w.w.WriteRune(w.comma)
+		}
+
+		if w.quote {
+			w.w.WriteByte('"')
+			w.w.WriteString(strings.ReplaceAll(field, `"`, `""`))
+			w.w.WriteByte('"')
+		} else {
+			w.w.WriteString(field)
+		}
+	}
+
+	var err error
+	if w.useCRLF {
+		_, err = w.w.WriteString("\r\n")
+	} else {
+		err = w.w.WriteByte('\n')
+	}
+
+	return err
+}
+
+func (w *quotingWriter) Flush() {
+	w.w.Flush()
+}
+
+func (w *quotingWriter) Error() error {
+	_, err := w.w.Write(nil)
+	return err
+}
+
+type csvReader struct {
+	r *csv.Reader
+}
+
+func (r *csvReader) Read() ([]string, error) {
+	return r.r.Read()
+}
+
+func (r *csvReader) Line() int {
+	line, _ := r.r.FieldPos(0)
+	return line
+}
+
+// unquotedReader reads rows written with QuoteNone. Escaped delimiters do not
+// split fields, and escapes are kept as is.
+type unquotedReader struct {
+	s     *bufio.Scanner
+	comma rune
+	line  int
+}
+
+func (r *unquotedReader) Read() ([]string, error) {
+	if !r.s.Scan() {
+		if err := r.s.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, io.EOF
+	}
+	r.line++
+
+	line := strings.TrimSuffix(r.s.Text(), "\r")
+	fields := []string{}
+	start := 0
+	escaped := false
+	for i, c := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == r.comma:
+			fields = append(fields, line[start:i])
+			start = i + utf8.RuneLen(c)
+		}
+	}
+
+	return append(fields, line[start:]), nil
+}
+
+func (r *unquotedReader) Line() int {
+	return r.line
+}
@@ -0,0 +1,85 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build small
+// +build small
+
+package export
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDelimiter(t *testing.T) {
+	examples := map[string]struct {
+		s        string
+		expected rune
+		msg      string
+	}{
+		"comma":     {s: ",", expected: ',', msg: ""},
+		"semicolon": {s: ";", expected: ';', msg: ""},
+		"tab":       {s: "tab", expected: '\t', msg: ""},
+		"escaped":   {s: `\t`, expected: '\t', msg: ""},
+		"multibyte": {s: "│", expected: '│', msg: ""},
+		"empty":     {s: "", expected: 0, msg: `invalid delimiter: ""`},
+		"long":      {s: ",,", expected: 0, msg: `invalid delimiter: ",,"`},
+		"quote":     {s: `"`, expected: 0, msg: `invalid delimiter: "\""`},
+		"newline":   {s: "\n", expected: 0, msg: `invalid delimiter: "\n"`},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			actual, err := ParseDelimiter(e.s)
+			if len(e.msg) == 0 {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, e.msg)
+			}
+
+			assert.Equal(t, e.expected, actual)
+		})
+	}
+}
+
+func TestParseQuoting(t *testing.T) {
+	for _, s := range []string{"", "minimal", "all", "none"} {
+		_, err := ParseQuoting(s)
+		assert.NoError(t, err)
+	}
+
+	_, err := ParseQuoting("always")
+	assert.EqualError(t, err, "unknown quoting: always")
+}
+
+func TestUnquotedReader(t *testing.T) {
+	r := &unquotedReader{s: bufio.NewScanner(strings.NewReader("1,a\\,b,c\\\\\r\n2,,\n")), comma: ','}
+
+	row, err := r.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", `a\,b`, `c\\`}, row)
+	assert.Equal(t, 1, r.Line())
+
+	row, err = r.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2", "", ""}, row)
+	assert.Equal(t, 2, r.Line())
+
+	_, err = r.Read()
+	assert.ErrorIs(t, err, io.EOF)
+}
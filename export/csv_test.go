@@ -21,10 +21,15 @@ import (
 	"encoding/csv"
 	"io"
 	"os"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/akiomik/squawks/api/json"
+	"github.com/akiomik/squawks/logging"
 )
 
 func TestExportCsvEmpty(t *testing.T) {
@@ -136,3 +141,419 @@ func TestExportCsvNonEmpty(t *testing.T) {
 	_, err = reader.Read()
 	assert.ErrorIs(t, err, io.EOF)
 }
+
+type errWriter struct {
+	err error
+}
+
+func (w errWriter) Write(p []byte) (int, error) {
+	return 0, w.err
+}
+
+func TestExportCsvWriteError(t *testing.T) {
+	ch := make(chan []Record)
+	go func() {
+		defer close(ch)
+
+		for i := 0; i < 3; i++ {
+			ch <- []Record{Record{Id: uint64(i)}}
+		}
+	}()
+
+	err := <-ExportCsv(errWriter{syscall.EPIPE}, ch)
+	assert.ErrorIs(t, err, syscall.EPIPE)
+	assert.True(t, IsBrokenPipe(err))
+}
+
+func TestExportCsvPipe(t *testing.T) {
+	r, w, err := os.Pipe()
+	assert.NoError(t, err)
+	defer w.Close()
+
+	ch := make(chan []Record, 1)
+	ch <- []Record{Record{Id: 1, Username: "watson"}}
+
+	done := ExportCsv(w, ch)
+
+	// Each page is flushed without waiting for the channel to be closed.
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, "id", header[0])
+
+	row, err := reader.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "watson"}, row[:2])
+
+	close(ch)
+	assert.NoError(t, <-done)
+}
+
+func TestCsvExporterLogger(t *testing.T) {
+	var log strings.Builder
+	logger := logging.With(logging.New(&log, logging.LevelInfo, logging.FormatText), "job", "foo")
+
+	ch := make(chan []Record, 1)
+	ch <- []Record{Record{Id: 1}}
+	close(ch)
+
+	err := <-(&CsvExporter{Logger: logger}).Export(errWriter{syscall.EIO}, ch)
+	assert.ErrorIs(t, err, syscall.EIO)
+	assert.Contains(t, log.String(), `msg="failed to export" job=foo format=csv`)
+}
+
+func TestCsvExporterSkipHeader(t *testing.T) {
+	var buf strings.Builder
+
+	ch := make(chan []Record, 1)
+	ch <- []Record{Record{Id: 1, Username: "watson"}}
+	close(ch)
+
+	err := <-(&CsvExporter{SkipHeader: true}).Export(&buf, ch)
+	assert.NoError(t, err)
+	assert.Equal(t, "1,watson,,,0,0,0,0,,,,\n", buf.String())
+}
+
+func TestCsvExporterColumns(t *testing.T) {
+	var buf strings.Builder
+
+	latitude := 40.74118764
+
+	ch := make(chan []Record, 1)
+	ch <- []Record{Record{Id: 1, Username: "watson", FullText: "foo", Latitude: &latitude}}
+	close(ch)
+
+	columns, err := ParseColumns([]string{"latitude", "username", "id"})
+	assert.NoError(t, err)
+
+	err = <-(&CsvExporter{Columns: columns}).Export(&buf, ch)
+	assert.NoError(t, err)
+	assert.Equal(t, "latitude,username,id\n40.74118764,watson,1\n", buf.String())
+}
+
+func TestCsvExporterDialect(t *testing.T) {
+	columns, err := ParseColumns([]string{"id", "username", "full_text"})
+	assert.NoError(t, err)
+
+	records := []Record{
+		Record{Id: 1, Username: "watson", FullText: "a,b \"c\"\nd\te\\f"},
+		Record{Id: 2, Username: "holmes", FullText: ""},
+	}
+
+	tsv := NewTsvExporter()
+	tsv.Columns = columns
+
+	examples := map[string]struct {
+		exporter CsvExporter
+		expected string
+	}{
+		"default": {
+			exporter: CsvExporter{Columns: columns},
+			expected: "id,username,full_text\n1,watson,\"a,b \"\"c\"\"\nd\te\\f\"\n2,holmes,\n",
+		},
+		"semicolon": {
+			exporter: CsvExporter{Columns: columns, Comma: ';'},
+			expected: "id;username;full_text\n1;watson;\"a,b \"\"c\"\"\nd\te\\f\"\n2;holmes;\n",
+		},
+		"quote-all": {
+			exporter: CsvExporter{Columns: columns, Quoting: QuoteAll},
+			expected: "\"id\",\"username\",\"full_text\"\n\"1\",\"watson\",\"a,b \"\"c\"\"\nd\te\\f\"\n\"2\",\"holmes\",\"\"\n",
+		},
+		"quote-none": {
+			exporter: CsvExporter{Columns: columns, Quoting: QuoteNone},
+			expected: "id,username,full_text\n1,watson,a\\,b \"c\"\\nd\te\\\\f\n2,holmes,\n",
+		},
+		"tsv": {
+			exporter: tsv,
+			expected: "id\tusername\tfull_text\n1\twatson\ta,b \"c\"\\nd\\te\\\\f\n2\tholmes\t\n",
+		},
+		"excel": {
+			exporter: CsvExporter{Columns: columns, Bom: true, UseCRLF: true, EscapeNewlines: true},
+			expected: "\ufeffid,username,full_text\r\n1,watson,\"a,b \"\"c\"\"\\nd\te\\\\f\"\r\n2,holmes,\r\n",
+		},
+		"no-header": {
+			exporter: CsvExporter{Columns: columns, SkipHeader: true},
+			expected: "1,watson,\"a,b \"\"c\"\"\nd\te\\f\"\n2,holmes,\n",
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			var buf strings.Builder
+
+			ch := make(chan []Record, 1)
+			ch <- records
+			close(ch)
+
+			err := <-e.exporter.Export(&buf, ch)
+			assert.NoError(t, err)
+			assert.Equal(t, e.expected, buf.String())
+		})
+	}
+}
+
+func TestCsvExporterPrepareAppendDialect(t *testing.T) {
+	columns, err := ParseColumns([]string{"full_text", "id"})
+	assert.NoError(t, err)
+
+	tsv := NewTsvExporter()
+	tsv.Columns = columns
+
+	noHeader := CsvExporter{Columns: columns, SkipHeader: true, Bom: true}
+
+	examples := map[string]struct {
+		exporter    CsvExporter
+		content     string
+		expectedIds []uint64
+		msg         string
+	}{
+		"tsv": {
+			exporter:    tsv,
+			content:     "full_text\tid\na\\tb\t1\n\t2\n",
+			expectedIds: []uint64{1, 2},
+			msg:         "",
+		},
+		"bom": {
+			exporter:    CsvExporter{Columns: columns, Bom: true},
+			content:     "\ufefffull_text,id\r\nfoo,1\r\n",
+			expectedIds: []uint64{1},
+			msg:         "",
+		},
+		"no-header": {
+			exporter:    noHeader,
+			content:     "\ufefffoo,1\nbar,2\n",
+			expectedIds: []uint64{1, 2},
+			msg:         "",
+		},
+		"no-header-incompatible": {
+			exporter:    noHeader,
+			content:     "foo,1,bar\n",
+			expectedIds: []uint64{},
+			msg:         "incompatible csv columns: expected 2 columns, got 3",
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			ids := NewMemoryIdSet()
+			actual, err := e.exporter.PrepareAppend(strings.NewReader(e.content), ids)
+			if len(e.msg) == 0 {
+				assert.NoError(t, err)
+				assert.True(t, actual)
+			} else {
+				assert.EqualError(t, err, e.msg)
+			}
+
+			actualIds := []uint64{}
+			for _, id := range []uint64{1, 2, 3} {
+				if !ids.Add(id) {
+					actualIds = append(actualIds, id)
+				}
+			}
+			assert.Equal(t, e.expectedIds, actualIds)
+		})
+	}
+}
+
+func TestCsvExporterAppendBom(t *testing.T) {
+	e := CsvExporter{Bom: true}
+	_, err := e.PrepareAppend(strings.NewReader("\ufeff"+strings.Join(e.Header(), ",")+"\n"), nil)
+	assert.NoError(t, err)
+
+	var buf strings.Builder
+	ch := make(chan []Record, 1)
+	ch <- []Record{Record{Id: 1}}
+	close(ch)
+
+	assert.NoError(t, <-e.Export(&buf, ch))
+	assert.True(t, strings.HasPrefix(buf.String(), "1,"))
+}
+
+func TestSanitizeFormula(t *testing.T) {
+	examples := map[string]struct {
+		s        string
+		expected string
+	}{
+		"empty":           {s: "", expected: ""},
+		"plain":           {s: "hello", expected: "hello"},
+		"equals":          {s: "=HYPERLINK(\"http://example.com\")", expected: "'=HYPERLINK(\"http://example.com\")"},
+		"equals-only":     {s: "=", expected: "'="},
+		"plus":            {s: "+1 for this", expected: "'+1 for this"},
+		"minus":           {s: "-2+3", expected: "'-2+3"},
+		"at":              {s: "@SUM(A1:A2)", expected: "'@SUM(A1:A2)"},
+		"tab":             {s: "\t=1+1", expected: "'\t=1+1"},
+		"carriage-return": {s: "\r=1+1", expected: "'\r=1+1"},
+		"leading-space":   {s: " =1+1", expected: " =1+1"},
+		"middle":          {s: "a=b+c", expected: "a=b+c"},
+		"quote":           {s: "'=1+1", expected: "'=1+1"},
+		"multibyte":       {s: "＝1+1", expected: "＝1+1"},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, e.expected, SanitizeFormula(e.s))
+		})
+	}
+}
+
+func TestCsvExporterSanitizeFormulas(t *testing.T) {
+	columns, err := ParseColumns([]string{"id", "username", "full_text", "longitude", "source"})
+	assert.NoError(t, err)
+
+	longitude := -73.9998279
+
+	var buf strings.Builder
+	ch := make(chan []Record, 1)
+	ch <- []Record{Record{Id: 1, Username: "watson", FullText: "=1+1", Longitude: &longitude, Source: "@bot"}}
+	close(ch)
+
+	err = <-(&CsvExporter{Columns: columns, SanitizeFormulas: true, Quoting: QuoteAll}).Export(&buf, ch)
+	assert.NoError(t, err)
+	assert.Equal(t, "\"id\",\"username\",\"full_text\",\"longitude\",\"source\"\n\"1\",\"watson\",\"'=1+1\",\"-73.9998279\",\"'@bot\"\n", buf.String())
+}
+
+func TestCsvExporterTimeFormat(t *testing.T) {
+	columns, err := ParseColumns([]string{"id", "created_at", "user_created_at"})
+	assert.NoError(t, err)
+
+	f, err := ParseTimeFormat("rfc3339", "Asia/Tokyo")
+	assert.NoError(t, err)
+
+	var buf strings.Builder
+	ch := make(chan []Record, 1)
+	ch <- []Record{Record{
+		Id:            1,
+		CreatedAt:     Iso8601Date(time.Date(2020, 9, 6, 0, 1, 2, 0, time.UTC)),
+		UserCreatedAt: Iso8601Date(time.Date(2010, 1, 2, 15, 0, 0, 0, time.UTC)),
+	}}
+	close(ch)
+
+	err = <-(&CsvExporter{Columns: columns, TimeFormat: f}).Export(&buf, ch)
+	assert.NoError(t, err)
+	assert.Equal(t, "id,created_at,user_created_at\n1,2020-09-06T09:01:02+09:00,2010-01-03T00:00:00+09:00\n", buf.String())
+}
+
+func TestCsvExporterGeoFallback(t *testing.T) {
+	place := &json.Place{
+		Id:          "01a9a39529b27f36",
+		BoundingBox: json.BoundingBox{Type: "Polygon", Coordinates: [][]json.LongLat{{{-74, 40}, {-73, 40}, {-73, 41}, {-74, 41}}}},
+	}
+	records := []Record{Record{Id: 1, PlaceId: place.Id, GeoSource: "place", Tweet: &json.Tweet{Place: place}}}
+
+	examples := map[string]struct {
+		columns  []string
+		expected string
+	}{
+		"default": {
+			columns:  nil,
+			expected: "id,username,created_at,full_text,retweet_count,favorite_count,reply_count,quote_count,latitude,longitude,lang,source,place_latitude,place_longitude,geo_source\n1,,,,0,0,0,0,,,,,40.5,-73.5,place\n",
+		},
+		"columns": {
+			columns:  []string{"id", "place_id", "place_latitude"},
+			expected: "id,place_id,place_latitude\n1,01a9a39529b27f36,40.5\n",
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			exporter := &CsvExporter{GeoFallback: GeoFallbackCentroid}
+			if e.columns != nil {
+				columns, err := ParseColumns(e.columns)
+				assert.NoError(t, err)
+				exporter.Columns = columns
+			}
+
+			var buf strings.Builder
+			ch := make(chan []Record, 1)
+			ch <- records
+			close(ch)
+
+			assert.NoError(t, <-exporter.Export(&buf, ch))
+			assert.Equal(t, e.expected, buf.String())
+			assert.Nil(t, records[0].PlaceLatitude)
+		})
+	}
+}
+
+func TestCsvExporterPrepareAppendColumns(t *testing.T) {
+	columns, err := ParseColumns([]string{"username", "id"})
+	assert.NoError(t, err)
+
+	ids := NewMemoryIdSet()
+	e := &CsvExporter{Columns: columns}
+	actual, err := e.PrepareAppend(strings.NewReader("username,id\nwatson,1\n"), ids)
+	assert.NoError(t, err)
+	assert.True(t, actual)
+	assert.False(t, ids.Add(1))
+
+	columns, err = ParseColumns([]string{"username"})
+	assert.NoError(t, err)
+
+	e = &CsvExporter{Columns: columns}
+	_, err = e.PrepareAppend(strings.NewReader("username\nwatson\n"), ids)
+	assert.EqualError(t, err, "cannot skip existing tweets without the id column")
+}
+
+func TestCsvExporterPrepareAppend(t *testing.T) {
+	header := strings.Join((&CsvExporter{}).Header(), ",") + "\n"
+
+	examples := map[string]struct {
+		content     string
+		expected    bool
+		expectedIds []uint64
+		msg         string
+	}{
+		"empty": {
+			content:     "",
+			expected:    false,
+			expectedIds: []uint64{},
+			msg:         "",
+		},
+		"header-only": {
+			content:     header,
+			expected:    true,
+			expectedIds: []uint64{},
+			msg:         "",
+		},
+		"rows": {
+			content:     header + "1,watson,2020-09-06T00:01:02+00:00,foo,0,0,0,0,,,en,\n2,holmes,2020-09-06T00:01:02+00:00,\"bar\nbaz\",0,0,0,0,,,en,\n",
+			expected:    true,
+			expectedIds: []uint64{1, 2},
+			msg:         "",
+		},
+		"incompatible": {
+			content:     "id,username\n1,watson\n",
+			expected:    false,
+			expectedIds: []uint64{},
+			msg:         "incompatible csv columns: expected " + strings.TrimSuffix(header, "\n") + ", got id,username",
+		},
+		"invalid-id": {
+			content:     header + "1,watson,,,0,0,0,0,,,,\nfoo,holmes,,,0,0,0,0,,,,\n",
+			expected:    false,
+			expectedIds: []uint64{1},
+			msg:         "invalid tweet id on line 3: strconv.ParseUint: parsing \"foo\": invalid syntax",
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			ids := NewMemoryIdSet()
+			actual, err := (&CsvExporter{}).PrepareAppend(strings.NewReader(e.content), ids)
+			if len(e.msg) == 0 {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, e.msg)
+			}
+
+			assert.Equal(t, e.expected, actual)
+
+			actualIds := []uint64{}
+			for _, id := range []uint64{1, 2, 3} {
+				if !ids.Add(id) {
+					actualIds = append(actualIds, id)
+				}
+			}
+			assert.Equal(t, e.expectedIds, actualIds)
+		})
+	}
+}
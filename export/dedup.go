@@ -0,0 +1,192 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+)
+
+const (
+	DefaultBloomCapacity          = 10000000
+	DefaultBloomFalsePositiveRate = 0.0001
+)
+
+// IdSet records tweet ids. Add returns false if the id was already added.
+type IdSet interface {
+	Add(id uint64) bool
+}
+
+type MemoryIdSet map[uint64]struct{}
+
+func NewMemoryIdSet() MemoryIdSet {
+	return MemoryIdSet{}
+}
+
+func (s MemoryIdSet) Add(id uint64) bool {
+	if _, ok := s[id]; ok {
+		return false
+	}
+
+	s[id] = struct{}{}
+	return true
+}
+
+// BloomIdSet is a fixed-size IdSet for very large crawls. It never misses a
+// duplicate, but may report a new id as already added with a small probability.
+type BloomIdSet struct {
+	bits   []uint64
+	size   uint64
+	hashes uint64
+}
+
+func NewBloomIdSet(capacity uint64, falsePositiveRate float64) *BloomIdSet {
+	if capacity == 0 {
+		capacity = 1
+	}
+
+	size := uint64(math.Ceil(-float64(capacity) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	if size < 64 {
+		size = 64
+	}
+
+	hashes := uint64(math.Round(float64(size) / float64(capacity) * math.Ln2))
+	if hashes < 1 {
+		hashes = 1
+	}
+
+	return &BloomIdSet{
+		bits:   make([]uint64, (size+63)/64),
+		size:   size,
+		hashes: hashes,
+	}
+}
+
+func (s *BloomIdSet) Add(id uint64) bool {
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, id)
+
+	h := fnv.New128a()
+	h.Write(buf)
+	sum := h.Sum(nil)
+	h1 := binary.LittleEndian.Uint64(sum[:8])
+	h2 := binary.LittleEndian.Uint64(sum[8:])
+
+	added := false
+	for i := uint64(0); i < s.hashes; i++ {
+		n := (h1 + i*h2) % s.size
+		mask := uint64(1) << (n % 64)
+		if s.bits[n/64]&mask == 0 {
+			s.bits[n/64] |= mask
+			added = true
+		}
+	}
+
+	return added
+}
+
+// NewIdSet returns an IdSet by kind (memory, bloom or none). It returns nil for none.
+// The capacity is the expected number of ids for bloom, or the default if zero.
+func NewIdSet(kind string, capacity uint64) (IdSet, error) {
+	switch kind {
+	case "memory":
+		return NewMemoryIdSet(), nil
+	case "bloom":
+		if capacity == 0 {
+			capacity = DefaultBloomCapacity
+		}
+
+		return NewBloomIdSet(capacity, DefaultBloomFalsePositiveRate), nil
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown dedup: %s", kind)
+	}
+}
+
+type Deduplicator struct {
+	Ids     IdSet
+	Dropped uint64
+}
+
+func NewDeduplicator(ids IdSet) *Deduplicator {
+	return &Deduplicator{Ids: ids}
+}
+
+// Deduplicate drops records whose id was already seen. Dropped is final once
+// the returned channel is closed. Once ctx is done, the returned channel is
+// closed and ch is drained, so that its sender is not blocked by a consumer
+// that stopped reading.
+func (d *Deduplicator) Deduplicate(ctx context.Context, ch <-chan []Record) <-chan []Record {
+	return filterPages(ctx, ch, func(r Record) bool {
+		if d.Ids.Add(r.Id) {
+			return true
+		}
+
+		d.Dropped++
+		return false
+	})
+}
+
+// Skipper drops records whose id is in Ids, such as the tweets already in an
+// output file being appended to. Unlike Deduplicator, it does not add the ids
+// of the records passing through, so duplicates among them are kept.
+type Skipper struct {
+	Ids     MemoryIdSet
+	Skipped uint64
+}
+
+func NewSkipper(ids MemoryIdSet) *Skipper {
+	return &Skipper{Ids: ids}
+}
+
+// Skip drops records whose id is in Ids. Skipped is final once the returned
+// channel is closed. It stops like Deduplicate once ctx is done.
+func (s *Skipper) Skip(ctx context.Context, ch <-chan []Record) <-chan []Record {
+	return filterPages(ctx, ch, func(r Record) bool {
+		if _, ok := s.Ids[r.Id]; !ok {
+			return true
+		}
+
+		s.Skipped++
+		return false
+	})
+}
+
+func filterPages(ctx context.Context, ch <-chan []Record, keep func(Record) bool) <-chan []Record {
+	out := make(chan []Record)
+
+	go func() {
+		defer close(out)
+
+		for records := range ch {
+			select {
+			case out <- Filter(records, keep):
+			case <-ctx.Done():
+				go func() {
+					for range ch {
+					}
+				}()
+
+				return
+			}
+		}
+	}()
+
+	return out
+}
@@ -0,0 +1,202 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build small
+// +build small
+
+package export
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryIdSetAdd(t *testing.T) {
+	s := NewMemoryIdSet()
+
+	assert.True(t, s.Add(1))
+	assert.True(t, s.Add(2))
+	assert.False(t, s.Add(1))
+	assert.False(t, s.Add(2))
+	assert.True(t, s.Add(3))
+}
+
+func TestBloomIdSetAdd(t *testing.T) {
+	s := NewBloomIdSet(1000, 0.001)
+
+	assert.True(t, s.Add(1))
+	assert.True(t, s.Add(2))
+	assert.False(t, s.Add(1))
+	assert.False(t, s.Add(2))
+
+	for id := uint64(1000); id < 2000; id++ {
+		s.Add(id)
+	}
+
+	for id := uint64(1000); id < 2000; id++ {
+		assert.False(t, s.Add(id))
+	}
+}
+
+func TestNewBloomIdSet(t *testing.T) {
+	s := NewBloomIdSet(1000, 0.01)
+
+	assert.Equal(t, uint64(9586), s.size)
+	assert.Equal(t, uint64(7), s.hashes)
+	assert.Len(t, s.bits, 150)
+}
+
+func TestNewIdSet(t *testing.T) {
+	examples := map[string]struct {
+		kind        string
+		capacity    uint64
+		expected    IdSet
+		expectError bool
+	}{
+		"memory": {
+			kind:        "memory",
+			capacity:    0,
+			expected:    MemoryIdSet{},
+			expectError: false,
+		},
+		"bloom": {
+			kind:        "bloom",
+			capacity:    1000,
+			expected:    NewBloomIdSet(1000, DefaultBloomFalsePositiveRate),
+			expectError: false,
+		},
+		"none": {
+			kind:        "none",
+			capacity:    0,
+			expected:    nil,
+			expectError: false,
+		},
+		"unknown": {
+			kind:        "foo",
+			capacity:    0,
+			expected:    nil,
+			expectError: true,
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			actual, err := NewIdSet(e.kind, e.capacity)
+			if e.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, e.expected, actual)
+		})
+	}
+}
+
+func TestDeduplicate(t *testing.T) {
+	ch := make(chan []Record)
+	go func() {
+		defer close(ch)
+
+		ch <- []Record{Record{Id: 3}, Record{Id: 2}, Record{Id: 2}}
+		ch <- []Record{Record{Id: 2}, Record{Id: 1}}
+		ch <- []Record{Record{Id: 1}}
+	}()
+
+	d := NewDeduplicator(NewMemoryIdSet())
+
+	actual := [][]Record{}
+	for records := range d.Deduplicate(context.Background(), ch) {
+		actual = append(actual, records)
+	}
+
+	expected := [][]Record{
+		[]Record{Record{Id: 3}, Record{Id: 2}},
+		[]Record{Record{Id: 1}},
+		[]Record{},
+	}
+	assert.Equal(t, expected, actual)
+	assert.Equal(t, uint64(3), d.Dropped)
+}
+
+func TestSkip(t *testing.T) {
+	ch := make(chan []Record)
+	go func() {
+		defer close(ch)
+
+		ch <- []Record{Record{Id: 3}, Record{Id: 2}, Record{Id: 2}}
+		ch <- []Record{Record{Id: 1}}
+	}()
+
+	ids := NewMemoryIdSet()
+	ids.Add(2)
+	s := NewSkipper(ids)
+
+	actual := [][]Record{}
+	for records := range s.Skip(context.Background(), ch) {
+		actual = append(actual, records)
+	}
+
+	expected := [][]Record{
+		[]Record{Record{Id: 3}},
+		[]Record{Record{Id: 1}},
+	}
+	assert.Equal(t, expected, actual)
+	assert.Equal(t, uint64(2), s.Skipped)
+	assert.Len(t, ids, 1)
+}
+
+// failingExporter fails after the first page without reading the rest.
+type failingExporter struct{}
+
+func (failingExporter) Export(ch <-chan []Record) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		<-ch
+		done <- errors.New("failed to write")
+	}()
+
+	return done
+}
+
+func TestDeduplicateWhenExportFails(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sent := make(chan struct{})
+	ch := make(chan []Record)
+	go func() {
+		defer close(sent)
+		defer close(ch)
+
+		for i := uint64(1); i <= 3; i++ {
+			ch <- []Record{Record{Id: i}}
+		}
+	}()
+
+	d := NewDeduplicator(NewMemoryIdSet())
+	err := <-failingExporter{}.Export(d.Deduplicate(ctx, ch))
+	assert.EqualError(t, err, "failed to write")
+	cancel()
+
+	select {
+	case <-sent:
+	case <-time.After(time.Second):
+		assert.Fail(t, "the sender is blocked")
+	}
+}
@@ -0,0 +1,367 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akiomik/squawks/logging"
+)
+
+const (
+	// DefaultEsIndex is the default index of bulk documents.
+	DefaultEsIndex = "tweets"
+	// DefaultEsBatchSize is the default number of documents per bulk request.
+	DefaultEsBatchSize = 500
+)
+
+// esDateFields maps the date fields of index patterns to Go layouts.
+var esDateFields = []struct {
+	field  string
+	layout string
+}{
+	{field: "yyyy", layout: "2006"},
+	{field: "yy", layout: "06"},
+	{field: "MM", layout: "01"},
+	{field: "dd", layout: "02"},
+	{field: "HH", layout: "15"},
+}
+
+// IndexPattern is an index name that may contain a date in braces, such as
+// tweets-{yyyy.MM}, filled with the creation time of each tweet.
+type IndexPattern struct {
+	pattern string
+	// parts are literal text at even indexes and Go layouts at odd ones.
+	parts []string
+}
+
+// ParseIndexPattern parses an index pattern. Dates support the yyyy, yy, MM,
+// dd and HH fields separated by ., - or _.
+func ParseIndexPattern(s string) (IndexPattern, error) {
+	p := IndexPattern{pattern: s}
+	if len(s) == 0 {
+		return p, fmt.Errorf("invalid index pattern: empty")
+	}
+
+	rest := s
+	for {
+		i := strings.IndexByte(rest, '{')
+		if i < 0 {
+			break
+		}
+
+		j := strings.IndexByte(rest[i:], '}')
+		if j < 0 {
+			return p, fmt.Errorf("invalid index pattern: %s: unclosed {", s)
+		}
+
+		layout, err := esDateLayout(rest[i+1 : i+j])
+		if err != nil {
+			return p, fmt.Errorf("invalid index pattern: %s: %w", s, err)
+		}
+
+		p.parts = append(p.parts, rest[:i], layout)
+		rest = rest[i+j+1:]
+	}
+	p.parts = append(p.parts, rest)
+
+	for i := 0; i < len(p.parts); i += 2 {
+		if strings.ContainsAny(p.parts[i], "}\\/*?\"<>| ,#") || strings.ToLower(p.parts[i]) != p.parts[i] {
+			return p, fmt.Errorf("invalid index pattern: %s: index names must be lowercase without special characters", s)
+		}
+	}
+
+	return p, nil
+}
+
+func esDateLayout(date string) (string, error) {
+	var layout strings.Builder
+	for len(date) != 0 {
+		if strings.IndexByte(".-_", date[0]) >= 0 {
+			layout.WriteByte(date[0])
+			date = date[1:]
+			continue
+		}
+
+		found := false
+		for _, f := range esDateFields {
+			if strings.HasPrefix(date, f.field) {
+				layout.WriteString(f.layout)
+				date = date[len(f.field):]
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return "", fmt.Errorf("unknown date field: %s", date)
+		}
+	}
+
+	if layout.Len() == 0 {
+		return "", fmt.Errorf("empty date")
+	}
+
+	return layout.String(), nil
+}
+
+// Name returns the index name for a tweet created at t.
+func (p IndexPattern) Name(t time.Time) string {
+	var name strings.Builder
+	for i, part := range p.parts {
+		if i%2 == 0 {
+			name.WriteString(part)
+		} else {
+			name.WriteString(t.Format(part))
+		}
+	}
+
+	return name.String()
+}
+
+func (p IndexPattern) String() string {
+	return p.pattern
+}
+
+type esAction struct {
+	Index struct {
+		Index string `json:"_index"`
+		Id    string `json:"_id"`
+	} `json:"index"`
+}
+
+type esGeoPoint struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// EsDocument is the document indexed for a Record.
+type EsDocument struct {
+	Id            uint64       `json:"id"`
+	UserId        uint64       `json:"user_id,omitempty"`
+	Username      string       `json:"username"`
+	CreatedAt     *Iso8601Date `json:"created_at,omitempty"`
+	FullText      string       `json:"full_text"`
+	RetweetCount  uint64       `json:"retweet_count"`
+	FavoriteCount uint64       `json:"favorite_count"`
+	ReplyCount    uint64       `json:"reply_count"`
+	QuoteCount    uint64       `json:"quote_count"`
+	Location      *esGeoPoint  `json:"location,omitempty"`
+	Lang          string       `json:"lang"`
+	Source        string       `json:"source"`
+	Hashtags      []string     `json:"hashtags,omitempty"`
+	Mentions      []string     `json:"mentions,omitempty"`
+	Urls          []string     `json:"urls,omitempty"`
+	PlaceId       string       `json:"place_id,omitempty"`
+	PlaceFullName string       `json:"place_full_name,omitempty"`
+	CountryCode   string       `json:"country_code,omitempty"`
+}
+
+// NewEsDocument returns r as a document with its coordinates as a geo point.
+func NewEsDocument(r *Record) EsDocument {
+	d := EsDocument{
+		Id:            r.Id,
+		Username:      r.Username,
+		FullText:      r.FullText,
+		RetweetCount:  r.RetweetCount,
+		FavoriteCount: r.FavoriteCount,
+		ReplyCount:    r.ReplyCount,
+		QuoteCount:    r.QuoteCount,
+		Lang:          r.Lang,
+		Source:        r.Source,
+		PlaceId:       r.PlaceId,
+		PlaceFullName: r.PlaceFullName,
+		CountryCode:   r.CountryCode,
+	}
+
+	if !time.Time(r.CreatedAt).IsZero() {
+		createdAt := r.CreatedAt
+		d.CreatedAt = &createdAt
+	}
+
+	if r.Latitude != nil && r.Longitude != nil {
+		d.Location = &esGeoPoint{Lat: *r.Latitude, Lon: *r.Longitude}
+	}
+
+	if t := r.Tweet; t != nil {
+		d.UserId = t.UserId
+
+		for _, h := range t.Entities.Hashtags {
+			d.Hashtags = append(d.Hashtags, h.Text)
+		}
+
+		for _, m := range t.Entities.UserMentions {
+			d.Mentions = append(d.Mentions, m.ScreenName)
+		}
+
+		for _, u := range t.Entities.Urls {
+			d.Urls = append(d.Urls, u.ExpandedUrl)
+		}
+	}
+
+	return d
+}
+
+// EsBulkExporter writes records as the action and document lines of the bulk
+// API of Elasticsearch and OpenSearch, using tweet ids as document ids so that
+// tweets indexed again are replaced. The lines are written to a file, or posted
+// in batches if Client is set.
+type EsBulkExporter struct {
+	Name        string
+	Mode        OutputMode
+	Compression Compression
+	Index       IndexPattern
+	// Location is the time zone of the dates of index names. It defaults to UTC.
+	Location *time.Location
+	Client   *EsBulkClient
+	// BatchSize is the number of documents per bulk request.
+	BatchSize int
+	// Logger defaults to logging.Default().
+	Logger logging.Logger
+
+	out   *Output
+	buf   bytes.Buffer
+	batch int
+	open  bool
+}
+
+// Open creates the file unless the lines are posted.
+func (e *EsBulkExporter) Open() error {
+	if e.Client == nil {
+		out, err := Open(e.Name, e.Mode, e.Compression)
+		if err != nil {
+			return err
+		}
+
+		e.out = out
+		orDefault(e.Logger).Info("opened output", "file", e.Name, "mode", e.Mode)
+	}
+
+	e.open = true
+	return nil
+}
+
+// Close posts the last batch or closes the file. Calling Close more than once is a no-op.
+func (e *EsBulkExporter) Close() error {
+	if !e.open {
+		return nil
+	}
+
+	e.open = false
+	if e.out == nil {
+		return e.push()
+	}
+
+	err := e.out.Close()
+	e.out = nil
+	return err
+}
+
+// Export writes pages of records until ch is closed and closes the output.
+func (e *EsBulkExporter) Export(ch <-chan []Record) <-chan error {
+	return runExport(orDefault(e.Logger), "es-bulk", ch, func() error {
+		var err error
+		for records := range ch {
+			if err = e.WritePage(records); err != nil {
+				break
+			}
+		}
+
+		if cerr := e.Close(); err == nil {
+			err = cerr
+		}
+
+		return err
+	})
+}
+
+// WritePage writes the bulk lines of records, posting a batch once it is full.
+func (e *EsBulkExporter) WritePage(records []Record) error {
+	if !e.open {
+		if err := e.Open(); err != nil {
+			return err
+		}
+	}
+
+	if e.out != nil {
+		e.buf.Reset()
+	}
+
+	loc := e.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	enc := json.NewEncoder(&e.buf)
+	enc.SetEscapeHTML(false)
+	for i := range records {
+		r := &records[i]
+
+		var action esAction
+		action.Index.Index = e.Index.Name(time.Time(r.CreatedAt).In(loc))
+		action.Index.Id = strconv.FormatUint(r.Id, 10)
+		if err := enc.Encode(action); err != nil {
+			return err
+		}
+
+		if err := enc.Encode(NewEsDocument(r)); err != nil {
+			return err
+		}
+
+		if e.out == nil {
+			e.batch++
+			if e.batch >= e.batchSize() {
+				if err := e.push(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if e.out == nil {
+		return nil
+	}
+
+	if _, err := e.out.Write(e.buf.Bytes()); err != nil {
+		return err
+	}
+
+	return e.out.Flush()
+}
+
+func (e *EsBulkExporter) batchSize() int {
+	if e.BatchSize <= 0 {
+		return DefaultEsBatchSize
+	}
+
+	return e.BatchSize
+}
+
+func (e *EsBulkExporter) push() error {
+	if e.batch == 0 {
+		return nil
+	}
+
+	// A failed batch is dropped, as the client has already retried it.
+	err := e.Client.Push(e.buf.Bytes())
+	e.buf.Reset()
+	e.batch = 0
+	return err
+}
@@ -0,0 +1,112 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build small
+// +build small
+
+package export
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/akiomik/squawks/api/json"
+)
+
+func TestParseIndexPattern(t *testing.T) {
+	createdAt := time.Date(2020, 9, 6, 7, 1, 2, 0, time.UTC)
+
+	examples := map[string]struct {
+		pattern  string
+		expected string
+		msg      string
+	}{
+		"plain":         {pattern: "tweets", expected: "tweets", msg: ""},
+		"month":         {pattern: "tweets-{yyyy.MM}", expected: "tweets-2020.09", msg: ""},
+		"hour":          {pattern: "tweets-{yy_MM_dd-HH}-raw", expected: "tweets-20_09_06-07-raw", msg: ""},
+		"dates":         {pattern: "{yyyy}-tweets-{MM}", expected: "2020-tweets-09", msg: ""},
+		"empty":         {pattern: "", expected: "", msg: "invalid index pattern: empty"},
+		"unclosed":      {pattern: "tweets-{yyyy", expected: "", msg: "invalid index pattern: tweets-{yyyy: unclosed {"},
+		"unknown-field": {pattern: "tweets-{yyyy.ww}", expected: "", msg: "invalid index pattern: tweets-{yyyy.ww}: unknown date field: ww"},
+		"empty-date":    {pattern: "tweets-{}", expected: "", msg: "invalid index pattern: tweets-{}: empty date"},
+		"uppercase":     {pattern: "Tweets", expected: "", msg: "invalid index pattern: Tweets: index names must be lowercase without special characters"},
+		"special":       {pattern: "tweets}", expected: "", msg: "invalid index pattern: tweets}: index names must be lowercase without special characters"},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			p, err := ParseIndexPattern(e.pattern)
+			if len(e.msg) == 0 {
+				assert.NoError(t, err)
+				assert.Equal(t, e.expected, p.Name(createdAt))
+				assert.Equal(t, e.pattern, p.String())
+			} else {
+				assert.EqualError(t, err, e.msg)
+			}
+		})
+	}
+}
+
+func TestNewEsDocument(t *testing.T) {
+	latitude := 35.6851508
+	longitude := 139.7526768
+	createdAt := Iso8601Date(time.Date(2020, 9, 6, 0, 1, 2, 0, time.UTC))
+
+	examples := map[string]struct {
+		record   Record
+		expected EsDocument
+	}{
+		"full": {
+			record: Record{
+				Id:        1,
+				Username:  "watson",
+				CreatedAt: createdAt,
+				Latitude:  &latitude,
+				Longitude: &longitude,
+				PlaceId:   "p1",
+				Tweet: &json.Tweet{
+					UserId: 10,
+					Entities: json.Entities{
+						Hashtags:     []json.Hashtag{{Text: "baker"}},
+						UserMentions: []json.UserMention{{ScreenName: "holmes"}},
+						Urls:         []json.Url{{ExpandedUrl: "https://example.com"}},
+					},
+				},
+			},
+			expected: EsDocument{
+				Id:        1,
+				UserId:    10,
+				Username:  "watson",
+				CreatedAt: &createdAt,
+				Location:  &esGeoPoint{Lat: latitude, Lon: longitude},
+				Hashtags:  []string{"baker"},
+				Mentions:  []string{"holmes"},
+				Urls:      []string{"https://example.com"},
+				PlaceId:   "p1",
+			},
+		},
+		"empty": {
+			record:   Record{Id: 2},
+			expected: EsDocument{Id: 2},
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, e.expected, NewEsDocument(&e.record))
+		})
+	}
+}
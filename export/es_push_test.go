@@ -0,0 +1,201 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build medium
+// +build medium
+
+package export
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func esBulkPages() [][]Record {
+	createdAt := Iso8601Date(time.Date(2020, 9, 6, 23, 1, 2, 0, time.UTC))
+	return [][]Record{
+		{{Id: 1, Username: "watson", CreatedAt: createdAt, FullText: "<b>"}, {Id: 2, CreatedAt: createdAt}},
+		{{Id: 3, CreatedAt: createdAt}},
+	}
+}
+
+func exportEsBulk(t *testing.T, e *EsBulkExporter) error {
+	ch := make(chan []Record, 2)
+	for _, p := range esBulkPages() {
+		ch <- p
+	}
+	close(ch)
+
+	if err := e.Open(); err != nil {
+		return err
+	}
+
+	return <-e.Export(ch)
+}
+
+func TestEsBulkExporterFile(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	index, err := ParseIndexPattern("tweets-{yyyy.MM.dd}")
+	assert.NoError(t, err)
+
+	name := filepath.Join(t.TempDir(), "out.ndjson")
+	err = exportEsBulk(t, &EsBulkExporter{Name: name, Index: index, Location: tokyo})
+	assert.NoError(t, err)
+
+	expected := `{"index":{"_index":"tweets-2020.09.07","_id":"1"}}
+{"id":1,"username":"watson","created_at":"2020-09-06T23:01:02+00:00","full_text":"<b>","retweet_count":0,"favorite_count":0,"reply_count":0,"quote_count":0,"lang":"","source":""}
+{"index":{"_index":"tweets-2020.09.07","_id":"2"}}
+{"id":2,"username":"","created_at":"2020-09-06T23:01:02+00:00","full_text":"","retweet_count":0,"favorite_count":0,"reply_count":0,"quote_count":0,"lang":"","source":""}
+{"index":{"_index":"tweets-2020.09.07","_id":"3"}}
+{"id":3,"username":"","created_at":"2020-09-06T23:01:02+00:00","full_text":"","retweet_count":0,"favorite_count":0,"reply_count":0,"quote_count":0,"lang":"","source":""}
+`
+	actual, err := os.ReadFile(name)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(actual))
+}
+
+// esStandIn is a stand-in bulk endpoint that fails the first requests with a
+// status and records the documents of accepted requests.
+type esStandIn struct {
+	mu       sync.Mutex
+	failures int
+	status   int
+	response string
+	bodies   []string
+	requests int
+}
+
+func (s *esStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+	if r.URL.Path != "/_bulk" || r.Header.Get("Content-Type") != "application/x-ndjson" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if s.failures > 0 {
+		s.failures--
+		w.WriteHeader(s.status)
+		io.WriteString(w, s.response)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	s.bodies = append(s.bodies, string(body))
+	w.Header().Set("Content-Type", "application/json")
+	io.WriteString(w, s.response)
+}
+
+func TestEsBulkExporterPush(t *testing.T) {
+	index, err := ParseIndexPattern("tweets")
+	assert.NoError(t, err)
+
+	examples := map[string]struct {
+		failures         int
+		status           int
+		response         string
+		expectedRequests int
+		expectedBatches  int
+		msg              string
+	}{
+		"ok": {
+			failures:         0,
+			response:         `{"errors":false,"items":[]}`,
+			expectedRequests: 2,
+			expectedBatches:  2,
+			msg:              "",
+		},
+		"retried": {
+			failures:         2,
+			status:           http.StatusTooManyRequests,
+			response:         `{"errors":false,"items":[]}`,
+			expectedRequests: 4,
+			expectedBatches:  2,
+			msg:              "",
+		},
+		"retry-limit": {
+			failures:         10,
+			status:           http.StatusServiceUnavailable,
+			expectedRequests: 3,
+			expectedBatches:  0,
+			msg:              "failed to post bulk request: 503 Service Unavailable: ",
+		},
+		"long-error": {
+			failures:         1,
+			status:           http.StatusBadRequest,
+			response:         strings.Repeat("エラー", 100),
+			expectedRequests: 1,
+			expectedBatches:  0,
+			msg:              "failed to post bulk request: 400 Bad Request: " + strings.Repeat("エラー", 66) + "エ…",
+		},
+		"rejected": {
+			failures:         0,
+			response:         `{"errors":true,"items":[{"index":{"_id":"1","status":201}},{"index":{"_id":"2","status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse"}}}]}`,
+			expectedRequests: 1,
+			expectedBatches:  1,
+			msg:              "failed to index 1 of 2 documents (2: mapper_parsing_exception: failed to parse)",
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			s := &esStandIn{failures: e.failures, status: e.status, response: e.response}
+			server := httptest.NewServer(s)
+			defer server.Close()
+
+			client, err := NewEsBulkClient(server.URL+"/", 2)
+			if !assert.NoError(t, err) {
+				return
+			}
+			client.Client.SetRetryWaitTime(time.Millisecond).SetRetryMaxWaitTime(time.Millisecond)
+
+			err = exportEsBulk(t, &EsBulkExporter{Index: index, Client: client, BatchSize: 2})
+			if len(e.msg) == 0 {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, e.msg)
+			}
+
+			assert.Equal(t, e.expectedRequests, s.requests)
+			assert.Len(t, s.bodies, e.expectedBatches)
+			if e.expectedBatches == 2 {
+				assert.Equal(t, 4, strings.Count(s.bodies[0], "\n"))
+				assert.Equal(t, 2, strings.Count(s.bodies[1], "\n"))
+				assert.Contains(t, s.bodies[1], `{"index":{"_index":"tweets","_id":"3"}}`)
+			}
+		})
+	}
+}
+
+func TestNewEsBulkClient(t *testing.T) {
+	for _, u := range []string{"localhost:9200", "ftp://localhost", "http://"} {
+		_, err := NewEsBulkClient(u, 0)
+		assert.EqualError(t, err, "invalid elasticsearch url: "+u)
+	}
+}
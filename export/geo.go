@@ -0,0 +1,264 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/akiomik/squawks/api/json"
+	"github.com/akiomik/squawks/logging"
+)
+
+// GeoPlaces selects how tweets located only by their place are exported.
+type GeoPlaces string
+
+const (
+	// GeoPlacesNone skips tweets without exact coordinates.
+	GeoPlacesNone GeoPlaces = "none"
+	// GeoPlacesPolygon exports the bounding box of the place as a polygon.
+	GeoPlacesPolygon GeoPlaces = "polygon"
+	// GeoPlacesCentroid exports the centroid of the bounding box as a point.
+	GeoPlacesCentroid GeoPlaces = "centroid"
+)
+
+func ParseGeoPlaces(s string) (GeoPlaces, error) {
+	switch p := GeoPlaces(s); p {
+	case "":
+		return GeoPlacesNone, nil
+	case GeoPlacesNone, GeoPlacesPolygon, GeoPlacesCentroid:
+		return p, nil
+	default:
+		return GeoPlacesNone, fmt.Errorf("unknown geo places: %s", s)
+	}
+}
+
+// GeoFallback selects the location exported for tweets without exact coordinates.
+type GeoFallback string
+
+const (
+	GeoFallbackNone GeoFallback = "none"
+	// GeoFallbackCentroid exports the centroid of the place of the tweet.
+	GeoFallbackCentroid GeoFallback = "centroid"
+)
+
+func ParseGeoFallback(s string) (GeoFallback, error) {
+	switch f := GeoFallback(s); f {
+	case "":
+		return GeoFallbackNone, nil
+	case GeoFallbackNone, GeoFallbackCentroid:
+		return f, nil
+	default:
+		return GeoFallbackNone, fmt.Errorf("unknown geo fallback: %s", s)
+	}
+}
+
+// Geometry is the location of a tweet, either a point or a polygon.
+type Geometry struct {
+	Point *json.LongLat
+	// Polygon is a closed ring.
+	Polygon []json.LongLat
+}
+
+// NewGeometry returns the exact coordinates of r or, depending on places, the
+// bounding box or centroid of its place. It returns false if r has no location.
+func NewGeometry(r *Record, places GeoPlaces) (Geometry, bool) {
+	if r.Latitude != nil && r.Longitude != nil {
+		return Geometry{Point: &json.LongLat{*r.Longitude, *r.Latitude}}, true
+	}
+
+	t := r.Tweet
+	if t == nil {
+		return Geometry{}, false
+	}
+
+	if t.Geo != nil {
+		return Geometry{Point: &json.LongLat{t.Geo.Coordinates.Longitude(), t.Geo.Coordinates.Latitude()}}, true
+	}
+
+	if t.Place == nil {
+		return Geometry{}, false
+	}
+
+	switch places {
+	case GeoPlacesPolygon:
+		if ring := t.Place.BoundingBox.Ring(); len(ring) != 0 {
+			return Geometry{Polygon: ring}, true
+		}
+	case GeoPlacesCentroid:
+		if c, ok := t.Place.BoundingBox.Centroid(); ok {
+			return Geometry{Point: &c}, true
+		}
+	}
+
+	return Geometry{}, false
+}
+
+// geoProperties are the tweet properties attached to a feature.
+type geoProperties struct {
+	Id            string `json:"id"`
+	Username      string `json:"username"`
+	CreatedAt     string `json:"created_at"`
+	FullText      string `json:"full_text"`
+	RetweetCount  uint64 `json:"retweet_count"`
+	FavoriteCount uint64 `json:"favorite_count"`
+	ReplyCount    uint64 `json:"reply_count"`
+	QuoteCount    uint64 `json:"quote_count"`
+	Lang          string `json:"lang"`
+	Source        string `json:"source"`
+	Place         string `json:"place,omitempty"`
+}
+
+func newGeoProperties(r *Record, f TimeFormat) geoProperties {
+	p := geoProperties{
+		// Ids are strings, as they do not fit in the numbers of many GIS tools.
+		Id:            strconv.FormatUint(r.Id, 10),
+		Username:      r.Username,
+		CreatedAt:     f.Format(time.Time(r.CreatedAt)),
+		FullText:      r.FullText,
+		RetweetCount:  r.RetweetCount,
+		FavoriteCount: r.FavoriteCount,
+		ReplyCount:    r.ReplyCount,
+		QuoteCount:    r.QuoteCount,
+		Lang:          r.Lang,
+		Source:        r.Source,
+	}
+
+	if r.Tweet != nil && r.Tweet.Place != nil {
+		p.Place = r.Tweet.Place.FullName
+	}
+
+	return p
+}
+
+// geoWriter encodes features in a geographic format.
+type geoWriter interface {
+	Begin() error
+	WriteFeature(r *Record, g Geometry) error
+	End() error
+}
+
+// GeoExporter writes located records as features of a GeoJSON
+// FeatureCollection or a KML document. Records without a location are skipped.
+type GeoExporter struct {
+	Name string
+	// Format is geojson or kml.
+	Format string
+	// Mode is OutputCreate or OutputOverwrite, as a document cannot be appended to.
+	Mode        OutputMode
+	Compression Compression
+	Places      GeoPlaces
+	TimeFormat  TimeFormat
+	// Skipped counts the records without a location.
+	Skipped uint64
+	// Logger defaults to logging.Default().
+	Logger logging.Logger
+
+	out *Output
+	w   geoWriter
+}
+
+// Open creates the file and begins the document.
+func (e *GeoExporter) Open() error {
+	if e.Mode == OutputAppend {
+		return fmt.Errorf("%s output cannot be appended to", e.Format)
+	}
+
+	out, err := Open(e.Name, e.Mode, e.Compression)
+	if err != nil {
+		return err
+	}
+
+	switch e.Format {
+	case "geojson":
+		e.w = newGeoJsonWriter(out, e.TimeFormat)
+	case "kml":
+		e.w = newKmlWriter(out, e.TimeFormat)
+	default:
+		out.Close()
+		return fmt.Errorf("unsupported format: %s", e.Format)
+	}
+
+	if err := e.w.Begin(); err != nil {
+		out.Close()
+		return err
+	}
+
+	e.out = out
+	orDefault(e.Logger).Info("opened output", "file", e.Name, "mode", e.Mode)
+	return nil
+}
+
+// Close ends the document and closes the file. Calling Close more than once is a no-op.
+func (e *GeoExporter) Close() error {
+	if e.out == nil {
+		return nil
+	}
+
+	err := e.w.End()
+	if cerr := e.out.Close(); err == nil {
+		err = cerr
+	}
+
+	e.out = nil
+	e.w = nil
+	return err
+}
+
+// Export writes pages of records until ch is closed and closes the file.
+func (e *GeoExporter) Export(ch <-chan []Record) <-chan error {
+	return runExport(orDefault(e.Logger), e.Format, ch, func() error {
+		var err error
+		for records := range ch {
+			if err = e.WritePage(records); err != nil {
+				break
+			}
+		}
+
+		if e.Skipped != 0 {
+			orDefault(e.Logger).Info("skipped tweets without a location", "format", e.Format, "tweets", e.Skipped)
+		}
+
+		if cerr := e.Close(); err == nil {
+			err = cerr
+		}
+
+		return err
+	})
+}
+
+// WritePage writes a feature for each located record.
+func (e *GeoExporter) WritePage(records []Record) error {
+	if e.out == nil {
+		if err := e.Open(); err != nil {
+			return err
+		}
+	}
+
+	for i := range records {
+		g, ok := NewGeometry(&records[i], e.Places)
+		if !ok {
+			e.Skipped++
+			continue
+		}
+
+		if err := e.w.WriteFeature(&records[i], g); err != nil {
+			return err
+		}
+	}
+
+	return e.out.Flush()
+}
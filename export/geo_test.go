@@ -0,0 +1,122 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build small
+// +build small
+
+package export
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/akiomik/squawks/api/json"
+)
+
+func TestParseGeoPlaces(t *testing.T) {
+	examples := map[string]struct {
+		value    string
+		expected GeoPlaces
+		msg      string
+	}{
+		"empty":    {value: "", expected: GeoPlacesNone, msg: ""},
+		"polygon":  {value: "polygon", expected: GeoPlacesPolygon, msg: ""},
+		"centroid": {value: "centroid", expected: GeoPlacesCentroid, msg: ""},
+		"unknown":  {value: "bbox", expected: GeoPlacesNone, msg: "unknown geo places: bbox"},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			actual, err := ParseGeoPlaces(e.value)
+			if len(e.msg) == 0 {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, e.msg)
+			}
+			assert.Equal(t, e.expected, actual)
+		})
+	}
+}
+
+func TestNewGeometry(t *testing.T) {
+	latitude := 35.6851508
+	longitude := 139.7526768
+	place := &json.Place{BoundingBox: json.BoundingBox{Type: "Polygon", Coordinates: [][]json.LongLat{{{139, 35}, {140, 35}, {140, 36}, {139, 36}}}}}
+	ring := []json.LongLat{{139, 35}, {140, 35}, {140, 36}, {139, 36}, {139, 35}}
+
+	examples := map[string]struct {
+		record   Record
+		places   GeoPlaces
+		expected Geometry
+		ok       bool
+	}{
+		"coordinates": {
+			record:   Record{Latitude: &latitude, Longitude: &longitude, Tweet: &json.Tweet{Place: place}},
+			places:   GeoPlacesPolygon,
+			expected: Geometry{Point: &json.LongLat{longitude, latitude}},
+			ok:       true,
+		},
+		"geo": {
+			record:   Record{Tweet: &json.Tweet{Geo: &json.Geo{Coordinates: json.LatLong{latitude, longitude}}}},
+			places:   GeoPlacesNone,
+			expected: Geometry{Point: &json.LongLat{longitude, latitude}},
+			ok:       true,
+		},
+		"place-none": {
+			record:   Record{Tweet: &json.Tweet{Place: place}},
+			places:   GeoPlacesNone,
+			expected: Geometry{},
+			ok:       false,
+		},
+		"place-polygon": {
+			record:   Record{Tweet: &json.Tweet{Place: place}},
+			places:   GeoPlacesPolygon,
+			expected: Geometry{Polygon: ring},
+			ok:       true,
+		},
+		"place-centroid": {
+			record:   Record{Tweet: &json.Tweet{Place: place}},
+			places:   GeoPlacesCentroid,
+			expected: Geometry{Point: &json.LongLat{139.5, 35.5}},
+			ok:       true,
+		},
+		"place-empty": {
+			record:   Record{Tweet: &json.Tweet{Place: &json.Place{}}},
+			places:   GeoPlacesCentroid,
+			expected: Geometry{},
+			ok:       false,
+		},
+		"no-location": {
+			record:   Record{Tweet: &json.Tweet{}},
+			places:   GeoPlacesCentroid,
+			expected: Geometry{},
+			ok:       false,
+		},
+		"no-tweet": {
+			record:   Record{},
+			places:   GeoPlacesCentroid,
+			expected: Geometry{},
+			ok:       false,
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			actual, ok := NewGeometry(&e.record, e.places)
+			assert.Equal(t, e.ok, ok)
+			assert.Equal(t, e.expected, actual)
+		})
+	}
+}
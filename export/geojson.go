@@ -0,0 +1,83 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+type geoJsonGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+type geoJsonFeature struct {
+	Type       string          `json:"type"`
+	Geometry   geoJsonGeometry `json:"geometry"`
+	Properties geoProperties   `json:"properties"`
+}
+
+// geoJsonWriter writes a FeatureCollection, one feature per line.
+type geoJsonWriter struct {
+	out      io.Writer
+	time     TimeFormat
+	buf      bytes.Buffer
+	enc      *json.Encoder
+	features int
+}
+
+func newGeoJsonWriter(out io.Writer, f TimeFormat) *geoJsonWriter {
+	w := &geoJsonWriter{out: out, time: f}
+	w.enc = json.NewEncoder(&w.buf)
+	w.enc.SetEscapeHTML(false)
+	return w
+}
+
+func (w *geoJsonWriter) Begin() error {
+	_, err := io.WriteString(w.out, `{"type":"FeatureCollection","features":[`)
+	return err
+}
+
+func (w *geoJsonWriter) WriteFeature(r *Record, g Geometry) error {
+	f := geoJsonFeature{Type: "Feature", Properties: newGeoProperties(r, w.time)}
+	if g.Point != nil {
+		f.Geometry = geoJsonGeometry{Type: "Point", Coordinates: g.Point}
+	} else {
+		f.Geometry = geoJsonGeometry{Type: "Polygon", Coordinates: []interface{}{g.Polygon}}
+	}
+
+	w.buf.Reset()
+	if w.features == 0 {
+		w.buf.WriteString("\n")
+	} else {
+		w.buf.WriteString(",\n")
+	}
+
+	if err := w.enc.Encode(f); err != nil {
+		return err
+	}
+	w.features++
+
+	// Drop the newline of Encode, so that the separator is written before the next feature.
+	_, err := w.out.Write(bytes.TrimSuffix(w.buf.Bytes(), []byte("\n")))
+	return err
+}
+
+func (w *geoJsonWriter) End() error {
+	_, err := io.WriteString(w.out, "\n]}\n")
+	return err
+}
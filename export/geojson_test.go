@@ -0,0 +1,64 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build small
+// +build small
+
+package export
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/akiomik/squawks/api/json"
+)
+
+func TestGeoJsonWriter(t *testing.T) {
+	examples := map[string]struct {
+		geometries []Geometry
+		expected   string
+	}{
+		"empty": {
+			geometries: []Geometry{},
+			expected:   "{\"type\":\"FeatureCollection\",\"features\":[\n]}\n",
+		},
+		"features": {
+			geometries: []Geometry{
+				{Point: &json.LongLat{139.5, 35.5}},
+				{Polygon: []json.LongLat{{139, 35}, {140, 35}, {140, 36}, {139, 35}}},
+			},
+			expected: "{\"type\":\"FeatureCollection\",\"features\":[\n" +
+				`{"type":"Feature","geometry":{"type":"Point","coordinates":[139.5,35.5]},"properties":{"id":"1","username":"watson","created_at":"2020-09-06T00:01:02+00:00","full_text":"<b>","retweet_count":3,"favorite_count":0,"reply_count":0,"quote_count":0,"lang":"en","source":""}},` + "\n" +
+				`{"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[139,35],[140,35],[140,36],[139,35]]]},"properties":{"id":"1","username":"watson","created_at":"2020-09-06T00:01:02+00:00","full_text":"<b>","retweet_count":3,"favorite_count":0,"reply_count":0,"quote_count":0,"lang":"en","source":""}}` + "\n" +
+				"]}\n",
+		},
+	}
+
+	r := Record{Id: 1, Username: "watson", CreatedAt: Iso8601Date(time.Date(2020, 9, 6, 0, 1, 2, 0, time.UTC)), FullText: "<b>", RetweetCount: 3, Lang: "en"}
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			w := newGeoJsonWriter(buf, TimeFormat{})
+			assert.NoError(t, w.Begin())
+			for _, g := range e.geometries {
+				assert.NoError(t, w.WriteFeature(&r, g))
+			}
+			assert.NoError(t, w.End())
+			assert.Equal(t, e.expected, buf.String())
+		})
+	}
+}
@@ -0,0 +1,408 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/akiomik/squawks/logging"
+)
+
+const (
+	// DefaultHtmlTitle is the title of html reports.
+	DefaultHtmlTitle = "Tweets"
+	// htmlTopItems is the length of the lists of top hashtags, mentions and authors.
+	htmlTopItems    = 10
+	htmlChartWidth  = 800
+	htmlChartHeight = 160
+)
+
+//go:embed html.tmpl
+var htmlTemplateText string
+
+var htmlTemplate = template.Must(template.New("html").Parse(htmlTemplateText))
+
+// htmlTweet is the part of a record shown in html reports.
+type htmlTweet struct {
+	Id            string
+	Username      string
+	Name          string
+	CreatedAt     time.Time
+	Time          string
+	Text          string
+	Url           string
+	RetweetCount  uint64
+	FavoriteCount uint64
+	ReplyCount    uint64
+	QuoteCount    uint64
+	Hashtags      []string
+	Mentions      []string
+}
+
+func newHtmlTweet(r *Record, f TimeFormat) htmlTweet {
+	t := htmlTweet{
+		Id:            strconv.FormatUint(r.Id, 10),
+		Username:      r.Username,
+		CreatedAt:     time.Time(r.CreatedAt),
+		Time:          f.Format(time.Time(r.CreatedAt)),
+		Text:          r.FullText,
+		RetweetCount:  r.RetweetCount,
+		FavoriteCount: r.FavoriteCount,
+		ReplyCount:    r.ReplyCount,
+		QuoteCount:    r.QuoteCount,
+	}
+
+	if r.User != nil {
+		t.Name = r.User.Name
+	}
+
+	if r.Tweet != nil {
+		t.Text = ExpandUrls(r)
+		for _, h := range r.Tweet.Entities.Hashtags {
+			t.Hashtags = append(t.Hashtags, h.Text)
+		}
+
+		for _, m := range r.Tweet.Entities.UserMentions {
+			t.Mentions = append(t.Mentions, m.ScreenName)
+		}
+	}
+
+	if len(t.Username) != 0 {
+		t.Url = fmt.Sprintf("https://twitter.com/%s/status/%s", t.Username, t.Id)
+	}
+
+	return t
+}
+
+// Initial is the letter shown in place of the avatar of the author.
+func (t htmlTweet) Initial() string {
+	name := t.Name
+	if len(name) == 0 {
+		name = t.Username
+	}
+
+	r, _ := utf8.DecodeRuneInString(name)
+	if r == utf8.RuneError {
+		return "?"
+	}
+
+	return strings.ToUpper(string(r))
+}
+
+// Search is the lowercased text matched by the search box.
+func (t htmlTweet) Search() string {
+	fields := []string{"@" + t.Username, t.Name, t.Text}
+	for _, h := range t.Hashtags {
+		fields = append(fields, "#"+h)
+	}
+
+	return strings.ToLower(strings.Join(fields, " "))
+}
+
+// htmlCount is an item of a top list.
+type htmlCount struct {
+	Name  string
+	Count int
+	// Query is searched when the item is clicked.
+	Query string
+}
+
+// htmlBar is a bar of the volume chart.
+type htmlBar struct {
+	Label  string
+	Count  int
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+}
+
+type htmlChart struct {
+	Width  int
+	Height int
+	// Unit is hour, day or month.
+	Unit string
+	Max  int
+	Bars []htmlBar
+	// First and Last are the labels of the first and last bars.
+	First string
+	Last  string
+}
+
+// htmlTop is a list of the most frequent hashtags, mentions or authors.
+type htmlTop struct {
+	Title string
+	Items []htmlCount
+}
+
+type htmlReport struct {
+	Title   string
+	Tweets  []htmlTweet
+	Authors int
+	From    string
+	To      string
+	Chart   htmlChart
+	Tops    []htmlTop
+}
+
+func newHtmlReport(title string, tweets []htmlTweet, f TimeFormat) htmlReport {
+	r := htmlReport{Title: title, Tweets: tweets}
+
+	hashtags := newCounter()
+	mentions := newCounter()
+	authors := newCounter()
+	var from, to time.Time
+	for _, t := range tweets {
+		for _, h := range t.Hashtags {
+			hashtags.add(strings.ToLower(h), "#"+h)
+		}
+
+		for _, m := range t.Mentions {
+			mentions.add(strings.ToLower(m), "@"+m)
+		}
+
+		if len(t.Username) != 0 {
+			authors.add(strings.ToLower(t.Username), "@"+t.Username)
+		}
+
+		if t.CreatedAt.IsZero() {
+			continue
+		}
+
+		if from.IsZero() || t.CreatedAt.Before(from) {
+			from = t.CreatedAt
+		}
+
+		if to.IsZero() || t.CreatedAt.After(to) {
+			to = t.CreatedAt
+		}
+	}
+
+	r.Authors = len(authors.counts)
+	r.From = f.Format(from)
+	r.To = f.Format(to)
+	r.Tops = []htmlTop{
+		{Title: "Top hashtags", Items: hashtags.top(htmlTopItems)},
+		{Title: "Top mentions", Items: mentions.top(htmlTopItems)},
+		{Title: "Top authors", Items: authors.top(htmlTopItems)},
+	}
+	r.Chart = newHtmlChart(tweets, from, to, f)
+	return r
+}
+
+// counter counts items by a key, showing each as the first name seen.
+type counter struct {
+	counts map[string]int
+	names  map[string]string
+}
+
+func newCounter() *counter {
+	return &counter{counts: map[string]int{}, names: map[string]string{}}
+}
+
+func (c *counter) add(key string, name string) {
+	if _, ok := c.names[key]; !ok {
+		c.names[key] = name
+	}
+
+	c.counts[key]++
+}
+
+// top returns the n most counted items, breaking ties by name.
+func (c *counter) top(n int) []htmlCount {
+	items := make([]htmlCount, 0, len(c.counts))
+	for key, count := range c.counts {
+		items = append(items, htmlCount{Name: c.names[key], Count: count, Query: strings.ToLower(c.names[key])})
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Count != items[j].Count {
+			return items[i].Count > items[j].Count
+		}
+
+		return items[i].Query < items[j].Query
+	})
+
+	if len(items) > n {
+		items = items[:n]
+	}
+
+	return items
+}
+
+// newHtmlChart counts tweets per hour for up to 3 days, per day for up to a
+// year and per month otherwise, including periods without tweets.
+func newHtmlChart(tweets []htmlTweet, from time.Time, to time.Time, f TimeFormat) htmlChart {
+	c := htmlChart{Width: htmlChartWidth, Height: htmlChartHeight}
+	if from.IsZero() {
+		return c
+	}
+
+	from = f.In(from)
+	to = f.In(to)
+
+	var next func(t time.Time) time.Time
+	var layout string
+	switch span := to.Sub(from); {
+	case span <= 72*time.Hour:
+		c.Unit = "hour"
+		layout = "2006-01-02 15:00"
+		next = func(t time.Time) time.Time { return t.Add(time.Hour) }
+	case span <= 366*24*time.Hour:
+		c.Unit = "day"
+		layout = "2006-01-02"
+		next = func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+	default:
+		c.Unit = "month"
+		layout = "2006-01"
+		next = func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }
+	}
+
+	start := truncateTime(from, c.Unit)
+	counts := map[time.Time]int{}
+	for _, t := range tweets {
+		if !t.CreatedAt.IsZero() {
+			counts[truncateTime(f.In(t.CreatedAt), c.Unit)]++
+		}
+	}
+
+	for t := start; !t.After(to); t = next(t) {
+		n := counts[t]
+		c.Bars = append(c.Bars, htmlBar{Label: t.Format(layout), Count: n})
+		if n > c.Max {
+			c.Max = n
+		}
+	}
+
+	c.First = c.Bars[0].Label
+	c.Last = c.Bars[len(c.Bars)-1].Label
+	width := float64(c.Width) / float64(len(c.Bars))
+	for i := range c.Bars {
+		b := &c.Bars[i]
+		b.Width = width
+		b.X = width * float64(i)
+		b.Height = float64(c.Height) * float64(b.Count) / float64(c.Max)
+		b.Y = float64(c.Height) - b.Height
+	}
+
+	return c
+}
+
+func truncateTime(t time.Time, unit string) time.Time {
+	switch unit {
+	case "hour":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	case "day":
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	}
+}
+
+// HtmlExporter writes a self-contained html report of records with a volume
+// chart, the top hashtags, mentions and authors, and the tweets as cards and a
+// table filtered by a search box. Records are kept in memory until Close.
+type HtmlExporter struct {
+	Name string
+	// Mode is OutputCreate or OutputOverwrite, as a report cannot be appended to.
+	Mode        OutputMode
+	Compression Compression
+	// Title defaults to DefaultHtmlTitle.
+	Title      string
+	TimeFormat TimeFormat
+	// Logger defaults to logging.Default().
+	Logger logging.Logger
+
+	out    *Output
+	tweets []htmlTweet
+}
+
+// Open creates the file, which is written on Close.
+func (e *HtmlExporter) Open() error {
+	if e.Mode == OutputAppend {
+		return fmt.Errorf("html output cannot be appended to")
+	}
+
+	out, err := Open(e.Name, e.Mode, e.Compression)
+	if err != nil {
+		return err
+	}
+
+	e.out = out
+	e.tweets = nil
+	orDefault(e.Logger).Info("opened output", "file", e.Name, "mode", e.Mode)
+	return nil
+}
+
+// Close writes the report and closes the file. Calling Close more than once is a no-op.
+func (e *HtmlExporter) Close() error {
+	if e.out == nil {
+		return nil
+	}
+
+	title := e.Title
+	if len(title) == 0 {
+		title = DefaultHtmlTitle
+	}
+
+	err := htmlTemplate.Execute(e.out, newHtmlReport(title, e.tweets, e.TimeFormat))
+	if cerr := e.out.Close(); err == nil {
+		err = cerr
+	}
+
+	e.out = nil
+	e.tweets = nil
+	return err
+}
+
+// Export collects pages of records until ch is closed and writes the report.
+func (e *HtmlExporter) Export(ch <-chan []Record) <-chan error {
+	return runExport(orDefault(e.Logger), "html", ch, func() error {
+		var err error
+		for records := range ch {
+			if err = e.WritePage(records); err != nil {
+				break
+			}
+		}
+
+		if cerr := e.Close(); err == nil {
+			err = cerr
+		}
+
+		return err
+	})
+}
+
+// WritePage adds records to the report.
+func (e *HtmlExporter) WritePage(records []Record) error {
+	if e.out == nil {
+		if err := e.Open(); err != nil {
+			return err
+		}
+	}
+
+	for i := range records {
+		e.tweets = append(e.tweets, newHtmlTweet(&records[i], e.TimeFormat))
+	}
+
+	return nil
+}
@@ -0,0 +1,178 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build medium
+// +build medium
+
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/akiomik/squawks/api/json"
+)
+
+func TestNewHtmlChart(t *testing.T) {
+	base := time.Date(2020, 9, 6, 0, 30, 0, 0, time.UTC)
+	examples := map[string]struct {
+		times          []time.Time
+		expectedUnit   string
+		expectedCounts []int
+		expectedLabels []string
+	}{
+		"empty": {
+			times:          nil,
+			expectedUnit:   "",
+			expectedCounts: nil,
+			expectedLabels: nil,
+		},
+		"hour": {
+			times:          []time.Time{base, base.Add(10 * time.Minute), base.Add(2 * time.Hour)},
+			expectedUnit:   "hour",
+			expectedCounts: []int{2, 0, 1},
+			expectedLabels: []string{"2020-09-06 00:00", "2020-09-06 01:00", "2020-09-06 02:00"},
+		},
+		"day": {
+			times:          []time.Time{base, base.AddDate(0, 0, 4), base.AddDate(0, 0, 4)},
+			expectedUnit:   "day",
+			expectedCounts: []int{1, 0, 0, 0, 2},
+			expectedLabels: []string{"2020-09-06", "2020-09-07", "2020-09-08", "2020-09-09", "2020-09-10"},
+		},
+		"month": {
+			times:          []time.Time{base, base.AddDate(1, 1, 0)},
+			expectedUnit:   "month",
+			expectedCounts: []int{1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1},
+			expectedLabels: nil,
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			tweets := make([]htmlTweet, 0, len(e.times))
+			var from, to time.Time
+			for _, c := range e.times {
+				tweets = append(tweets, htmlTweet{CreatedAt: c})
+				if from.IsZero() || c.Before(from) {
+					from = c
+				}
+
+				if c.After(to) {
+					to = c
+				}
+			}
+
+			c := newHtmlChart(tweets, from, to, TimeFormat{})
+			assert.Equal(t, e.expectedUnit, c.Unit)
+
+			var counts []int
+			var labels []string
+			for _, b := range c.Bars {
+				counts = append(counts, b.Count)
+				labels = append(labels, b.Label)
+				assert.LessOrEqual(t, b.X+b.Width, float64(c.Width)+0.001)
+				assert.InDelta(t, float64(c.Height), b.Y+b.Height, 0.001)
+			}
+
+			assert.Equal(t, e.expectedCounts, counts)
+			if e.expectedLabels != nil {
+				assert.Equal(t, e.expectedLabels, labels)
+			}
+		})
+	}
+}
+
+func TestNewHtmlChartTimezone(t *testing.T) {
+	jst := time.FixedZone("JST", 9*60*60)
+	createdAt := time.Date(2020, 9, 5, 20, 0, 0, 0, time.UTC)
+	tweets := []htmlTweet{{CreatedAt: createdAt}, {CreatedAt: createdAt.AddDate(0, 0, 5)}}
+
+	c := newHtmlChart(tweets, tweets[0].CreatedAt, tweets[1].CreatedAt, TimeFormat{Location: jst})
+	assert.Equal(t, "2020-09-06", c.First)
+	assert.Equal(t, "2020-09-11", c.Last)
+}
+
+func TestNewHtmlReport(t *testing.T) {
+	createdAt := time.Date(2020, 9, 6, 0, 1, 2, 0, time.UTC)
+	tweets := []htmlTweet{
+		{Username: "watson", CreatedAt: createdAt, Hashtags: []string{"Baker", "street"}, Mentions: []string{"holmes"}},
+		{Username: "holmes", CreatedAt: createdAt.Add(time.Hour), Hashtags: []string{"baker"}},
+		{Username: "Watson", CreatedAt: createdAt.Add(-time.Hour)},
+	}
+
+	r := newHtmlReport("Digest", tweets, TimeFormat{})
+	assert.Equal(t, "Digest", r.Title)
+	assert.Equal(t, 2, r.Authors)
+	assert.Equal(t, "2020-09-05T23:01:02+00:00", r.From)
+	assert.Equal(t, "2020-09-06T01:01:02+00:00", r.To)
+	assert.Equal(t, []htmlTop{
+		{Title: "Top hashtags", Items: []htmlCount{{Name: "#Baker", Count: 2, Query: "#baker"}, {Name: "#street", Count: 1, Query: "#street"}}},
+		{Title: "Top mentions", Items: []htmlCount{{Name: "@holmes", Count: 1, Query: "@holmes"}}},
+		{Title: "Top authors", Items: []htmlCount{{Name: "@watson", Count: 2, Query: "@watson"}, {Name: "@holmes", Count: 1, Query: "@holmes"}}},
+	}, r.Tops)
+}
+
+func TestHtmlExporter(t *testing.T) {
+	createdAt := time.Date(2020, 9, 6, 0, 1, 2, 0, time.UTC)
+	tweet := json.Tweet{
+		Entities: json.Entities{
+			Hashtags: []json.Hashtag{{Text: "baker"}},
+			Urls:     []json.Url{{Url: "https://t.co/x", ExpandedUrl: "https://example.com/a"}},
+		},
+	}
+	user := json.User{Name: "John Watson"}
+	pages := [][]Record{
+		{{Id: 1, Username: "watson", CreatedAt: Iso8601Date(createdAt), FullText: "<b>hi</b> https://t.co/x #baker", RetweetCount: 3, Tweet: &tweet, User: &user}},
+		{{Id: 2, Username: "holmes", CreatedAt: Iso8601Date(createdAt), FullText: "b"}},
+	}
+
+	out := filepath.Join(t.TempDir(), "out.html")
+	exporter := &HtmlExporter{Name: out, Title: "Weekly <digest>"}
+	if !assert.NoError(t, exporter.Open()) {
+		return
+	}
+
+	ch := make(chan []Record, len(pages))
+	for _, p := range pages {
+		ch <- p
+	}
+	close(ch)
+	assert.NoError(t, <-exporter.Export(ch))
+
+	actual, err := os.ReadFile(out)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	html := string(actual)
+	assert.Contains(t, html, "<title>Weekly &lt;digest&gt;</title>")
+	assert.Contains(t, html, "<p>&lt;b&gt;hi&lt;/b&gt; https://example.com/a #baker</p>")
+	assert.Contains(t, html, `<a href="https://twitter.com/watson/status/1"`)
+	assert.Contains(t, html, `<strong>John Watson</strong>`)
+	assert.Contains(t, html, `data-search="@watson john watson &lt;b&gt;hi&lt;/b&gt; https://example.com/a #baker #baker"`)
+	assert.Contains(t, html, `<button type="button" data-query="#baker">#baker</button>`)
+	assert.Contains(t, html, "<title>2020-09-06 00:00: 2</title>")
+	assert.Equal(t, 2, strings.Count(html, "<article "))
+	assert.NotContains(t, html, "src=")
+}
+
+func TestHtmlExporterAppend(t *testing.T) {
+	e := &HtmlExporter{Name: filepath.Join(t.TempDir(), "out.html"), Mode: OutputAppend}
+	assert.EqualError(t, e.Open(), "html output cannot be appended to")
+}
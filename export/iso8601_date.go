@@ -15,11 +15,20 @@
 package export
 
 import (
+	"encoding/json"
 	"time"
 )
 
 type Iso8601Date time.Time
 
 func (t *Iso8601Date) String() string {
-	return time.Time(*t).Format("2006-01-02T15:04:05-07:00")
+	return time.Time(*t).Format(Iso8601Layout)
+}
+
+func (t Iso8601Date) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t Iso8601Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
 }
@@ -18,6 +18,7 @@
 package export
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -30,3 +31,17 @@ func TestString(t *testing.T) {
 	actual := d.String()
 	assert.Equal(t, expected, actual)
 }
+
+func TestMarshalJSON(t *testing.T) {
+	v := struct {
+		CreatedAt Iso8601Date `json:"created_at"`
+	}{Iso8601Date(time.Date(2013, 8, 19, 2, 4, 28, 0, time.UTC))}
+
+	actual, err := json.Marshal(v)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"created_at":"2013-08-19T02:04:28+00:00"}`, string(actual))
+
+	text, err := v.CreatedAt.MarshalText()
+	assert.NoError(t, err)
+	assert.Equal(t, "2013-08-19T02:04:28+00:00", string(text))
+}
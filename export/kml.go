@@ -0,0 +1,109 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type kmlData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+type kmlPoint struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+type kmlPolygon struct {
+	Coordinates string `xml:"outerBoundaryIs>LinearRing>coordinates"`
+}
+
+type kmlPlacemark struct {
+	XMLName     xml.Name    `xml:"Placemark"`
+	Name        string      `xml:"name"`
+	Description string      `xml:"description"`
+	When        string      `xml:"TimeStamp>when,omitempty"`
+	Data        []kmlData   `xml:"ExtendedData>Data"`
+	Point       *kmlPoint   `xml:"Point,omitempty"`
+	Polygon     *kmlPolygon `xml:"Polygon,omitempty"`
+}
+
+// kmlWriter writes a KML document, one placemark per line.
+type kmlWriter struct {
+	out  io.Writer
+	time TimeFormat
+}
+
+func newKmlWriter(out io.Writer, f TimeFormat) *kmlWriter {
+	return &kmlWriter{out: out, time: f}
+}
+
+func (w *kmlWriter) Begin() error {
+	_, err := io.WriteString(w.out, xml.Header+`<kml xmlns="http://www.opengis.net/kml/2.2"><Document>`+"\n")
+	return err
+}
+
+func (w *kmlWriter) WriteFeature(r *Record, g Geometry) error {
+	p := newGeoProperties(r, w.time)
+	m := kmlPlacemark{
+		Name:        "@" + p.Username,
+		Description: p.FullText,
+		Data: []kmlData{
+			{Name: "id", Value: p.Id},
+			{Name: "username", Value: p.Username},
+			{Name: "created_at", Value: p.CreatedAt},
+			{Name: "retweet_count", Value: strconv.FormatUint(p.RetweetCount, 10)},
+			{Name: "favorite_count", Value: strconv.FormatUint(p.FavoriteCount, 10)},
+			{Name: "reply_count", Value: strconv.FormatUint(p.ReplyCount, 10)},
+			{Name: "quote_count", Value: strconv.FormatUint(p.QuoteCount, 10)},
+			{Name: "lang", Value: p.Lang},
+			{Name: "source", Value: p.Source},
+			{Name: "place", Value: p.Place},
+		},
+	}
+
+	// KML time stamps are always xsd:dateTime, whatever the time format.
+	if t := time.Time(r.CreatedAt); !t.IsZero() {
+		m.When = w.time.In(t).Format(time.RFC3339)
+	}
+
+	if g.Point != nil {
+		m.Point = &kmlPoint{Coordinates: g.Point.String()}
+	} else {
+		coordinates := make([]string, len(g.Polygon))
+		for i, c := range g.Polygon {
+			coordinates[i] = c.String()
+		}
+		m.Polygon = &kmlPolygon{Coordinates: strings.Join(coordinates, " ")}
+	}
+
+	buf, err := xml.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.out.Write(append(buf, '\n'))
+	return err
+}
+
+func (w *kmlWriter) End() error {
+	_, err := io.WriteString(w.out, "</Document></kml>\n")
+	return err
+}
@@ -0,0 +1,62 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build small
+// +build small
+
+package export
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/akiomik/squawks/api/json"
+)
+
+func TestKmlWriter(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	r := Record{
+		Id:        1,
+		Username:  "watson",
+		CreatedAt: Iso8601Date(time.Date(2020, 9, 6, 0, 1, 2, 0, time.UTC)),
+		FullText:  "a & b",
+		Tweet:     &json.Tweet{Place: &json.Place{FullName: "Tokyo, Japan"}},
+	}
+
+	buf := new(bytes.Buffer)
+	w := newKmlWriter(buf, TimeFormat{Layout: TimeFormatUnix, Location: tokyo})
+	assert.NoError(t, w.Begin())
+	assert.NoError(t, w.WriteFeature(&r, Geometry{Point: &json.LongLat{139.5, 35.5}}))
+	assert.NoError(t, w.WriteFeature(&r, Geometry{Polygon: []json.LongLat{{139, 35}, {140, 35}, {140, 36}, {139, 35}}}))
+	assert.NoError(t, w.End())
+
+	data := `<ExtendedData><Data name="id"><value>1</value></Data><Data name="username"><value>watson</value></Data><Data name="created_at"><value>1599350462</value></Data>` +
+		`<Data name="retweet_count"><value>0</value></Data><Data name="favorite_count"><value>0</value></Data><Data name="reply_count"><value>0</value></Data><Data name="quote_count"><value>0</value></Data>` +
+		`<Data name="lang"><value></value></Data><Data name="source"><value></value></Data><Data name="place"><value>Tokyo, Japan</value></Data></ExtendedData>`
+	expected := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		`<kml xmlns="http://www.opengis.net/kml/2.2"><Document>` + "\n" +
+		`<Placemark><name>@watson</name><description>a &amp; b</description><TimeStamp><when>2020-09-06T09:01:02+09:00</when></TimeStamp>` + data +
+		`<Point><coordinates>139.5,35.5</coordinates></Point></Placemark>` + "\n" +
+		`<Placemark><name>@watson</name><description>a &amp; b</description><TimeStamp><when>2020-09-06T09:01:02+09:00</when></TimeStamp>` + data +
+		`<Polygon><outerBoundaryIs><LinearRing><coordinates>139,35 140,35 140,36 139,35</coordinates></LinearRing></outerBoundaryIs></Polygon></Placemark>` + "\n" +
+		`</Document></kml>` + "\n"
+	assert.Equal(t, expected, buf.String())
+}
@@ -0,0 +1,229 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+)
+
+// Stdout is the output name that writes to the standard output.
+const Stdout = "-"
+
+func IsStdout(name string) bool {
+	return len(name) == 0 || name == Stdout
+}
+
+// Name returns the file name of w, or an empty string if w is not a file.
+func Name(w interface{}) string {
+	if f, ok := w.(interface{ Name() string }); ok {
+		return f.Name()
+	}
+
+	return ""
+}
+
+type OutputMode string
+
+const (
+	// OutputCreate fails if the output file already exists.
+	OutputCreate    OutputMode = "create"
+	OutputOverwrite OutputMode = "overwrite"
+	OutputAppend    OutputMode = "append"
+)
+
+// Create opens an output file in the given mode, or returns the standard output for
+// an empty name or "-". A file opened in OutputAppend mode can also be read from
+// the beginning. Closing the returned standard output is a no-op.
+func Create(name string, mode OutputMode) (*os.File, func() error, error) {
+	if IsStdout(name) {
+		return os.Stdout, func() error { return nil }, nil
+	}
+
+	var flag int
+	switch mode {
+	case "", OutputCreate:
+		flag = os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	case OutputOverwrite:
+		flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	case OutputAppend:
+		flag = os.O_RDWR | os.O_CREATE | os.O_APPEND
+	default:
+		return nil, nil, fmt.Errorf("unknown output mode: %s", mode)
+	}
+
+	f, err := os.OpenFile(name, flag, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return f, f.Close, nil
+}
+
+// IsBrokenPipe reports whether err is caused by the reader of a pipe exiting early.
+func IsBrokenPipe(err error) bool {
+	return errors.Is(err, syscall.EPIPE)
+}
+
+// Exporter writes pages of records received from a channel to an output.
+type Exporter interface {
+	// Export writes records until the channel is closed and sends the
+	// result once finished.
+	Export(ch <-chan []Record) <-chan error
+	Close() error
+}
+
+// Output is an opened output file, compressed if requested.
+type Output struct {
+	io.Writer
+
+	name    string
+	size    *countingWriter
+	closers []func() error
+}
+
+func (o *Output) Name() string {
+	return o.name
+}
+
+// Size returns the size of the output file in bytes, including any existing content
+// when appending.
+func (o *Output) Size() uint64 {
+	return o.size.n
+}
+
+// Flush ends the current compressed frame, if any, so that everything written
+// so far can be read back.
+func (o *Output) Flush() error {
+	if f, ok := o.Writer.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+
+	return nil
+}
+
+// Close ends the compressed stream, if any, and closes the file. Calling Close
+// more than once is a no-op.
+func (o *Output) Close() error {
+	var err error
+	for i := len(o.closers) - 1; i >= 0; i-- {
+		if cerr := o.closers[i](); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	o.closers = nil
+
+	return err
+}
+
+// Open opens an output file as Create does and compresses what is written to it.
+func Open(name string, mode OutputMode, c Compression) (*Output, error) {
+	return open(name, mode, c, nil)
+}
+
+// open opens an output, calling prepare with the file before anything is written.
+func open(name string, mode OutputMode, c Compression, prepare func(f *os.File) error) (*Output, error) {
+	f, closeFile, err := Create(name, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &Output{Writer: f, name: f.Name(), size: &countingWriter{w: f}, closers: []func() error{closeFile}}
+
+	if prepare != nil {
+		if err := prepare(f); err != nil {
+			o.Close()
+			return nil, err
+		}
+	}
+
+	if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+		o.size.n = uint64(info.Size())
+	}
+
+	w, err := NewCompressWriter(o.size, c)
+	if err != nil {
+		o.Close()
+		return nil, err
+	}
+
+	if cw, ok := w.(io.Closer); ok {
+		o.closers = append(o.closers, cw.Close)
+	}
+	o.Writer = w
+
+	return o, nil
+}
+
+// CsvOutput is an opened output of a csv export.
+type CsvOutput struct {
+	*Output
+	Exporter *CsvExporter
+}
+
+// OpenCsv opens the output of a csv export written with the options of e. When
+// appending to an existing csv, its columns are checked and, if ids is not nil, its
+// tweet ids are added to ids.
+func OpenCsv(name string, mode OutputMode, c Compression, e CsvExporter, ids IdSet) (*CsvOutput, error) {
+	o := &CsvOutput{Exporter: &e}
+
+	var prepare func(f *os.File) error
+	if mode == OutputAppend && !IsStdout(name) {
+		prepare = func(f *os.File) error {
+			if err := prepareAppend(f, c, o.Exporter, ids); err != nil {
+				return fmt.Errorf("cannot append to %s: %w", name, err)
+			}
+
+			return nil
+		}
+	}
+
+	out, err := open(name, mode, c, prepare)
+	if err != nil {
+		return nil, err
+	}
+	o.Output = out
+
+	return o, nil
+}
+
+func prepareAppend(f *os.File, c Compression, e *CsvExporter, ids IdSet) error {
+	r, err := NewDecompressReader(f, c)
+	if err == io.EOF {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	_, err = e.PrepareAppend(r, ids)
+	return err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n uint64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += uint64(n)
+	return n, err
+}
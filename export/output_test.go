@@ -0,0 +1,100 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build medium
+// +build medium
+
+package export
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreate(t *testing.T) {
+	examples := map[string]struct {
+		mode     OutputMode
+		expected string
+		hasError bool
+	}{
+		"create":    {mode: OutputCreate, expected: "foo\n", hasError: true},
+		"overwrite": {mode: OutputOverwrite, expected: "bar\n", hasError: false},
+		"append":    {mode: OutputAppend, expected: "foo\nbar\n", hasError: false},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "out.csv")
+			err := os.WriteFile(path, []byte("foo\n"), 0644)
+			assert.NoError(t, err)
+
+			f, closeFile, err := Create(path, e.mode)
+			if e.hasError {
+				assert.ErrorIs(t, err, os.ErrExist)
+			} else {
+				assert.NoError(t, err)
+				_, err = f.WriteString("bar\n")
+				assert.NoError(t, err)
+				assert.NoError(t, closeFile())
+			}
+
+			actual, err := os.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, e.expected, string(actual))
+		})
+	}
+}
+
+func TestCreateStdout(t *testing.T) {
+	for _, name := range []string{"", "-"} {
+		f, closeFile, err := Create(name, OutputAppend)
+		assert.NoError(t, err)
+		assert.Equal(t, os.Stdout, f)
+		assert.NoError(t, closeFile())
+	}
+}
+
+func TestOutputFlush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.csv.gz")
+	o, err := Open(path, OutputCreate, CompressionGzip)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer o.Close()
+
+	_, err = o.Write([]byte("foo\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, o.Flush())
+
+	f, err := os.Open(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer f.Close()
+
+	r, err := NewDecompressReader(f, CompressionGzip)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer r.Close()
+
+	actual, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "foo\n", string(actual))
+	assert.NotZero(t, o.Size())
+}
@@ -0,0 +1,218 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+
+	"github.com/akiomik/squawks/logging"
+)
+
+// DefaultRowGroupSize is the default size of parquet row groups in bytes.
+const DefaultRowGroupSize = 128 * 1024 * 1024
+
+// ParquetRow is the parquet schema of a Record.
+type ParquetRow struct {
+	Id            int64    `parquet:"name=id, type=INT64"`
+	UserId        *int64   `parquet:"name=user_id, type=INT64, repetitiontype=OPTIONAL"`
+	Username      string   `parquet:"name=username, type=BYTE_ARRAY, convertedtype=UTF8"`
+	CreatedAt     *int64   `parquet:"name=created_at, type=INT64, convertedtype=TIMESTAMP_MILLIS, repetitiontype=OPTIONAL"`
+	FullText      string   `parquet:"name=full_text, type=BYTE_ARRAY, convertedtype=UTF8"`
+	RetweetCount  int64    `parquet:"name=retweet_count, type=INT64"`
+	FavoriteCount int64    `parquet:"name=favorite_count, type=INT64"`
+	ReplyCount    int64    `parquet:"name=reply_count, type=INT64"`
+	QuoteCount    int64    `parquet:"name=quote_count, type=INT64"`
+	Latitude      *float64 `parquet:"name=latitude, type=DOUBLE, repetitiontype=OPTIONAL"`
+	Longitude     *float64 `parquet:"name=longitude, type=DOUBLE, repetitiontype=OPTIONAL"`
+	Lang          string   `parquet:"name=lang, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Source        string   `parquet:"name=source, type=BYTE_ARRAY, convertedtype=UTF8"`
+	UserCreatedAt *int64   `parquet:"name=user_created_at, type=INT64, convertedtype=TIMESTAMP_MILLIS, repetitiontype=OPTIONAL"`
+	Hashtags      []string `parquet:"name=hashtags, type=LIST, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+	Mentions      []string `parquet:"name=mentions, type=LIST, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+	Urls          []string `parquet:"name=urls, type=LIST, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+}
+
+// NewParquetRow returns r as a parquet row. Hashtags, mentions (screen names)
+// and urls (expanded) are taken from the source tweet of r.
+func NewParquetRow(r *Record) ParquetRow {
+	row := ParquetRow{
+		Id:            int64(r.Id),
+		Username:      r.Username,
+		CreatedAt:     parquetTime(time.Time(r.CreatedAt)),
+		FullText:      r.FullText,
+		RetweetCount:  int64(r.RetweetCount),
+		FavoriteCount: int64(r.FavoriteCount),
+		ReplyCount:    int64(r.ReplyCount),
+		QuoteCount:    int64(r.QuoteCount),
+		Latitude:      r.Latitude,
+		Longitude:     r.Longitude,
+		Lang:          r.Lang,
+		Source:        r.Source,
+		UserCreatedAt: parquetTime(time.Time(r.UserCreatedAt)),
+		Hashtags:      []string{},
+		Mentions:      []string{},
+		Urls:          []string{},
+	}
+
+	if t := r.Tweet; t != nil {
+		userId := int64(t.UserId)
+		row.UserId = &userId
+
+		for _, h := range t.Entities.Hashtags {
+			row.Hashtags = append(row.Hashtags, h.Text)
+		}
+
+		for _, m := range t.Entities.UserMentions {
+			row.Mentions = append(row.Mentions, m.ScreenName)
+		}
+
+		for _, u := range t.Entities.Urls {
+			row.Urls = append(row.Urls, u.ExpandedUrl)
+		}
+	}
+
+	return row
+}
+
+func parquetTime(t time.Time) *int64 {
+	if t.IsZero() {
+		return nil
+	}
+
+	ms := t.UnixMilli()
+	return &ms
+}
+
+// ParquetExporter writes records to a parquet file, buffering them into row
+// groups of about RowGroupSize bytes.
+type ParquetExporter struct {
+	Name string
+	// Mode is OutputCreate or OutputOverwrite. Parquet files cannot be appended to.
+	Mode OutputMode
+	// Compression is the codec of column chunks, or snappy if empty.
+	Compression  Compression
+	RowGroupSize uint64
+	// Logger defaults to logging.Default().
+	Logger logging.Logger
+
+	w         *writer.ParquetWriter
+	closeFile func() error
+}
+
+// Open creates the file and writes the parquet header.
+func (e *ParquetExporter) Open() error {
+	if e.Mode == OutputAppend {
+		return fmt.Errorf("parquet output cannot be appended to")
+	}
+
+	codec, err := parquetCodec(e.Compression)
+	if err != nil {
+		return err
+	}
+
+	f, closeFile, err := Create(e.Name, e.Mode)
+	if err != nil {
+		return err
+	}
+
+	// Pages of tweets are small, so rows are marshalled in one goroutine.
+	w, err := writer.NewParquetWriterFromWriter(f, new(ParquetRow), 1)
+	if err != nil {
+		closeFile()
+		return err
+	}
+
+	w.CompressionType = codec
+	w.RowGroupSize = DefaultRowGroupSize
+	if e.RowGroupSize != 0 {
+		w.RowGroupSize = int64(e.RowGroupSize)
+	}
+
+	e.w = w
+	e.closeFile = closeFile
+	orDefault(e.Logger).Info("opened output", "file", e.Name, "mode", e.Mode)
+	return nil
+}
+
+func parquetCodec(c Compression) (parquet.CompressionCodec, error) {
+	switch c {
+	case "":
+		return parquet.CompressionCodec_SNAPPY, nil
+	case CompressionNone:
+		return parquet.CompressionCodec_UNCOMPRESSED, nil
+	case CompressionGzip:
+		return parquet.CompressionCodec_GZIP, nil
+	case CompressionZstd:
+		return parquet.CompressionCodec_ZSTD, nil
+	default:
+		return parquet.CompressionCodec_UNCOMPRESSED, fmt.Errorf("unknown compression: %s", c)
+	}
+}
+
+// Close writes the last row group and the footer, and closes the file.
+// Calling Close more than once is a no-op.
+func (e *ParquetExporter) Close() error {
+	if e.w == nil {
+		return nil
+	}
+
+	err := e.w.WriteStop()
+	if cerr := e.closeFile(); err == nil {
+		err = cerr
+	}
+
+	e.w = nil
+	e.closeFile = nil
+	return err
+}
+
+// Export writes pages of records until ch is closed and closes the file.
+func (e *ParquetExporter) Export(ch <-chan []Record) <-chan error {
+	return runExport(orDefault(e.Logger), "parquet", ch, func() error {
+		var err error
+		for records := range ch {
+			if err = e.WritePage(records); err != nil {
+				break
+			}
+		}
+
+		if cerr := e.Close(); err == nil {
+			err = cerr
+		}
+
+		return err
+	})
+}
+
+// WritePage buffers records, writing a row group once it is full.
+func (e *ParquetExporter) WritePage(records []Record) error {
+	if e.w == nil {
+		if err := e.Open(); err != nil {
+			return err
+		}
+	}
+
+	for i := range records {
+		if err := e.w.Write(NewParquetRow(&records[i])); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
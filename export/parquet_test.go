@@ -0,0 +1,143 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build medium
+// +build medium
+
+package export
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/reader"
+
+	"github.com/akiomik/squawks/api/json"
+)
+
+func TestParquetExporter(t *testing.T) {
+	latitude := 35.6851508
+	longitude := 139.7526768
+	tweet := json.Tweet{
+		Id:     1,
+		UserId: 10,
+		Entities: json.Entities{
+			Hashtags:     []json.Hashtag{{Text: "baker"}, {Text: "street"}},
+			UserMentions: []json.UserMention{{ScreenName: "holmes"}},
+			Urls:         []json.Url{{Url: "https://t.co/x", ExpandedUrl: "https://example.com"}},
+		},
+	}
+	createdAt := time.Date(2020, 9, 6, 0, 1, 2, 345000000, time.UTC)
+	pages := [][]Record{
+		{{Id: 1, Username: "watson", CreatedAt: Iso8601Date(createdAt), FullText: "a", RetweetCount: 3, Latitude: &latitude, Longitude: &longitude, Lang: "en", Tweet: &tweet}},
+		{{Id: 2, FullText: "b"}},
+	}
+
+	userId := int64(10)
+	createdAtMs := createdAt.UnixMilli()
+	expected := []ParquetRow{
+		{Id: 1, UserId: &userId, Username: "watson", CreatedAt: &createdAtMs, FullText: "a", RetweetCount: 3, Latitude: &latitude, Longitude: &longitude, Lang: "en", Hashtags: []string{"baker", "street"}, Mentions: []string{"holmes"}, Urls: []string{"https://example.com"}},
+		{Id: 2, FullText: "b", Hashtags: []string{}, Mentions: []string{}, Urls: []string{}},
+	}
+
+	examples := map[string]struct {
+		compression Compression
+	}{
+		"snappy": {compression: ""},
+		"none":   {compression: CompressionNone},
+		"gzip":   {compression: CompressionGzip},
+		"zstd":   {compression: CompressionZstd},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "out.parquet")
+			exporter := &ParquetExporter{Name: out, Compression: e.compression}
+			if !assert.NoError(t, exporter.Open()) {
+				return
+			}
+
+			ch := make(chan []Record, len(pages))
+			for _, p := range pages {
+				ch <- p
+			}
+			close(ch)
+			assert.NoError(t, <-exporter.Export(ch))
+
+			f, err := local.NewLocalFileReader(out)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer f.Close()
+
+			r, err := reader.NewParquetReader(f, new(ParquetRow), 1)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer r.ReadStop()
+
+			actual := make([]ParquetRow, r.GetNumRows())
+			assert.NoError(t, r.Read(&actual))
+			assert.Equal(t, expected, actual)
+		})
+	}
+}
+
+func TestParquetExporterRowGroupSize(t *testing.T) {
+	examples := map[string]struct {
+		rowGroupSize      uint64
+		expectedRowGroups int
+	}{
+		"default": {rowGroupSize: 0, expectedRowGroups: 1},
+		"small":   {rowGroupSize: 64 * 1024, expectedRowGroups: 4},
+	}
+
+	records := make([]Record, 1000)
+	for i := range records {
+		records[i] = Record{Id: uint64(i), FullText: strings.Repeat("a", 1000)}
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "out.parquet")
+			exporter := &ParquetExporter{Name: out, Compression: CompressionNone, RowGroupSize: e.rowGroupSize}
+			assert.NoError(t, exporter.WritePage(records))
+			assert.NoError(t, exporter.Close())
+
+			f, err := local.NewLocalFileReader(out)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer f.Close()
+
+			r, err := reader.NewParquetReader(f, new(ParquetRow), 1)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer r.ReadStop()
+
+			assert.Equal(t, int64(len(records)), r.GetNumRows())
+			assert.GreaterOrEqual(t, len(r.Footer.RowGroups), e.expectedRowGroups)
+		})
+	}
+}
+
+func TestParquetExporterAppend(t *testing.T) {
+	e := &ParquetExporter{Name: filepath.Join(t.TempDir(), "out.parquet"), Mode: OutputAppend}
+	assert.EqualError(t, e.Open(), "parquet output cannot be appended to")
+}
@@ -36,6 +36,24 @@ type Record struct {
 	Longitude     *float64
 	Lang          string
 	Source        string
+	UserCreatedAt Iso8601Date
+	PlaceId       string
+	PlaceName     string
+	PlaceFullName string
+	PlaceType     string
+	CountryCode   string
+	// PlaceLatitude and PlaceLongitude are the centroid of the place of a tweet
+	// without exact coordinates, set by SetPlaceCentroid.
+	PlaceLatitude  *float64
+	PlaceLongitude *float64
+	// GeoSource is the most precise location of the tweet: coordinates, geo,
+	// place or empty.
+	GeoSource string
+
+	// Tweet and User are the source objects of the record, used by exporters
+	// that keep more than the flat columns. They are nil if unknown.
+	Tweet *json.Tweet
+	User  *json.User
 }
 
 func ReverseSortedTweetIds(j *json.Adaptive) []string {
@@ -62,15 +80,35 @@ func ReverseSortedTweetIds(j *json.Adaptive) []string {
 func NewRecordsFromAdaptive(j *json.Adaptive) []Record {
 	return Map(ReverseSortedTweetIds(j), func(id string) Record {
 		t := j.GlobalObjects.Tweets[id]
-		u := j.GlobalObjects.Users[strconv.FormatUint(t.UserId, 10)]
+		u, ok := j.GlobalObjects.Users[strconv.FormatUint(t.UserId, 10)]
+
+		var user *json.User
+		if ok {
+			user = &u
+		}
 
 		var latitude *float64
 		var longitude *float64
+		var geoSource string
 		if t.Coordinates != nil {
 			lat := t.Coordinates.Coordinates.Latitude()
 			long := t.Coordinates.Coordinates.Longitude()
 			latitude = &lat
 			longitude = &long
+			geoSource = "coordinates"
+		} else if t.Geo != nil {
+			lat := t.Geo.Coordinates.Latitude()
+			long := t.Geo.Coordinates.Longitude()
+			latitude = &lat
+			longitude = &long
+			geoSource = "geo"
+		} else if t.Place != nil {
+			geoSource = "place"
+		}
+
+		var place json.Place
+		if t.Place != nil {
+			place = *t.Place
 		}
 
 		return Record{
@@ -86,6 +124,30 @@ func NewRecordsFromAdaptive(j *json.Adaptive) []Record {
 			Longitude:     longitude,
 			Lang:          t.Lang,
 			Source:        t.Source,
+			UserCreatedAt: Iso8601Date(time.Time(u.CreatedAt)),
+			PlaceId:       place.Id,
+			PlaceName:     place.Name,
+			PlaceFullName: place.FullName,
+			PlaceType:     place.PlaceType,
+			CountryCode:   place.CountryCode,
+			GeoSource:     geoSource,
+			Tweet:         &t,
+			User:          user,
 		}
 	})
 }
+
+// SetPlaceCentroid sets the place coordinates of r to the centroid of the
+// bounding box of its place if r has no exact coordinates.
+func (r *Record) SetPlaceCentroid() {
+	if r.Latitude != nil || r.Tweet == nil || r.Tweet.Place == nil {
+		return
+	}
+
+	if c, ok := r.Tweet.Place.BoundingBox.Centroid(); ok {
+		lat := c.Latitude()
+		long := c.Longitude()
+		r.PlaceLatitude = &lat
+		r.PlaceLongitude = &long
+	}
+}
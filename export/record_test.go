@@ -134,7 +134,14 @@ func TestNewRecordsFromAdaptive(t *testing.T) {
 					FavoriteCount: 4000,
 					ReplyCount:    5000,
 					QuoteCount:    6000,
-					Lang:          "en",
+					Place: &json.Place{
+						Id:          "01a9a39529b27f36",
+						PlaceType:   "city",
+						Name:        "Manhattan",
+						FullName:    "Manhattan, NY",
+						CountryCode: "US",
+					},
+					Lang: "en",
 				},
 				"100": json.Tweet{
 					Id:            100,
@@ -207,6 +214,8 @@ func TestNewRecordsFromAdaptive(t *testing.T) {
 
 	latitude := 40.74118764
 	longitude := -73.9998279
+	tweet1000, user2000 := j.GlobalObjects.Tweets["1000"], j.GlobalObjects.Users["2000"]
+	tweet100, user200 := j.GlobalObjects.Tweets["100"], j.GlobalObjects.Users["200"]
 	expected := []Record{
 		Record{
 			Id:            1000,
@@ -220,6 +229,14 @@ func TestNewRecordsFromAdaptive(t *testing.T) {
 			Latitude:      nil,
 			Longitude:     nil,
 			Lang:          "en",
+			PlaceId:       "01a9a39529b27f36",
+			PlaceName:     "Manhattan",
+			PlaceFullName: "Manhattan, NY",
+			PlaceType:     "city",
+			CountryCode:   "US",
+			GeoSource:     "place",
+			Tweet:         &tweet1000,
+			User:          &user2000,
 		},
 		Record{
 			Id:            100,
@@ -233,9 +250,58 @@ func TestNewRecordsFromAdaptive(t *testing.T) {
 			Latitude:      &latitude,
 			Longitude:     &longitude,
 			Lang:          "en",
+			GeoSource:     "coordinates",
+			Tweet:         &tweet100,
+			User:          &user200,
 		},
 	}
 
 	actual := NewRecordsFromAdaptive(&j)
 	assert.Equal(t, expected, actual)
 }
+
+func TestRecordSetPlaceCentroid(t *testing.T) {
+	latitude := 40.74118764
+	longitude := -73.9998279
+	place := &json.Place{
+		BoundingBox: json.BoundingBox{
+			Type:        "Polygon",
+			Coordinates: [][]json.LongLat{{{-74, 40}, {-73, 40}, {-73, 41}, {-74, 41}}},
+		},
+	}
+
+	examples := map[string]struct {
+		record            Record
+		expectedLatitude  *float64
+		expectedLongitude *float64
+	}{
+		"place": {
+			record:            Record{Tweet: &json.Tweet{Place: place}},
+			expectedLatitude:  func(f float64) *float64 { return &f }(40.5),
+			expectedLongitude: func(f float64) *float64 { return &f }(-73.5),
+		},
+		"coordinates": {
+			record:            Record{Latitude: &latitude, Longitude: &longitude, Tweet: &json.Tweet{Place: place}},
+			expectedLatitude:  nil,
+			expectedLongitude: nil,
+		},
+		"no-place": {
+			record:            Record{Tweet: &json.Tweet{}},
+			expectedLatitude:  nil,
+			expectedLongitude: nil,
+		},
+		"no-tweet": {
+			record:            Record{},
+			expectedLatitude:  nil,
+			expectedLongitude: nil,
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			e.record.SetPlaceCentroid()
+			assert.Equal(t, e.expectedLatitude, e.record.PlaceLatitude)
+			assert.Equal(t, e.expectedLongitude, e.record.PlaceLongitude)
+		})
+	}
+}
@@ -0,0 +1,319 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akiomik/squawks/logging"
+)
+
+type Partition string
+
+const (
+	PartitionNone  Partition = "none"
+	PartitionHour  Partition = "hour"
+	PartitionDay   Partition = "day"
+	PartitionMonth Partition = "month"
+	PartitionYear  Partition = "year"
+)
+
+func ParsePartition(s string) (Partition, error) {
+	switch p := Partition(s); p {
+	case "", PartitionNone:
+		return PartitionNone, nil
+	case PartitionHour, PartitionDay, PartitionMonth, PartitionYear:
+		return p, nil
+	default:
+		return PartitionNone, fmt.Errorf("unknown partition: %s", s)
+	}
+}
+
+// Key returns the partition of a tweet created at t, in the location of t.
+func (p Partition) Key(t time.Time) string {
+	switch p {
+	case PartitionHour:
+		return t.Format("2006-01-02T15")
+	case PartitionDay:
+		return t.Format("2006-01-02")
+	case PartitionMonth:
+		return t.Format("2006-01")
+	case PartitionYear:
+		return t.Format("2006")
+	default:
+		return ""
+	}
+}
+
+// Rotation splits an export across several files. Zero values disable each limit.
+type Rotation struct {
+	MaxBytes    uint64
+	MaxRecords  uint64
+	PartitionBy Partition
+}
+
+func (r Rotation) IsZero() bool {
+	return r.MaxBytes == 0 && r.MaxRecords == 0 && !r.partitioned()
+}
+
+func (r Rotation) partitioned() bool {
+	return len(r.PartitionBy) != 0 && r.PartitionBy != PartitionNone
+}
+
+// FileName returns the name of the nth file of a partition, e.g. out-2022-05-01.csv
+// for a daily partition or out-0002.csv for the second file rotated by size.
+// The name is returned as is when rotation is disabled.
+func (r Rotation) FileName(name string, key string, n int) string {
+	if r.IsZero() {
+		return name
+	}
+
+	dir, base := filepath.Split(name)
+	ext := filepath.Ext(base)
+	if CompressionForName(base) != CompressionNone {
+		ext = filepath.Ext(strings.TrimSuffix(base, ext)) + ext
+	}
+
+	stem := strings.TrimSuffix(base, ext)
+	if len(key) != 0 {
+		stem += "-" + key
+	}
+
+	if r.MaxBytes > 0 || r.MaxRecords > 0 {
+		stem += fmt.Sprintf("-%04d", n)
+	}
+
+	return dir + stem + ext
+}
+
+var sizeUnits = map[string]uint64{
+	"":    1,
+	"b":   1,
+	"kb":  1000,
+	"mb":  1000 * 1000,
+	"gb":  1000 * 1000 * 1000,
+	"tb":  1000 * 1000 * 1000 * 1000,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+// ParseSize parses a size in bytes with an optional unit (e.g. 500MB, 1GiB).
+func ParseSize(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+	if i < 0 {
+		i = len(s)
+	}
+
+	unit, ok := sizeUnits[strings.ToLower(strings.TrimSpace(s[i:]))]
+	if i == 0 || !ok {
+		return 0, fmt.Errorf("invalid size: %s", s)
+	}
+
+	n, err := strconv.ParseUint(s[:i], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size: %s", s)
+	}
+
+	return n * unit, nil
+}
+
+type partition struct {
+	n       int
+	records uint64
+}
+
+// CsvFileExporter writes records to csv files, optionally rotated by size, number
+// of records or tweet date. Each file has its own header. A partition that was
+// already written by this exporter is appended to when its tweets show up again.
+type CsvFileExporter struct {
+	Rotation
+	Name        string
+	Mode        OutputMode
+	Compression Compression
+	// Csv holds the csv options of every file.
+	Csv CsvExporter
+	// Ids receives the tweet ids of the files appended to.
+	Ids IdSet
+	// Logger defaults to logging.Default().
+	Logger logging.Logger
+
+	out        *CsvOutput
+	w          *csvWriter
+	key        string
+	partitions map[string]*partition
+}
+
+// Open opens the first file so that errors are reported before any record is
+// exported. Files of date partitions are opened when their first tweet arrives.
+func (e *CsvFileExporter) Open() error {
+	if e.partitioned() {
+		return nil
+	}
+
+	return e.use("")
+}
+
+// Close closes the current file. Calling Close more than once is a no-op.
+func (e *CsvFileExporter) Close() error {
+	if e.out == nil {
+		return nil
+	}
+
+	err := e.out.Close()
+	e.out = nil
+	e.w = nil
+	return err
+}
+
+// Export writes records as described by CsvExporter.Export and closes the last file.
+func (e *CsvFileExporter) Export(ch <-chan []Record) <-chan error {
+	return runExport(orDefault(e.Logger), "csv", ch, func() error {
+		err := e.export(ch)
+		if cerr := e.Close(); err == nil {
+			err = cerr
+		}
+
+		return err
+	})
+}
+
+func (e *CsvFileExporter) export(ch <-chan []Record) error {
+	logger := orDefault(e.Logger)
+	logger.Info("export started", "format", "csv", "file", e.Name, "rotation", !e.IsZero())
+
+	pages := 0
+	total := 0
+	for records := range ch {
+		if err := e.writePage(records); err != nil {
+			return err
+		}
+
+		pages++
+		total += len(records)
+		logger.Debug("exported page", "page", pages, "records", len(records), "total", total)
+	}
+
+	logger.Info("export finished", "format", "csv", "file", e.Name, "pages", pages, "records", total)
+
+	return nil
+}
+
+func (e *CsvFileExporter) writePage(records []Record) error {
+	if len(records) == 0 && !e.partitioned() {
+		if err := e.use(e.key); err != nil {
+			return err
+		}
+
+		return e.w.WritePage(records)
+	}
+
+	for len(records) > 0 {
+		key := e.partitionKey(records[0])
+		n := 1
+		for n < len(records) && e.partitionKey(records[n]) == key {
+			n++
+		}
+
+		if err := e.use(key); err != nil {
+			return err
+		}
+
+		p := e.partitions[key]
+		if e.full(p) {
+			if err := e.rotate(key); err != nil {
+				return err
+			}
+		}
+
+		if e.MaxRecords > 0 && uint64(n) > e.MaxRecords-p.records {
+			n = int(e.MaxRecords - p.records)
+		}
+
+		if err := e.w.WritePage(records[:n]); err != nil {
+			return err
+		}
+
+		p.records += uint64(n)
+		records = records[n:]
+	}
+
+	return nil
+}
+
+// partitionKey returns the partition of r in the time zone of the exported timestamps.
+func (e *CsvFileExporter) partitionKey(r Record) string {
+	return e.PartitionBy.Key(e.Csv.TimeFormat.In(time.Time(r.CreatedAt)))
+}
+
+func (e *CsvFileExporter) full(p *partition) bool {
+	return (e.MaxRecords > 0 && p.records >= e.MaxRecords) || (e.MaxBytes > 0 && e.out.Size() >= e.MaxBytes)
+}
+
+// use makes the file of the partition key current.
+func (e *CsvFileExporter) use(key string) error {
+	if e.out != nil && e.key == key {
+		return nil
+	}
+
+	if e.partitions == nil {
+		e.partitions = map[string]*partition{}
+	}
+
+	mode := e.Mode
+	p, ok := e.partitions[key]
+	if ok {
+		mode = OutputAppend
+	} else {
+		p = &partition{n: 1}
+		e.partitions[key] = p
+	}
+
+	return e.open(key, p, mode)
+}
+
+// rotate starts the next file of the partition key.
+func (e *CsvFileExporter) rotate(key string) error {
+	p := e.partitions[key]
+	p.n++
+	p.records = 0
+
+	return e.open(key, p, e.Mode)
+}
+
+func (e *CsvFileExporter) open(key string, p *partition, mode OutputMode) error {
+	if err := e.Close(); err != nil {
+		return err
+	}
+
+	name := e.FileName(e.Name, key, p.n)
+	out, err := OpenCsv(name, mode, e.Compression, e.Csv, e.Ids)
+	if err != nil {
+		return err
+	}
+
+	orDefault(e.Logger).Info("opened output", "file", name, "mode", mode)
+
+	e.out = out
+	e.w = out.Exporter.newWriter(out)
+	e.key = key
+	return nil
+}
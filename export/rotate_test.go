@@ -0,0 +1,192 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build medium
+// +build medium
+
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartitionKey(t *testing.T) {
+	createdAt := time.Date(2022, 5, 1, 23, 4, 5, 0, time.FixedZone("JST", 9*60*60))
+
+	examples := map[string]struct {
+		partition Partition
+		expected  string
+	}{
+		"none":  {partition: PartitionNone, expected: ""},
+		"hour":  {partition: PartitionHour, expected: "2022-05-01T14"},
+		"day":   {partition: PartitionDay, expected: "2022-05-01"},
+		"month": {partition: PartitionMonth, expected: "2022-05"},
+		"year":  {partition: PartitionYear, expected: "2022"},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, e.expected, e.partition.Key(createdAt.UTC()))
+		})
+	}
+}
+
+func TestRotationFileName(t *testing.T) {
+	examples := map[string]struct {
+		rotation Rotation
+		name     string
+		key      string
+		n        int
+		expected string
+	}{
+		"disabled":  {rotation: Rotation{}, name: "out.csv", key: "", n: 1, expected: "out.csv"},
+		"day":       {rotation: Rotation{PartitionBy: PartitionDay}, name: "out.csv", key: "2022-05-01", n: 1, expected: "out-2022-05-01.csv"},
+		"size":      {rotation: Rotation{MaxBytes: 100}, name: "data/out.csv.gz", key: "", n: 2, expected: "data/out-0002.csv.gz"},
+		"day-count": {rotation: Rotation{MaxRecords: 10, PartitionBy: PartitionDay}, name: "out.csv.zst", key: "2022-05-01", n: 3, expected: "out-2022-05-01-0003.csv.zst"},
+		"no-ext":    {rotation: Rotation{MaxRecords: 10}, name: "out", key: "", n: 1, expected: "out-0001"},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, e.expected, e.rotation.FileName(e.name, e.key, e.n))
+		})
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	examples := map[string]struct {
+		s        string
+		expected uint64
+		msg      string
+	}{
+		"bytes":   {s: "1024", expected: 1024, msg: ""},
+		"mb":      {s: "500MB", expected: 500 * 1000 * 1000, msg: ""},
+		"gib":     {s: "1 GiB", expected: 1 << 30, msg: ""},
+		"lower":   {s: "2kb", expected: 2000, msg: ""},
+		"no-unit": {s: "MB", expected: 0, msg: "invalid size: MB"},
+		"unknown": {s: "1PB", expected: 0, msg: "invalid size: 1PB"},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			actual, err := ParseSize(e.s)
+			if len(e.msg) == 0 {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, e.msg)
+			}
+
+			assert.Equal(t, e.expected, actual)
+		})
+	}
+}
+
+func readDir(t *testing.T, dir string) map[string]string {
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+
+	files := map[string]string{}
+	for _, entry := range entries {
+		buf, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		assert.NoError(t, err)
+		files[entry.Name()] = string(buf)
+	}
+
+	return files
+}
+
+func ids(content string) []string {
+	ids := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(content), "\n")[1:] {
+		ids = append(ids, strings.Split(line, ",")[0])
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+func TestCsvFileExporter(t *testing.T) {
+	day1 := Iso8601Date(time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC))
+	day2 := Iso8601Date(time.Date(2022, 5, 2, 12, 0, 0, 0, time.UTC))
+
+	examples := map[string]struct {
+		rotation Rotation
+		pages    [][]Record
+		expected map[string][]string
+	}{
+		"disabled": {
+			rotation: Rotation{},
+			pages:    [][]Record{{{Id: 1}, {Id: 2}}, {{Id: 3}}},
+			expected: map[string][]string{"out.csv": {"1", "2", "3"}},
+		},
+		"records": {
+			rotation: Rotation{MaxRecords: 2},
+			pages:    [][]Record{{{Id: 1}, {Id: 2}, {Id: 3}}, {{Id: 4}, {Id: 5}}},
+			expected: map[string][]string{"out-0001.csv": {"1", "2"}, "out-0002.csv": {"3", "4"}, "out-0003.csv": {"5"}},
+		},
+		"size": {
+			rotation: Rotation{MaxBytes: 1},
+			pages:    [][]Record{{{Id: 1}, {Id: 2}}, {{Id: 3}}},
+			expected: map[string][]string{"out-0001.csv": {"1", "2"}, "out-0002.csv": {"3"}},
+		},
+		"day": {
+			rotation: Rotation{PartitionBy: PartitionDay},
+			pages:    [][]Record{{{Id: 4, CreatedAt: day2}, {Id: 3, CreatedAt: day1}}, {{Id: 2, CreatedAt: day2}, {Id: 1, CreatedAt: day1}}},
+			expected: map[string][]string{"out-2022-05-01.csv": {"1", "3"}, "out-2022-05-02.csv": {"2", "4"}},
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			exporter := &CsvFileExporter{Rotation: e.rotation, Name: filepath.Join(dir, "out.csv")}
+			assert.NoError(t, exporter.Open())
+
+			ch := make(chan []Record)
+			go func() {
+				defer close(ch)
+
+				for _, page := range e.pages {
+					ch <- page
+				}
+			}()
+
+			assert.NoError(t, <-exporter.Export(ch))
+
+			files := readDir(t, dir)
+			actual := map[string][]string{}
+			for name, content := range files {
+				assert.True(t, strings.HasPrefix(content, strings.Join((&CsvExporter{}).Header(), ",")+"\n"), name)
+				actual[name] = ids(content)
+			}
+			assert.Equal(t, e.expected, actual)
+		})
+	}
+}
+
+func TestCsvFileExporterExists(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "out.csv")
+	assert.NoError(t, os.WriteFile(name, []byte("foo\n"), 0644))
+
+	exporter := &CsvFileExporter{Name: name}
+	assert.ErrorIs(t, exporter.Open(), os.ErrExist)
+}
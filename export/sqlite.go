@@ -0,0 +1,306 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/akiomik/squawks/logging"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS tweets (
+	id INTEGER PRIMARY KEY,
+	user_id INTEGER,
+	created_at TEXT,
+	full_text TEXT NOT NULL,
+	retweet_count INTEGER NOT NULL,
+	favorite_count INTEGER NOT NULL,
+	reply_count INTEGER NOT NULL,
+	quote_count INTEGER NOT NULL,
+	latitude REAL,
+	longitude REAL,
+	place_id TEXT,
+	lang TEXT NOT NULL,
+	source TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS tweets_created_at ON tweets (created_at);
+CREATE INDEX IF NOT EXISTS tweets_user_id ON tweets (user_id);
+
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY,
+	screen_name TEXT NOT NULL,
+	name TEXT NOT NULL,
+	location TEXT NOT NULL,
+	description TEXT NOT NULL,
+	url TEXT NOT NULL,
+	followers_count INTEGER NOT NULL,
+	friends_count INTEGER NOT NULL,
+	listed_count INTEGER NOT NULL,
+	favourites_count INTEGER NOT NULL,
+	statuses_count INTEGER NOT NULL,
+	media_count INTEGER NOT NULL,
+	verified INTEGER NOT NULL,
+	created_at TEXT
+);
+
+CREATE TABLE IF NOT EXISTS hashtags (
+	tweet_id INTEGER NOT NULL,
+	start INTEGER NOT NULL,
+	text TEXT NOT NULL,
+	PRIMARY KEY (tweet_id, start)
+);
+CREATE INDEX IF NOT EXISTS hashtags_text ON hashtags (text);
+
+CREATE TABLE IF NOT EXISTS mentions (
+	tweet_id INTEGER NOT NULL,
+	start INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	screen_name TEXT NOT NULL,
+	name TEXT NOT NULL,
+	PRIMARY KEY (tweet_id, start)
+);
+CREATE INDEX IF NOT EXISTS mentions_user_id ON mentions (user_id);
+
+CREATE TABLE IF NOT EXISTS urls (
+	tweet_id INTEGER NOT NULL,
+	start INTEGER NOT NULL,
+	url TEXT NOT NULL,
+	expanded_url TEXT NOT NULL,
+	display_url TEXT NOT NULL,
+	PRIMARY KEY (tweet_id, start)
+);
+
+CREATE TABLE IF NOT EXISTS places (
+	id TEXT PRIMARY KEY,
+	url TEXT NOT NULL,
+	place_type TEXT NOT NULL,
+	name TEXT NOT NULL,
+	full_name TEXT NOT NULL,
+	country_code TEXT NOT NULL,
+	country TEXT NOT NULL
+);
+`
+
+// Rows are upserted, so that tweets crawled again update their counts.
+const (
+	sqliteUpsertTweet = `INSERT INTO tweets (id, user_id, created_at, full_text, retweet_count, favorite_count, reply_count, quote_count, latitude, longitude, place_id, lang, source)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (id) DO UPDATE SET user_id = excluded.user_id, created_at = excluded.created_at, full_text = excluded.full_text,
+	retweet_count = excluded.retweet_count, favorite_count = excluded.favorite_count, reply_count = excluded.reply_count, quote_count = excluded.quote_count,
+	latitude = excluded.latitude, longitude = excluded.longitude, place_id = excluded.place_id, lang = excluded.lang, source = excluded.source`
+	sqliteUpsertUser = `INSERT INTO users (id, screen_name, name, location, description, url, followers_count, friends_count, listed_count, favourites_count, statuses_count, media_count, verified, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (id) DO UPDATE SET screen_name = excluded.screen_name, name = excluded.name, location = excluded.location, description = excluded.description, url = excluded.url,
+	followers_count = excluded.followers_count, friends_count = excluded.friends_count, listed_count = excluded.listed_count, favourites_count = excluded.favourites_count,
+	statuses_count = excluded.statuses_count, media_count = excluded.media_count, verified = excluded.verified, created_at = excluded.created_at`
+	sqliteUpsertHashtag = `INSERT INTO hashtags (tweet_id, start, text) VALUES (?, ?, ?)
+ON CONFLICT (tweet_id, start) DO UPDATE SET text = excluded.text`
+	sqliteUpsertMention = `INSERT INTO mentions (tweet_id, start, user_id, screen_name, name) VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (tweet_id, start) DO UPDATE SET user_id = excluded.user_id, screen_name = excluded.screen_name, name = excluded.name`
+	sqliteUpsertUrl = `INSERT INTO urls (tweet_id, start, url, expanded_url, display_url) VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (tweet_id, start) DO UPDATE SET url = excluded.url, expanded_url = excluded.expanded_url, display_url = excluded.display_url`
+	sqliteUpsertPlace = `INSERT INTO places (id, url, place_type, name, full_name, country_code, country) VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (id) DO UPDATE SET url = excluded.url, place_type = excluded.place_type, name = excluded.name, full_name = excluded.full_name,
+	country_code = excluded.country_code, country = excluded.country`
+)
+
+// SqliteExporter writes records to a SQLite database with tweets, users,
+// hashtags, mentions, urls and places tables. Each page is written in one
+// transaction. Timestamps are stored as ISO 8601 text in UTC.
+type SqliteExporter struct {
+	Name string
+	// Mode is OutputCreate, OutputOverwrite or OutputAppend, where appending
+	// updates the tables of an existing database.
+	Mode OutputMode
+	// Logger defaults to logging.Default().
+	Logger logging.Logger
+
+	db *sql.DB
+}
+
+// Open opens the database and creates the tables if needed.
+func (e *SqliteExporter) Open() error {
+	if !sqliteSupported {
+		return fmt.Errorf("sqlite output is not supported by this build, which was built without cgo")
+	}
+
+	// A database created here is removed if it cannot be initialized.
+	_, err := os.Stat(e.Name)
+	created := e.Mode != OutputAppend || errors.Is(err, fs.ErrNotExist)
+
+	if e.Mode != OutputAppend {
+		_, closeFile, err := Create(e.Name, e.Mode)
+		if err != nil {
+			return err
+		}
+
+		if err := closeFile(); err != nil {
+			return err
+		}
+	}
+
+	db, err := sql.Open("sqlite3", sqliteDsn(e.Name))
+	if err == nil {
+		if _, err = db.Exec(sqliteSchema); err != nil {
+			db.Close()
+			err = fmt.Errorf("failed to create tables in %s: %w", e.Name, err)
+		}
+	}
+
+	if err != nil {
+		if created {
+			os.Remove(e.Name)
+		}
+
+		return err
+	}
+
+	e.db = db
+	orDefault(e.Logger).Info("opened output", "file", e.Name, "mode", e.Mode)
+	return nil
+}
+
+// sqliteDsn returns the data source name of the database file name, escaping
+// the name so that ? and # in it are not read as connection parameters.
+func sqliteDsn(name string) string {
+	return "file:" + url.PathEscape(name) + "?mode=rwc"
+}
+
+// Close closes the database. Calling Close more than once is a no-op.
+func (e *SqliteExporter) Close() error {
+	if e.db == nil {
+		return nil
+	}
+
+	err := e.db.Close()
+	e.db = nil
+	return err
+}
+
+// Export writes pages of records until ch is closed and closes the database.
+func (e *SqliteExporter) Export(ch <-chan []Record) <-chan error {
+	return runExport(orDefault(e.Logger), "sqlite", ch, func() error {
+		var err error
+		for records := range ch {
+			if err = e.WritePage(records); err != nil {
+				break
+			}
+		}
+
+		if cerr := e.Close(); err == nil {
+			err = cerr
+		}
+
+		return err
+	})
+}
+
+// WritePage upserts records and their users, entities and places.
+func (e *SqliteExporter) WritePage(records []Record) error {
+	if e.db == nil {
+		if err := e.Open(); err != nil {
+			return err
+		}
+	}
+
+	tx, err := e.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for i := range records {
+		if err := upsertRecord(tx, &records[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to write tweet %d: %w", records[i].Id, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func upsertRecord(tx *sql.Tx, r *Record) error {
+	var userId, placeId interface{}
+	if r.Tweet != nil {
+		userId = r.Tweet.UserId
+		if r.Tweet.Place != nil && len(r.Tweet.Place.Id) != 0 {
+			placeId = r.Tweet.Place.Id
+		}
+	} else if r.User != nil {
+		userId = r.User.Id
+	}
+
+	_, err := tx.Exec(sqliteUpsertTweet, r.Id, userId, sqliteTime(time.Time(r.CreatedAt)), r.FullText,
+		r.RetweetCount, r.FavoriteCount, r.ReplyCount, r.QuoteCount, r.Latitude, r.Longitude, placeId, r.Lang, r.Source)
+	if err != nil {
+		return err
+	}
+
+	if u := r.User; u != nil && u.Id != 0 {
+		_, err := tx.Exec(sqliteUpsertUser, u.Id, u.ScreenName, u.Name, u.Location, u.Description, u.Url,
+			u.FollowersCount, u.FriendsCount, u.ListedCount, u.FavouritesCount, u.StatusesCount, u.MediaCount,
+			u.Verified, sqliteTime(time.Time(u.CreatedAt)))
+		if err != nil {
+			return err
+		}
+	}
+
+	t := r.Tweet
+	if t == nil {
+		return nil
+	}
+
+	if p := t.Place; p != nil && len(p.Id) != 0 {
+		_, err := tx.Exec(sqliteUpsertPlace, p.Id, p.Url, p.PlaceType, p.Name, p.FullName, p.CountryCode, p.Country)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, h := range t.Entities.Hashtags {
+		if _, err := tx.Exec(sqliteUpsertHashtag, r.Id, h.Indices[0], h.Text); err != nil {
+			return err
+		}
+	}
+
+	for _, m := range t.Entities.UserMentions {
+		if _, err := tx.Exec(sqliteUpsertMention, r.Id, m.Indices[0], m.Id, m.ScreenName, m.Name); err != nil {
+			return err
+		}
+	}
+
+	for _, u := range t.Entities.Urls {
+		if _, err := tx.Exec(sqliteUpsertUrl, r.Id, u.Indices[0], u.Url, u.ExpandedUrl, u.DisplayUrl); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sqliteTime returns t as text that SQLite date functions understand, or nil if t is unknown.
+func sqliteTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+
+	return t.UTC().Format(Iso8601Layout)
+}
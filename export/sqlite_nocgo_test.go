@@ -0,0 +1,32 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build small && !cgo
+// +build small,!cgo
+
+package export
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSqliteExporterWithoutCgo(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "out.db")
+	err := (&SqliteExporter{Name: name}).Open()
+	assert.EqualError(t, err, "sqlite output is not supported by this build, which was built without cgo")
+	assert.NoFileExists(t, name)
+}
@@ -0,0 +1,155 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build medium && cgo
+// +build medium,cgo
+
+package export
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/akiomik/squawks/api/json"
+)
+
+func TestSqliteExporter(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "out.db")
+	createdAt := time.Date(2020, 9, 6, 0, 1, 2, 0, time.UTC)
+	tweet := json.Tweet{
+		Id:       1,
+		UserId:   10,
+		FullText: "#baker street @holmes https://t.co/x",
+		Place:    &json.Place{Id: "p1", Name: "London", FullName: "London, England", CountryCode: "GB", Country: "United Kingdom"},
+		Entities: json.Entities{
+			Hashtags:     []json.Hashtag{{Text: "baker", Indices: json.Indices{0, 6}}},
+			UserMentions: []json.UserMention{{Id: 20, ScreenName: "holmes", Name: "Sherlock", Indices: json.Indices{14, 21}}},
+			Urls:         []json.Url{{Url: "https://t.co/x", ExpandedUrl: "https://example.com", DisplayUrl: "example.com", Indices: json.Indices{22, 36}}},
+		},
+	}
+	user := json.User{Id: 10, ScreenName: "watson", Name: "John Watson", FollowersCount: 5}
+	record := func(retweets uint64) Record {
+		return Record{Id: 1, Username: "watson", CreatedAt: Iso8601Date(createdAt), FullText: tweet.FullText, RetweetCount: retweets, Tweet: &tweet, User: &user}
+	}
+
+	// The same tweet is exported twice as if crawled again.
+	for i, mode := range []OutputMode{OutputCreate, OutputAppend} {
+		e := &SqliteExporter{Name: name, Mode: mode}
+		if !assert.NoError(t, e.Open()) {
+			return
+		}
+
+		ch := make(chan []Record, 2)
+		ch <- []Record{record(uint64(i + 1))}
+		ch <- []Record{{Id: 2, FullText: "no user"}}
+		close(ch)
+		assert.NoError(t, <-e.Export(ch))
+	}
+
+	db, err := sql.Open("sqlite3", name)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer db.Close()
+
+	examples := map[string]struct {
+		query    string
+		expected string
+	}{
+		"tweets":   {query: "SELECT count(*) || ',' || sum(retweet_count) FROM tweets", expected: "2,2"},
+		"tweet":    {query: "SELECT user_id || ',' || created_at || ',' || place_id FROM tweets WHERE id = 1", expected: "10,2020-09-06T00:01:02+00:00,p1"},
+		"users":    {query: "SELECT group_concat(id || ',' || screen_name || ',' || followers_count) FROM users", expected: "10,watson,5"},
+		"hashtags": {query: "SELECT group_concat(tweet_id || ',' || text) FROM hashtags", expected: "1,baker"},
+		"mentions": {query: "SELECT group_concat(user_id || ',' || screen_name) FROM mentions", expected: "20,holmes"},
+		"urls":     {query: "SELECT group_concat(expanded_url) FROM urls", expected: "https://example.com"},
+		"places":   {query: "SELECT group_concat(id || ',' || full_name) FROM places", expected: "p1,London, England"},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			var actual string
+			assert.NoError(t, db.QueryRow(e.query).Scan(&actual))
+			assert.Equal(t, e.expected, actual)
+		})
+	}
+}
+
+func TestSqliteExporterExists(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "out.db")
+	e := &SqliteExporter{Name: name}
+	assert.NoError(t, e.Open())
+	assert.NoError(t, e.Close())
+
+	err := (&SqliteExporter{Name: name}).Open()
+	assert.ErrorContains(t, err, "file exists")
+
+	e = &SqliteExporter{Name: name, Mode: OutputOverwrite}
+	assert.NoError(t, e.Open())
+	assert.NoError(t, e.Close())
+}
+
+func TestSqliteExporterSpecialName(t *testing.T) {
+	dir := t.TempDir()
+
+	// ? and # are part of the file name rather than connection parameters.
+	for _, base := range []string{"out.db?_txlock=foo", "out#1.db", "out%20.db"} {
+		name := filepath.Join(dir, base)
+		e := &SqliteExporter{Name: name}
+		if !assert.NoError(t, e.Open()) {
+			continue
+		}
+
+		ch := make(chan []Record, 1)
+		ch <- []Record{{Id: 1, FullText: "foo"}}
+		close(ch)
+		assert.NoError(t, <-e.Export(ch))
+
+		db, err := sql.Open("sqlite3", sqliteDsn(name))
+		if !assert.NoError(t, err) {
+			continue
+		}
+
+		var count int
+		assert.NoError(t, db.QueryRow("SELECT count(*) FROM tweets").Scan(&count))
+		assert.Equal(t, 1, count)
+		assert.NoError(t, db.Close())
+	}
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+}
+
+func TestSqliteExporterOpenError(t *testing.T) {
+	dir := t.TempDir()
+
+	// The journal cannot be created over a directory, so the tables cannot be created.
+	name := filepath.Join(dir, "out.db")
+	assert.NoError(t, os.Mkdir(name+"-journal", 0755))
+	err := (&SqliteExporter{Name: name}).Open()
+	assert.ErrorContains(t, err, "failed to create tables")
+	assert.NoFileExists(t, name)
+
+	// An existing file is kept when appending.
+	name = filepath.Join(dir, "notes.txt")
+	assert.NoError(t, os.WriteFile(name, []byte("not a database"), 0644))
+	err = (&SqliteExporter{Name: name, Mode: OutputAppend}).Open()
+	assert.ErrorContains(t, err, "failed to create tables")
+	assert.FileExists(t, name)
+}
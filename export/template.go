@@ -0,0 +1,246 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/akiomik/squawks/logging"
+)
+
+// TemplateSummary is the data of header and footer templates.
+type TemplateSummary struct {
+	// Tweets is the number of tweets written, which is 0 in the header.
+	Tweets uint64
+}
+
+// TemplateFuncs returns the helper functions of templates, which format
+// timestamps with f:
+//
+//   - time formats a timestamp with f, e.g. {{time .CreatedAt}}
+//   - date formats a timestamp with a Go layout in the location of f, e.g. {{date "Jan 2" .CreatedAt}}
+//   - truncate shortens text to a number of characters ending with …, e.g. {{truncate 80 .FullText}}
+//   - json encodes a value as JSON, e.g. {{json .FullText}} for a quoted and escaped string
+//   - expandUrls returns the text of a record with t.co links replaced by their targets, e.g. {{expandUrls .}}
+func TemplateFuncs(f TimeFormat) template.FuncMap {
+	return template.FuncMap{
+		"time": func(v interface{}) (string, error) {
+			t, err := templateTime(v)
+			if err != nil {
+				return "", err
+			}
+
+			return f.Format(t), nil
+		},
+		"date": func(layout string, v interface{}) (string, error) {
+			t, err := templateTime(v)
+			if err != nil || t.IsZero() {
+				return "", err
+			}
+
+			return f.In(t).Format(layout), nil
+		},
+		"truncate":   truncateText,
+		"json":       templateJson,
+		"expandUrls": ExpandUrls,
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// templateTime converts time.Time, Iso8601Date, json.RubyDate or pointers to
+// them to time.Time.
+func templateTime(v interface{}) (time.Time, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return time.Time{}, nil
+		}
+
+		rv = rv.Elem()
+	}
+
+	if !rv.IsValid() || !rv.Type().ConvertibleTo(timeType) {
+		return time.Time{}, fmt.Errorf("not a timestamp: %v", v)
+	}
+
+	return rv.Convert(timeType).Interface().(time.Time), nil
+}
+
+// truncateText returns s shortened to at most n characters, ending with … if it
+// was shortened.
+func truncateText(n int, s string) string {
+	if n <= 0 {
+		return ""
+	}
+
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+
+	rs := []rune(s)
+	return string(rs[:n-1]) + "…"
+}
+
+func templateJson(v interface{}) (string, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+
+	return string(bytes.TrimRight(b.Bytes(), "\n")), nil
+}
+
+// ExpandUrls returns the text of r with its t.co links replaced by the urls
+// they point to.
+func ExpandUrls(r *Record) string {
+	if r.Tweet == nil || len(r.Tweet.Entities.Urls) == 0 {
+		return r.FullText
+	}
+
+	pairs := make([]string, 0, len(r.Tweet.Entities.Urls)*2)
+	for _, u := range r.Tweet.Entities.Urls {
+		if len(u.Url) != 0 && len(u.ExpandedUrl) != 0 {
+			pairs = append(pairs, u.Url, u.ExpandedUrl)
+		}
+	}
+
+	return strings.NewReplacer(pairs...).Replace(r.FullText)
+}
+
+// ParseTemplateFile parses a template file with the helpers of TemplateFuncs.
+func ParseTemplateFile(name string, f TimeFormat) (*template.Template, error) {
+	text, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := template.New(filepath.Base(name)).Funcs(TemplateFuncs(f)).Parse(string(text))
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+
+	return t, nil
+}
+
+// TemplateExporter renders each record with a text template. The record is
+// the data of the template, so that fields such as {{.FullText}} and the
+// source objects {{.Tweet}} and {{.User}} can be used. The optional header
+// and footer templates are rendered once with a TemplateSummary.
+type TemplateExporter struct {
+	Name        string
+	Mode        OutputMode
+	Compression Compression
+	Template    *template.Template
+	Header      *template.Template
+	Footer      *template.Template
+	// Logger defaults to logging.Default().
+	Logger logging.Logger
+
+	out    *Output
+	tweets uint64
+}
+
+// Open creates the file and renders the header.
+func (e *TemplateExporter) Open() error {
+	if e.Template == nil {
+		return fmt.Errorf("template output requires a template")
+	}
+
+	out, err := Open(e.Name, e.Mode, e.Compression)
+	if err != nil {
+		return err
+	}
+
+	e.out = out
+	e.tweets = 0
+	if e.Header != nil {
+		if err := e.Header.Execute(out, TemplateSummary{}); err != nil {
+			out.Close()
+			e.out = nil
+			return err
+		}
+	}
+
+	orDefault(e.Logger).Info("opened output", "file", e.Name, "mode", e.Mode)
+	return nil
+}
+
+// Close renders the footer and closes the file. Calling Close more than once is a no-op.
+func (e *TemplateExporter) Close() error {
+	if e.out == nil {
+		return nil
+	}
+
+	var err error
+	if e.Footer != nil {
+		err = e.Footer.Execute(e.out, TemplateSummary{Tweets: e.tweets})
+	}
+
+	if cerr := e.out.Close(); err == nil {
+		err = cerr
+	}
+
+	e.out = nil
+	return err
+}
+
+// Export renders pages of records until ch is closed and closes the file.
+func (e *TemplateExporter) Export(ch <-chan []Record) <-chan error {
+	return runExport(orDefault(e.Logger), "template", ch, func() error {
+		var err error
+		for records := range ch {
+			if err = e.WritePage(records); err != nil {
+				break
+			}
+		}
+
+		if cerr := e.Close(); err == nil {
+			err = cerr
+		}
+
+		return err
+	})
+}
+
+// WritePage renders each record and flushes the output.
+func (e *TemplateExporter) WritePage(records []Record) error {
+	if e.out == nil {
+		if err := e.Open(); err != nil {
+			return err
+		}
+	}
+
+	for i := range records {
+		if err := e.Template.Execute(e.out, &records[i]); err != nil {
+			return err
+		}
+
+		e.tweets++
+	}
+
+	return e.out.Flush()
+}
@@ -0,0 +1,214 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build medium
+// +build medium
+
+package export
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/akiomik/squawks/api/json"
+)
+
+func TestTemplateFuncs(t *testing.T) {
+	createdAt := time.Date(2020, 9, 6, 0, 1, 2, 0, time.UTC)
+	tweet := json.Tweet{
+		CreatedAt: json.RubyDate(createdAt),
+		Entities: json.Entities{
+			Urls: []json.Url{{Url: "https://t.co/x", ExpandedUrl: "https://example.com/a"}},
+		},
+	}
+	record := &Record{
+		CreatedAt: Iso8601Date(createdAt),
+		FullText:  "see \"https://t.co/x\" & https://t.co/y",
+		Tweet:     &tweet,
+	}
+	jst, err := time.LoadLocation("Asia/Tokyo")
+	assert.NoError(t, err)
+
+	examples := map[string]struct {
+		text     string
+		format   TimeFormat
+		expected string
+		msg      string
+	}{
+		"time": {
+			text:     "{{time .CreatedAt}}",
+			format:   TimeFormat{},
+			expected: "2020-09-06T00:01:02+00:00",
+			msg:      "",
+		},
+		"time-unix": {
+			text:     "{{time .CreatedAt}}",
+			format:   TimeFormat{Layout: TimeFormatUnix},
+			expected: "1599350462",
+			msg:      "",
+		},
+		"time-ruby-date": {
+			text:     "{{time .Tweet.CreatedAt}}",
+			format:   TimeFormat{},
+			expected: "2020-09-06T00:01:02+00:00",
+			msg:      "",
+		},
+		"time-unknown": {
+			text:     "{{time .UserCreatedAt}}",
+			format:   TimeFormat{},
+			expected: "",
+			msg:      "",
+		},
+		"time-not-a-timestamp": {
+			text:     "{{time .FullText}}",
+			format:   TimeFormat{},
+			expected: "",
+			msg:      "not a timestamp",
+		},
+		"date": {
+			text:     "{{date \"Jan 2 15:04\" .CreatedAt}}",
+			format:   TimeFormat{Location: jst},
+			expected: "Sep 6 09:01",
+			msg:      "",
+		},
+		"truncate": {
+			text:     "{{truncate 5 \"こんにちは世界\"}}|{{truncate 5 \"hello\"}}",
+			format:   TimeFormat{},
+			expected: "こんにち…|hello",
+			msg:      "",
+		},
+		"json": {
+			text:     "{{json .FullText}}",
+			format:   TimeFormat{},
+			expected: `"see \"https://t.co/x\" & https://t.co/y"`,
+			msg:      "",
+		},
+		"expand-urls": {
+			text:     "{{expandUrls .}}",
+			format:   TimeFormat{},
+			expected: "see \"https://example.com/a\" & https://t.co/y",
+			msg:      "",
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			tmpl := template.Must(template.New(name).Funcs(TemplateFuncs(e.format)).Parse(e.text))
+
+			var b bytes.Buffer
+			err := tmpl.Execute(&b, record)
+			if len(e.msg) == 0 {
+				assert.NoError(t, err)
+				assert.Equal(t, e.expected, b.String())
+			} else {
+				assert.ErrorContains(t, err, e.msg)
+			}
+		})
+	}
+}
+
+func TestParseTemplateFile(t *testing.T) {
+	examples := map[string]struct {
+		content string
+		msg     string
+	}{
+		"valid":   {content: "{{.Username}}: {{truncate 10 .FullText}}\n", msg: ""},
+		"invalid": {content: "{{.Username", msg: "invalid template: "},
+		"unknown": {content: "{{shout .Username}}", msg: "invalid template: "},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tweet.tmpl")
+			assert.NoError(t, os.WriteFile(path, []byte(e.content), 0644))
+
+			actual, err := ParseTemplateFile(path, TimeFormat{})
+			if len(e.msg) == 0 {
+				assert.NoError(t, err)
+				assert.Equal(t, "tweet.tmpl", actual.Name())
+			} else {
+				assert.ErrorContains(t, err, e.msg)
+			}
+		})
+	}
+}
+
+func TestTemplateExporter(t *testing.T) {
+	funcs := TemplateFuncs(TimeFormat{})
+	pages := [][]Record{
+		{{Username: "watson", FullText: "a"}, {Username: "holmes", FullText: "b"}},
+		{{Username: "hudson", FullText: "c"}},
+	}
+
+	examples := map[string]struct {
+		header   string
+		footer   string
+		expected string
+	}{
+		"body": {
+			header:   "",
+			footer:   "",
+			expected: "- @watson: a\n- @holmes: b\n- @hudson: c\n",
+		},
+		"header-and-footer": {
+			header:   "# Digest\n",
+			footer:   "{{.Tweets}} tweets\n",
+			expected: "# Digest\n- @watson: a\n- @holmes: b\n- @hudson: c\n3 tweets\n",
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			out := filepath.Join(t.TempDir(), "out.md")
+			exporter := &TemplateExporter{
+				Name:     out,
+				Template: template.Must(template.New("body").Funcs(funcs).Parse("- @{{.Username}}: {{.FullText}}\n")),
+			}
+
+			if len(e.header) != 0 {
+				exporter.Header = template.Must(template.New("header").Parse(e.header))
+			}
+
+			if len(e.footer) != 0 {
+				exporter.Footer = template.Must(template.New("footer").Parse(e.footer))
+			}
+
+			if !assert.NoError(t, exporter.Open()) {
+				return
+			}
+
+			ch := make(chan []Record, len(pages))
+			for _, p := range pages {
+				ch <- p
+			}
+			close(ch)
+			assert.NoError(t, <-exporter.Export(ch))
+
+			actual, err := os.ReadFile(out)
+			assert.NoError(t, err)
+			assert.Equal(t, e.expected, string(actual))
+		})
+	}
+}
+
+func TestTemplateExporterWithoutTemplate(t *testing.T) {
+	e := &TemplateExporter{Name: filepath.Join(t.TempDir(), "out.txt")}
+	assert.EqualError(t, e.Open(), "template output requires a template")
+}
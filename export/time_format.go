@@ -0,0 +1,99 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Iso8601Layout is the default layout of exported timestamps.
+	Iso8601Layout = "2006-01-02T15:04:05-07:00"
+
+	TimeFormatRFC3339   = "rfc3339"
+	TimeFormatUnix      = "unix"
+	TimeFormatUnixMilli = "unixms"
+)
+
+// TimeFormat formats the timestamps of exported records.
+type TimeFormat struct {
+	// Layout is a Go time layout, TimeFormatUnix or TimeFormatUnixMilli.
+	// It defaults to Iso8601Layout.
+	Layout string
+	// Location defaults to UTC.
+	Location *time.Location
+}
+
+// ParseTimeFormat parses rfc3339, unix, unixms or a Go time layout, and an IANA
+// time zone name such as Asia/Tokyo. Empty values select the defaults.
+func ParseTimeFormat(format string, timezone string) (TimeFormat, error) {
+	f := TimeFormat{}
+
+	switch strings.ToLower(format) {
+	case "":
+	case TimeFormatRFC3339:
+		f.Layout = time.RFC3339
+	case TimeFormatUnix, TimeFormatUnixMilli:
+		f.Layout = strings.ToLower(format)
+	default:
+		// A layout without any reference time element would print itself.
+		if time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC).Format(format) == format {
+			return f, fmt.Errorf("invalid time format: %s", format)
+		}
+
+		f.Layout = format
+	}
+
+	if len(timezone) != 0 {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return f, fmt.Errorf("invalid timezone: %w", err)
+		}
+
+		f.Location = loc
+	}
+
+	return f, nil
+}
+
+// In returns t in the location of f.
+func (f TimeFormat) In(t time.Time) time.Time {
+	if f.Location == nil {
+		return t.UTC()
+	}
+
+	return t.In(f.Location)
+}
+
+// Format formats t, or returns an empty string if t is unknown.
+func (f TimeFormat) Format(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	switch f.Layout {
+	case TimeFormatUnix:
+		return strconv.FormatInt(t.Unix(), 10)
+	case TimeFormatUnixMilli:
+		return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+	case "":
+		return f.In(t).Format(Iso8601Layout)
+	default:
+		return f.In(t).Format(f.Layout)
+	}
+}
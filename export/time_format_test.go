@@ -0,0 +1,64 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build small
+// +build small
+
+package export
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTimeFormat(t *testing.T) {
+	createdAt := time.Date(2022, 5, 1, 23, 4, 5, 678000000, time.UTC)
+
+	examples := map[string]struct {
+		format   string
+		timezone string
+		expected string
+		msg      string
+	}{
+		"default":        {format: "", timezone: "", expected: "2022-05-01T23:04:05+00:00", msg: ""},
+		"default-tokyo":  {format: "", timezone: "Asia/Tokyo", expected: "2022-05-02T08:04:05+09:00", msg: ""},
+		"rfc3339":        {format: "rfc3339", timezone: "", expected: "2022-05-01T23:04:05Z", msg: ""},
+		"rfc3339-tokyo":  {format: "RFC3339", timezone: "Asia/Tokyo", expected: "2022-05-02T08:04:05+09:00", msg: ""},
+		"unix":           {format: "unix", timezone: "Asia/Tokyo", expected: "1651446245", msg: ""},
+		"unixms":         {format: "unixms", timezone: "", expected: "1651446245678", msg: ""},
+		"layout":         {format: "2006/01/02 15:04", timezone: "America/New_York", expected: "2022/05/01 19:04", msg: ""},
+		"invalid-layout": {format: "iso", timezone: "", expected: "", msg: "invalid time format: iso"},
+		"invalid-zone":   {format: "", timezone: "Mars/Olympus", expected: "", msg: "invalid timezone: unknown time zone Mars/Olympus"},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			f, err := ParseTimeFormat(e.format, e.timezone)
+			if len(e.msg) == 0 {
+				assert.NoError(t, err)
+				assert.Equal(t, e.expected, f.Format(createdAt))
+			} else {
+				assert.EqualError(t, err, e.msg)
+			}
+		})
+	}
+}
+
+func TestTimeFormatFormatZero(t *testing.T) {
+	f, err := ParseTimeFormat("unix", "Asia/Tokyo")
+	assert.NoError(t, err)
+	assert.Equal(t, "", f.Format(time.Time{}))
+}
@@ -0,0 +1,429 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/text/width"
+
+	"github.com/akiomik/squawks/logging"
+)
+
+const (
+	// DefaultSheetRows is the maximum number of rows of an Excel worksheet.
+	DefaultSheetRows = excelize.TotalRows
+	// XlsxSheetName is the name of the first worksheet. Further sheets are
+	// numbered from 2.
+	XlsxSheetName = "tweets"
+	// xlsxSampleRows is the number of rows of each sheet used to size its columns.
+	xlsxSampleRows = 1000
+	// xlsxMaxColumnWidth caps the width of columns with long text.
+	xlsxMaxColumnWidth = 60
+	xlsxDateFormat     = "yyyy-mm-dd hh:mm:ss"
+)
+
+// XlsxExporter writes records to an Excel workbook. Ids are written as text,
+// counts and coordinates as numbers and timestamps as date cells in Location.
+// The header row is frozen, columns are sized to their contents and a new
+// sheet is started once a sheet has SheetRows rows.
+type XlsxExporter struct {
+	Name string
+	// Mode is OutputCreate or OutputOverwrite. Workbooks cannot be appended to.
+	Mode    OutputMode
+	Columns []Column
+	// Location is the time zone of timestamps, or UTC if nil.
+	Location *time.Location
+	// SheetRows is the maximum number of rows of a sheet including the header,
+	// or DefaultSheetRows if 0.
+	SheetRows int
+	// Logger defaults to logging.Default().
+	Logger logging.Logger
+
+	f         *excelize.File
+	w         *excelize.StreamWriter
+	closeFile func() error
+	out       *os.File
+	styles    xlsxStyles
+	sheets    int
+	rows      int
+	pending   [][]interface{}
+}
+
+type xlsxStyles struct {
+	header int
+	text   int
+	date   int
+}
+
+// Open creates the file and the workbook, which is written on Close.
+func (e *XlsxExporter) Open() error {
+	if e.Mode == OutputAppend {
+		return fmt.Errorf("xlsx output cannot be appended to")
+	}
+
+	if e.SheetRows == 1 || e.SheetRows < 0 || e.SheetRows > DefaultSheetRows {
+		return fmt.Errorf("invalid sheet rows: %d", e.SheetRows)
+	}
+
+	out, closeFile, err := Create(e.Name, e.Mode)
+	if err != nil {
+		return err
+	}
+
+	f := excelize.NewFile()
+	styles, err := newXlsxStyles(f)
+	if err != nil {
+		f.Close()
+		closeFile()
+		return err
+	}
+
+	e.f = f
+	e.out = out
+	e.closeFile = closeFile
+	e.styles = styles
+	e.sheets = 0
+	e.w = nil
+	orDefault(e.Logger).Info("opened output", "file", e.Name, "mode", e.Mode)
+	return nil
+}
+
+func newXlsxStyles(f *excelize.File) (xlsxStyles, error) {
+	var s xlsxStyles
+	var err error
+	if s.header, err = f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}}); err != nil {
+		return s, err
+	}
+
+	// 49 is the builtin text format "@".
+	if s.text, err = f.NewStyle(&excelize.Style{NumFmt: 49}); err != nil {
+		return s, err
+	}
+
+	format := xlsxDateFormat
+	if s.date, err = f.NewStyle(&excelize.Style{CustomNumFmt: &format}); err != nil {
+		return s, err
+	}
+
+	return s, nil
+}
+
+func (e *XlsxExporter) columns() []Column {
+	if len(e.Columns) == 0 {
+		return DefaultColumns
+	}
+
+	return e.Columns
+}
+
+func (e *XlsxExporter) sheetRows() int {
+	if e.SheetRows == 0 {
+		return DefaultSheetRows
+	}
+
+	return e.SheetRows
+}
+
+// Close writes the workbook and closes the file. Calling Close more than once
+// is a no-op.
+func (e *XlsxExporter) Close() error {
+	if e.f == nil {
+		return nil
+	}
+
+	err := e.endSheet()
+	if err == nil && e.sheets == 0 {
+		err = e.beginSheet()
+		if err == nil {
+			err = e.endSheet()
+		}
+	}
+
+	if err == nil {
+		err = e.f.Write(e.out)
+	}
+
+	if cerr := e.f.Close(); err == nil {
+		err = cerr
+	}
+
+	if cerr := e.closeFile(); err == nil {
+		err = cerr
+	}
+
+	e.f = nil
+	e.w = nil
+	e.out = nil
+	e.closeFile = nil
+	e.pending = nil
+	return err
+}
+
+// Export writes pages of records until ch is closed and closes the file.
+func (e *XlsxExporter) Export(ch <-chan []Record) <-chan error {
+	return runExport(orDefault(e.Logger), "xlsx", ch, func() error {
+		var err error
+		for records := range ch {
+			if err = e.WritePage(records); err != nil {
+				break
+			}
+		}
+
+		if cerr := e.Close(); err == nil {
+			err = cerr
+		}
+
+		return err
+	})
+}
+
+// WritePage adds a row for each record, starting a new sheet when the current
+// one is full.
+func (e *XlsxExporter) WritePage(records []Record) error {
+	if e.f == nil {
+		if err := e.Open(); err != nil {
+			return err
+		}
+	}
+
+	for i := range records {
+		if e.w == nil {
+			if err := e.beginSheet(); err != nil {
+				return err
+			}
+		}
+
+		if err := e.writeRow(e.row(&records[i])); err != nil {
+			return err
+		}
+
+		if e.rows == e.sheetRows() {
+			if err := e.endSheet(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// beginSheet adds a sheet whose rows are held back until its columns are sized.
+func (e *XlsxExporter) beginSheet() error {
+	e.sheets++
+	name := XlsxSheetName
+	if e.sheets == 1 {
+		if err := e.f.SetSheetName("Sheet1", name); err != nil {
+			return err
+		}
+	} else {
+		name = fmt.Sprintf("%s %d", XlsxSheetName, e.sheets)
+		if _, err := e.f.NewSheet(name); err != nil {
+			return err
+		}
+
+		orDefault(e.Logger).Info("started a new sheet", "file", e.Name, "sheet", name)
+	}
+
+	w, err := e.f.NewStreamWriter(name)
+	if err != nil {
+		return err
+	}
+
+	e.w = w
+	e.rows = 0
+	e.pending = make([][]interface{}, 0, xlsxSampleRows)
+
+	header := make([]interface{}, 0, len(e.columns()))
+	for _, c := range e.columns() {
+		header = append(header, excelize.Cell{StyleID: e.styles.header, Value: c.Name})
+	}
+
+	return e.writeRow(header)
+}
+
+func (e *XlsxExporter) writeRow(row []interface{}) error {
+	e.rows++
+	if e.pending != nil {
+		e.pending = append(e.pending, row)
+		if len(e.pending) < xlsxSampleRows {
+			return nil
+		}
+
+		return e.flushPending()
+	}
+
+	cell, err := excelize.CoordinatesToCellName(1, e.rows)
+	if err != nil {
+		return err
+	}
+
+	return e.w.SetRow(cell, row)
+}
+
+// flushPending sizes the columns and freezes the header of the current sheet
+// by the rows held back so far, and writes them.
+func (e *XlsxExporter) flushPending() error {
+	for i, w := range xlsxColumnWidths(e.columns(), e.pending) {
+		if err := e.w.SetColWidth(i+1, i+1, w); err != nil {
+			return err
+		}
+	}
+
+	panes := &excelize.Panes{
+		Freeze:      true,
+		YSplit:      1,
+		TopLeftCell: "A2",
+		ActivePane:  "bottomLeft",
+	}
+	if err := e.w.SetPanes(panes); err != nil {
+		return err
+	}
+
+	pending := e.pending
+	e.pending = nil
+	for i, row := range pending {
+		cell, err := excelize.CoordinatesToCellName(1, e.rows-len(pending)+i+1)
+		if err != nil {
+			return err
+		}
+
+		if err := e.w.SetRow(cell, row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// endSheet writes the rows left in the current sheet.
+func (e *XlsxExporter) endSheet() error {
+	if e.w == nil {
+		return nil
+	}
+
+	if e.pending != nil {
+		if err := e.flushPending(); err != nil {
+			return err
+		}
+	}
+
+	err := e.w.Flush()
+	e.w = nil
+	return err
+}
+
+// row returns the cells of r. Ints in id columns are written as text, as
+// Excel keeps only 15 significant digits of numbers.
+func (e *XlsxExporter) row(r *Record) []interface{} {
+	loc := e.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	row := make([]interface{}, 0, len(e.columns()))
+	for _, c := range e.columns() {
+		var v interface{}
+		switch {
+		case c.Time != nil:
+			if t := c.Time(r); !t.IsZero() {
+				v = excelize.Cell{StyleID: e.styles.date, Value: t.In(loc)}
+			}
+		case c.Type == "int" && isIdColumn(c.Name):
+			v = excelize.Cell{StyleID: e.styles.text, Value: c.Value(r)}
+		case c.Type == "int":
+			if n, err := strconv.ParseUint(c.Value(r), 10, 64); err == nil {
+				v = n
+			}
+		case c.Type == "float":
+			if f, err := strconv.ParseFloat(c.Value(r), 64); err == nil {
+				v = f
+			}
+		default:
+			if s := c.Value(r); len(s) != 0 {
+				v = s
+			}
+		}
+
+		row = append(row, v)
+	}
+
+	return row
+}
+
+func isIdColumn(name string) bool {
+	return name == "id" || strings.HasSuffix(name, "_id")
+}
+
+// xlsxColumnWidths returns the widths of columns fitting the header and the
+// given rows, counting wide characters twice.
+func xlsxColumnWidths(cs []Column, rows [][]interface{}) []float64 {
+	widths := make([]float64, len(cs))
+	for _, row := range rows {
+		for i, v := range row {
+			if c, ok := v.(excelize.Cell); ok {
+				v = c.Value
+			}
+
+			var w int
+			switch v := v.(type) {
+			case nil:
+			case string:
+				w = textWidth(v)
+			case time.Time:
+				w = len(xlsxDateFormat)
+			default:
+				w = len(fmt.Sprint(v))
+			}
+
+			widths[i] = math.Max(widths[i], float64(w))
+		}
+	}
+
+	for i := range widths {
+		// Leave room for padding.
+		widths[i] = math.Min(widths[i]+2, xlsxMaxColumnWidth)
+	}
+
+	return widths
+}
+
+// textWidth returns the width of the longest line of s.
+func textWidth(s string) int {
+	max := 0
+	for _, line := range strings.Split(s, "\n") {
+		w := 0
+		for _, r := range line {
+			switch width.LookupRune(r).Kind() {
+			case width.EastAsianWide, width.EastAsianFullwidth:
+				w += 2
+			default:
+				w++
+			}
+		}
+
+		if w > max {
+			max = w
+		}
+	}
+
+	return max
+}
@@ -0,0 +1,157 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build medium
+// +build medium
+
+package export
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestXlsxExporter(t *testing.T) {
+	latitude := 35.6851508
+	createdAt := time.Date(2020, 9, 6, 0, 1, 2, 0, time.UTC)
+	pages := [][]Record{
+		{{Id: 1300000000000000001, Username: "watson", CreatedAt: Iso8601Date(createdAt), FullText: "初めまして", RetweetCount: 3, Latitude: &latitude}},
+		{{Id: 2, Username: "holmes", FullText: "=1+1"}},
+	}
+
+	out := filepath.Join(t.TempDir(), "out.xlsx")
+	exporter := &XlsxExporter{
+		Name:     out,
+		Columns:  mustParseColumns("id", "username", "created_at", "full_text", "retweet_count", "latitude"),
+		Location: time.FixedZone("JST", 9*60*60),
+	}
+	if !assert.NoError(t, exporter.Open()) {
+		return
+	}
+
+	ch := make(chan []Record, len(pages))
+	for _, p := range pages {
+		ch <- p
+	}
+	close(ch)
+	assert.NoError(t, <-exporter.Export(ch))
+
+	f, err := excelize.OpenFile(out)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer f.Close()
+
+	assert.Equal(t, []string{"tweets"}, f.GetSheetList())
+
+	rows, err := f.GetRows("tweets", excelize.Options{RawCellValue: true})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"id", "username", "created_at", "full_text", "retweet_count", "latitude"},
+		{"1300000000000000001", "watson", "44080.37571759259", "初めまして", "3", "35.6851508"},
+		{"2", "holmes", "", "=1+1", "0"},
+	}, rows)
+
+	examples := map[string]struct {
+		cell     string
+		expected excelize.CellType
+	}{
+		"id":            {cell: "A2", expected: excelize.CellTypeInlineString},
+		"username":      {cell: "B2", expected: excelize.CellTypeInlineString},
+		"created_at":    {cell: "C2", expected: excelize.CellTypeUnset},
+		"retweet_count": {cell: "E2", expected: excelize.CellTypeUnset},
+		"latitude":      {cell: "F2", expected: excelize.CellTypeUnset},
+		"formula":       {cell: "D3", expected: excelize.CellTypeInlineString},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			actual, err := f.GetCellType("tweets", e.cell)
+			assert.NoError(t, err)
+			assert.Equal(t, e.expected, actual)
+		})
+	}
+
+	createdAtText, err := f.GetCellValue("tweets", "C2")
+	assert.NoError(t, err)
+	assert.Equal(t, "2020-09-06 09:01:02", createdAtText)
+
+	panes, err := f.GetPanes("tweets")
+	assert.NoError(t, err)
+	assert.True(t, panes.Freeze)
+	assert.Equal(t, 1, panes.YSplit)
+
+	width, err := f.GetColWidth("tweets", "D")
+	assert.NoError(t, err)
+	// Five wide characters and padding.
+	assert.Equal(t, float64(12), width)
+}
+
+func TestXlsxExporterSheetRows(t *testing.T) {
+	records := make([]Record, 5)
+	for i := range records {
+		records[i] = Record{Id: uint64(i + 1)}
+	}
+
+	out := filepath.Join(t.TempDir(), "out.xlsx")
+	exporter := &XlsxExporter{Name: out, Columns: mustParseColumns("id"), SheetRows: 3}
+	assert.NoError(t, exporter.WritePage(records))
+	assert.NoError(t, exporter.Close())
+
+	f, err := excelize.OpenFile(out)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer f.Close()
+
+	expected := map[string][][]string{
+		"tweets":   {{"id"}, {"1"}, {"2"}},
+		"tweets 2": {{"id"}, {"3"}, {"4"}},
+		"tweets 3": {{"id"}, {"5"}},
+	}
+
+	assert.Equal(t, []string{"tweets", "tweets 2", "tweets 3"}, f.GetSheetList())
+	for name, rows := range expected {
+		actual, err := f.GetRows(name)
+		assert.NoError(t, err, fmt.Sprintf("sheet %s", name))
+		assert.Equal(t, rows, actual, fmt.Sprintf("sheet %s", name))
+	}
+}
+
+func TestXlsxExporterEmpty(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out.xlsx")
+	exporter := &XlsxExporter{Name: out}
+	assert.NoError(t, exporter.Open())
+	assert.NoError(t, exporter.Close())
+
+	f, err := excelize.OpenFile(out)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer f.Close()
+
+	rows, err := f.GetRows(XlsxSheetName)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{ColumnNames(DefaultColumns)}, rows)
+}
+
+func TestXlsxExporterAppend(t *testing.T) {
+	e := &XlsxExporter{Name: filepath.Join(t.TempDir(), "out.xlsx"), Mode: OutputAppend}
+	assert.EqualError(t, e.Open(), "xlsx output cannot be appended to")
+}
@@ -0,0 +1,246 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return LevelInfo, fmt.Errorf("unknown log level: %s", s)
+	}
+}
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	default:
+		return "LEVEL(" + strconv.Itoa(int(l)) + ")"
+	}
+}
+
+// Logger writes a message with alternating key-value pairs as attributes.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+type Format string
+
+const (
+	FormatText Format = "text"
+	FormatJson Format = "json"
+)
+
+type StreamLogger struct {
+	Level  Level
+	Format Format
+
+	mu  sync.Mutex
+	w   io.Writer
+	now func() time.Time
+}
+
+func New(w io.Writer, level Level, format Format) *StreamLogger {
+	return &StreamLogger{Level: level, Format: format, w: w, now: time.Now}
+}
+
+func (l *StreamLogger) Debug(msg string, args ...interface{}) {
+	l.log(LevelDebug, msg, args)
+}
+
+func (l *StreamLogger) Info(msg string, args ...interface{}) {
+	l.log(LevelInfo, msg, args)
+}
+
+func (l *StreamLogger) Warn(msg string, args ...interface{}) {
+	l.log(LevelWarn, msg, args)
+}
+
+func (l *StreamLogger) Error(msg string, args ...interface{}) {
+	l.log(LevelError, msg, args)
+}
+
+func (l *StreamLogger) log(level Level, msg string, args []interface{}) {
+	if level < l.Level {
+		return
+	}
+
+	keys := []string{"time", "level", "msg"}
+	values := []interface{}{l.now().UTC().Format(time.RFC3339Nano), level.String(), msg}
+	for i := 0; i < len(args); i += 2 {
+		if i+1 < len(args) {
+			keys = append(keys, fmt.Sprint(args[i]))
+			values = append(values, args[i+1])
+		} else {
+			keys = append(keys, "!BADKEY")
+			values = append(values, args[i])
+		}
+	}
+
+	var buf bytes.Buffer
+	if l.Format == FormatJson {
+		writeJson(&buf, keys, values)
+	} else {
+		writeText(&buf, keys, values)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w.Write(buf.Bytes())
+}
+
+func writeText(buf *bytes.Buffer, keys []string, values []interface{}) {
+	for i, k := range keys {
+		if i != 0 {
+			buf.WriteByte(' ')
+		}
+
+		s := fmt.Sprint(values[i])
+		if err, ok := values[i].(error); ok {
+			s = err.Error()
+		}
+
+		if len(s) == 0 || strings.ContainsAny(s, " \"=\n\t") {
+			s = strconv.Quote(s)
+		}
+
+		buf.WriteString(k + "=" + s)
+	}
+
+	buf.WriteByte('\n')
+}
+
+func writeJson(buf *bytes.Buffer, keys []string, values []interface{}) {
+	buf.WriteByte('{')
+	for i, k := range keys {
+		if i != 0 {
+			buf.WriteByte(',')
+		}
+
+		key, _ := json.Marshal(k)
+		buf.Write(key)
+		buf.WriteByte(':')
+
+		v := values[i]
+		switch x := v.(type) {
+		case error:
+			v = x.Error()
+		case time.Duration:
+			v = x.String()
+		}
+
+		value, err := json.Marshal(v)
+		if err != nil {
+			value, _ = json.Marshal(fmt.Sprint(v))
+		}
+		buf.Write(value)
+	}
+
+	buf.WriteString("}\n")
+}
+
+type attrLogger struct {
+	l    Logger
+	args []interface{}
+}
+
+// With returns a logger that adds the key-value pairs to every message.
+func With(l Logger, args ...interface{}) Logger {
+	return &attrLogger{l: l, args: args}
+}
+
+func (l *attrLogger) with(args []interface{}) []interface{} {
+	return append(append([]interface{}{}, l.args...), args...)
+}
+
+func (l *attrLogger) Debug(msg string, args ...interface{}) {
+	l.l.Debug(msg, l.with(args)...)
+}
+
+func (l *attrLogger) Info(msg string, args ...interface{}) {
+	l.l.Info(msg, l.with(args)...)
+}
+
+func (l *attrLogger) Warn(msg string, args ...interface{}) {
+	l.l.Warn(msg, l.with(args)...)
+}
+
+func (l *attrLogger) Error(msg string, args ...interface{}) {
+	l.l.Error(msg, l.with(args)...)
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, args ...interface{}) {}
+func (nopLogger) Info(msg string, args ...interface{})  {}
+func (nopLogger) Warn(msg string, args ...interface{})  {}
+func (nopLogger) Error(msg string, args ...interface{}) {}
+
+// Nop discards all messages.
+var Nop Logger = nopLogger{}
+
+var (
+	defaultMu     sync.RWMutex
+	defaultLogger = Nop
+)
+
+// Default returns the logger set by SetDefault, or Nop.
+func Default() Logger {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultLogger
+}
+
+func SetDefault(l Logger) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultLogger = l
+}
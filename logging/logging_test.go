@@ -0,0 +1,155 @@
+// Copyright 2022 Akiomi Kamakura
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build small
+// +build small
+
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLevel(t *testing.T) {
+	examples := map[string]struct {
+		input       string
+		expected    Level
+		expectError bool
+	}{
+		"debug": {
+			input:       "debug",
+			expected:    LevelDebug,
+			expectError: false,
+		},
+		"info": {
+			input:       "info",
+			expected:    LevelInfo,
+			expectError: false,
+		},
+		"warn": {
+			input:       "WARN",
+			expected:    LevelWarn,
+			expectError: false,
+		},
+		"error": {
+			input:       "error",
+			expected:    LevelError,
+			expectError: false,
+		},
+		"unknown": {
+			input:       "foo",
+			expected:    LevelInfo,
+			expectError: true,
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			actual, err := ParseLevel(e.input)
+			if e.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, e.expected, actual)
+		})
+	}
+}
+
+func TestStreamLogger(t *testing.T) {
+	examples := map[string]struct {
+		level    Level
+		format   Format
+		log      func(l Logger)
+		expected string
+	}{
+		"text": {
+			level:  LevelInfo,
+			format: FormatText,
+			log: func(l Logger) {
+				l.Info("http request", "method", "GET", "status", 200, "latency", 150*time.Millisecond)
+			},
+			expected: "time=2022-05-01T00:00:00Z level=INFO msg=\"http request\" method=GET status=200 latency=150ms\n",
+		},
+		"text-quoted": {
+			level:  LevelInfo,
+			format: FormatText,
+			log: func(l Logger) {
+				l.Error("failed", "error", errors.New("200: forbidden"), "empty", "")
+			},
+			expected: "time=2022-05-01T00:00:00Z level=ERROR msg=failed error=\"200: forbidden\" empty=\"\"\n",
+		},
+		"json": {
+			level:  LevelInfo,
+			format: FormatJson,
+			log: func(l Logger) {
+				l.Warn("retrying search", "attempt", 1, "error", errors.New("forbidden"), "latency", time.Second)
+			},
+			expected: `{"time":"2022-05-01T00:00:00Z","level":"WARN","msg":"retrying search","attempt":1,"error":"forbidden","latency":"1s"}` + "\n",
+		},
+		"bad-key": {
+			level:  LevelInfo,
+			format: FormatJson,
+			log: func(l Logger) {
+				l.Info("foo", "bar")
+			},
+			expected: `{"time":"2022-05-01T00:00:00Z","level":"INFO","msg":"foo","!BADKEY":"bar"}` + "\n",
+		},
+		"filtered": {
+			level:  LevelWarn,
+			format: FormatText,
+			log: func(l Logger) {
+				l.Debug("foo")
+				l.Info("bar")
+			},
+			expected: "",
+		},
+	}
+
+	for name, e := range examples {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			l := New(&buf, e.level, e.format)
+			l.now = func() time.Time { return time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC) }
+
+			e.log(l)
+			assert.Equal(t, e.expected, buf.String())
+		})
+	}
+}
+
+func TestWith(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, LevelInfo, FormatText)
+	l.now = func() time.Time { return time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC) }
+
+	With(l, "job", "foo").Info("bar", "baz", 1)
+	assert.Equal(t, "time=2022-05-01T00:00:00Z level=INFO msg=bar job=foo baz=1\n", buf.String())
+}
+
+func TestDefault(t *testing.T) {
+	assert.Equal(t, Nop, Default())
+
+	l := New(&bytes.Buffer{}, LevelInfo, FormatText)
+	SetDefault(l)
+	defer SetDefault(Nop)
+
+	assert.Equal(t, l, Default())
+}
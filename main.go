@@ -17,6 +17,8 @@ package main
 import (
 	"fmt"
 	"os"
+	// Embed the time zone database for --timezone on systems without one.
+	_ "time/tzdata"
 
 	"github.com/akiomik/squawks/cmd"
 )